@@ -1,12 +1,11 @@
 package bloom
 
 import (
-	"crypto/sha256"
 	"encoding/binary"
 	"encoding/hex"
-	"errors"
 	"fmt"
 	"math"
+	"math/bits"
 	"strings"
 )
 
@@ -18,8 +17,8 @@ type BigBloom struct {
 	// number of hash functions
 	k int
 
-	// bloom filter bytes
-	bs []byte
+	// bloom filter bits, 64 per word. Bit i is bit i%64 of word i/64, so the little endian bytes of the words are the bytes of the filter
+	words []uint64
 
 	// number of bytes
 	len int
@@ -32,93 +31,97 @@ type BigBloom struct {
 
 	// is loaded using FromBytes. This is used to ignore accuracy calculations
 	isLoaded bool
+
+	// optional, secret key for SipHash-2-4 hashing. Unkeyed filters use SHA256 with a nonce
+	key *[BLOOM_KEY_LEN]byte
 }
 
+// number of bytes in a key for keyed hashing
+const BLOOM_KEY_LEN = 16
+
+// version of the encoding written by MarshalBinary. UnmarshalBinary also reads every earlier version
+const BIG_BLOOM_ENCODING_VERSION = 1
+
+// BigBloom implements the shared interface of dynamic filters
+var _ MembershipFilter[*BigBloom] = (*BigBloom)(nil)
+
 //
 // Constructors
 //
 
 // Constructs len-byte bloom filter from k.
 func NewBigBloomFromK(len, k int) (*BigBloom, error) {
-	if k < 1 {
-		return nil, errors.New("k cannot be less than 1")
+	if err := checkK(k); err != nil {
+		return nil, err
 	}
 	return &BigBloom{
 		n:                    0,
 		k:                    k,
-		bs:                   make([]byte, len),
+		words:                make([]uint64, wordsLen(len)),
 		len:                  len,
 		maxFalsePositiveRate: nil,
 		cap:                  nil,
 		isLoaded:             false,
+		key:                  nil,
 	}, nil
 }
 
 // Constructs len-byte bloom filter from capacity
 func NewBigBloomFromCap(len, cap int) (*BigBloom, error) {
 	if cap < 1 {
-		return nil, errors.New("capacity cannot be less than 1")
+		return nil, newParameterError("cap", cap, "capacity cannot be less than 1")
 	}
 	return &BigBloom{
 		n:                    0,
 		k:                    calcKFromCap(len, cap),
-		bs:                   make([]byte, len),
+		words:                make([]uint64, wordsLen(len)),
 		len:                  len,
 		maxFalsePositiveRate: nil,
 		cap:                  nil,
 		isLoaded:             false,
+		key:                  nil,
 	}, nil
 }
 
 // Constructs len-byte bloom filter from maxFalsePositiveRate
 func NewBigBloomFromAcc(len int, maxFalsePositiveRate float64) (*BigBloom, error) {
 	if maxFalsePositiveRate <= 0 || maxFalsePositiveRate >= 1 {
-		return nil, errors.New("false positive rate must be between 0 and 1")
+		return nil, newParameterError("maxFalsePositiveRate", maxFalsePositiveRate, "false positive rate must be between 0 and 1")
 	}
 	return &BigBloom{
 		n:                    0,
 		k:                    calcKFromAcc(len, maxFalsePositiveRate),
-		bs:                   make([]byte, len),
+		words:                make([]uint64, wordsLen(len)),
 		len:                  len,
 		maxFalsePositiveRate: nil,
 		cap:                  nil,
 		isLoaded:             false,
+		key:                  nil,
 	}, nil
 }
 
 // Constructs bloom filter with cap and maxFalsePositiveRate
 func NewBigBloomAlloc(cap int, maxFalsePositiveRate float64) (*BigBloom, error) {
 	if cap < 1 {
-		return nil, errors.New("capacity cannot be less than 1")
+		return nil, newParameterError("cap", cap, "capacity cannot be less than 1")
 	}
 	if maxFalsePositiveRate <= 0 || maxFalsePositiveRate >= 1 {
-		return nil, errors.New("false positive rate must be between 0 and 1")
-	}
-
-	// math:
-	// eq1: k = ln(2) * m/n
-	// eq2: acc = (1 - (1 - e^(-kn/m))^k
-	// substitute k from eq1 into eq2 ...
-	// acc = (.5)^(ln(2) * m/n)
-	// log0.5(acc) = ln(2) * m/n
-	// m = (n * log0.5(acc))/ln(2)
-	// change of base ...
-	// m = (n * ln(acc)) / (ln(0.5) * ln(2))
-	numerator := float64(cap) * math.Log(maxFalsePositiveRate)
-	denom := math.Log(.5) * math.Log(2)
-	mFloat := numerator / denom
-	len := int(math.Ceil(mFloat / 8))
+		return nil, newParameterError("maxFalsePositiveRate", maxFalsePositiveRate, "false positive rate must be between 0 and 1")
+	}
+
+	len := calcLenFromCapAcc(cap, maxFalsePositiveRate)
 	// calculate k using m
 	k := calcKFromCap(len, cap)
 
 	return &BigBloom{
 		n:                    0,
 		k:                    k,
-		bs:                   make([]byte, len),
+		words:                make([]uint64, wordsLen(len)),
 		len:                  len,
 		maxFalsePositiveRate: &maxFalsePositiveRate,
 		cap:                  &cap,
 		isLoaded:             false,
+		key:                  nil,
 	}, nil
 
 }
@@ -126,24 +129,43 @@ func NewBigBloomAlloc(cap int, maxFalsePositiveRate float64) (*BigBloom, error)
 // Load bloom filter from bytes of bloom filter and k
 // This is useful for loading in a Bloom filter over the wire.
 // This mechanism will disable accuracy calculations because n is unknown
+// bs is copied, so later changes to bs do not show in the filter and inserts into the filter do not write to bs.
 func NewBigBloomFromBytes(bs []byte, k int) (*BigBloom, error) {
-	if k < 1 {
-		return nil, errors.New("k cannot be less than 1")
+	if err := checkK(k); err != nil {
+		return nil, err
 	}
 	if len(bs) == 0 {
-		return nil, errors.New("bloom filter length cannot be 0")
+		return nil, newParameterError("bs", bs, "bloom filter length cannot be 0")
 	}
 	return &BigBloom{
 		n:                    0,
 		k:                    k,
-		bs:                   bs,
+		words:                bytesToWords(bs),
 		len:                  len(bs),
 		maxFalsePositiveRate: nil,
 		cap:                  nil,
 		isLoaded:             true,
+		key:                  nil,
 	}, nil
 }
 
+// Load bloom filter from an encoding of any version, and check that it contains entries known to have been inserted into it.
+// A missing entry means the filter was built with hashing that differs from this version of the package,
+// and a *CompatibilityError is returned rather than a filter that silently gives false negatives.
+func LoadBigBloom(bs []byte, knownEntries ...[]byte) (*BigBloom, error) {
+	var b BigBloom
+	if err := b.UnmarshalBinary(bs); err != nil {
+		return nil, err
+	}
+	version, _ := BigBloomEncodingVersion(bs)
+	for _, entry := range knownEntries {
+		if exists, _ := b.ExistsBytes(entry); !exists {
+			return nil, &CompatibilityError{Version: version, Entry: entry, Reason: ""}
+		}
+	}
+	return &b, nil
+}
+
 //
 // Methods
 //
@@ -162,32 +184,27 @@ func (b *BigBloom) PutBytes(bs []byte) (*BigBloom, error) {
 	}
 
 	if b.cap != nil && b.n == *b.cap {
-		return b, &CapacityError{cap: *b.cap}
+		return b, &CapacityError{Cap: *b.cap, N: b.n}
 	}
 
 	if b.maxFalsePositiveRate != nil {
-		if falsePositiveRate(b.len, b.n+1, b.k) > *b.maxFalsePositiveRate {
-			return b, &AccuracyError{acc: *b.maxFalsePositiveRate}
+		if projected := falsePositiveRate(b.len, b.n+1, b.k); projected > *b.maxFalsePositiveRate {
+			return b, &AccuracyError{MaxFalsePositiveRate: *b.maxFalsePositiveRate, N: b.n, FalsePositiveRate: projected}
 		}
 	}
 
-	totBits := len(b.bs) * 8
+	totBits := b.len * 8
 	for i := 0; i < b.k; i++ {
-		// a single change in bs makes the whole SHA hash change, so an appended nonce is suitable
-		bsNonce := append(bs, byte(i))
-		var h [32]byte = sha256.Sum256(bsNonce)
-		// get a random uint64 number
-		bytes := h[0:8]
-		// find index of bit
-		bitI := binary.BigEndian.Uint64(bytes) % uint64(totBits)
-		// find index of byte
-		byteI := int(math.Floor(float64(bitI) / float64(8)))
-		// find index of bit within byte
-		iInByte := bitI % 8
-		// bit shift 1
-		bitFlip := byte(1 << iInByte)
+		var bitI uint64
+		if b.key != nil {
+			// find index of bit with the keyed hash
+			bitI = keyedHashIndex(b.key, bs, i, uint64(totBits))
+		} else {
+			// a single change in bs makes the whole SHA hash change, so an appended nonce is suitable
+			bitI = hashIndex(bs, i, uint64(totBits))
+		}
 		// set bit to 1
-		b.bs[byteI] = b.bs[byteI] | bitFlip
+		b.words[bitI>>6] |= 1 << (bitI & 63)
 	}
 
 	b.n++
@@ -203,23 +220,18 @@ func (b *BigBloom) ExistsStr(s string) (bool, float64) {
 // Checks for existance of bytes element in a bloom filter. Returns boolean and false positive rate.
 func (b *BigBloom) ExistsBytes(bs []byte) (bool, float64) {
 
-	totBits := len(b.bs) * 8
+	totBits := b.len * 8
 	for i := 0; i < b.k; i++ {
-		// a single change in bs makes the whole SHA hash change, so an appended nonce is suitable
-		bsNonce := append(bs, byte(i))
-		var h [32]byte = sha256.Sum256(bsNonce)
-		// get a random uint64 number
-		bytes := h[0:8]
-		// find index of bit
-		bitI := binary.BigEndian.Uint64(bytes) % uint64(totBits)
-		// find index of byte
-		byteI := int(math.Floor(float64(bitI) / float64(8)))
-		// find index of bit within byte
-		iInByte := bitI % 8
-		// bit shift 1
-		bitFlip := byte(1 << iInByte)
-		// it doesn't exists if there is a bitFlip
-		if b.bs[byteI] != b.bs[byteI]|bitFlip {
+		var bitI uint64
+		if b.key != nil {
+			// find index of bit with the keyed hash
+			bitI = keyedHashIndex(b.key, bs, i, uint64(totBits))
+		} else {
+			// a single change in bs makes the whole SHA hash change, so an appended nonce is suitable
+			bitI = hashIndex(bs, i, uint64(totBits))
+		}
+		// it doesn't exists if the bit is 0
+		if b.words[bitI>>6]&(1<<(bitI&63)) == 0 {
 			return false, 1
 		}
 	}
@@ -238,18 +250,29 @@ func (b *BigBloom) Accuracy() float64 {
 	return falsePositiveRate(b.len, b.n, b.k)
 }
 
+// Hashes elements with SipHash-2-4 keyed by the secret key instead of unkeyed SHA256.
+// Without the key, elements that set chosen bits cannot be crafted, so the filter resists pollution by untrusted input.
+// Loaded filters must be given the key they were built with.
+func (b *BigBloom) AddKey(key [BLOOM_KEY_LEN]byte) error {
+	if b.n > 0 {
+		return newParameterError("key", nil, "cannot add key to bloom filter with entries")
+	}
+	b.key = &key
+	return nil
+}
+
 // Constrains bloom from not adding more than cap insertions
 func (b *BigBloom) AddCapacityConstraint(cap int) error {
 	if b.isLoaded {
-		return errors.New("cannot add constraints to loaded bloom filters")
+		return ErrLoaded
 	}
 	if cap < 1 {
-		return errors.New("capacity cannot be less than 1")
+		return newParameterError("cap", cap, "capacity cannot be less than 1")
 	}
 	if b.maxFalsePositiveRate != nil {
 		// check if contraints capacity and maxFalsePositiveRate are compatible together with this size bloom filter
 		if !constraintsCompatible(b.len, cap, b.k, *b.maxFalsePositiveRate) {
-			return errors.New("false positive rate will be higher at full capacity than the maxFalsePositiveRate provided")
+			return &ConstraintError{Cap: cap, MaxFalsePositiveRate: *b.maxFalsePositiveRate, FalsePositiveRate: falsePositiveRate(b.len, cap, b.k)}
 		}
 	}
 	b.cap = &cap
@@ -259,21 +282,105 @@ func (b *BigBloom) AddCapacityConstraint(cap int) error {
 // Constrains bloom from not adding more insertions that cause accuracy to be worse than maxFalsePositiveRate
 func (b *BigBloom) AddAccuracyConstraint(maxFalsePositiveRate float64) error {
 	if b.isLoaded {
-		return errors.New("cannot add constraints to loaded bloom filters")
+		return ErrLoaded
 	}
 	if maxFalsePositiveRate <= 0 || maxFalsePositiveRate >= 1 {
-		return errors.New("false positive rate must be between 0 and 1")
+		return newParameterError("maxFalsePositiveRate", maxFalsePositiveRate, "false positive rate must be between 0 and 1")
 	}
 	if b.cap != nil {
 		// check if contraints capacity and maxFalsePositiveRate are compatible together with this size bloom filter
 		if !constraintsCompatible(b.len, *b.cap, b.k, maxFalsePositiveRate) {
-			return errors.New("false positive rate will be higher at full capacity than the maxFalsePositiveRate provided")
+			return &ConstraintError{Cap: *b.cap, MaxFalsePositiveRate: maxFalsePositiveRate, FalsePositiveRate: falsePositiveRate(b.len, *b.cap, b.k)}
 		}
 	}
 	b.maxFalsePositiveRate = &maxFalsePositiveRate
 	return nil
 }
 
+// Removes all entries, keeping k, the key and the constraints. A filter loaded from bytes is empty after a reset, so its accuracy is calculated again.
+func (b *BigBloom) Reset() {
+	for i := range b.words {
+		b.words[i] = 0
+	}
+	b.n = 0
+	b.isLoaded = false
+}
+
+// Deep copies bloom filter, including its constraints and key
+func (b *BigBloom) Clone() *BigBloom {
+	cap, maxFalsePositiveRate := cloneConstraints(b.cap, b.maxFalsePositiveRate)
+	var key *[BLOOM_KEY_LEN]byte
+	if b.key != nil {
+		keyCopy := *b.key
+		key = &keyCopy
+	}
+	return &BigBloom{
+		n:                    b.n,
+		k:                    b.k,
+		words:                append([]uint64(nil), b.words...),
+		len:                  b.len,
+		maxFalsePositiveRate: maxFalsePositiveRate,
+		cap:                  cap,
+		isLoaded:             b.isLoaded,
+		key:                  key,
+	}
+}
+
+// Checks whether b and other answer every query the same: same k, key and bits
+func (b *BigBloom) Equal(other *BigBloom) bool {
+	if b.k != other.k || b.len != other.len {
+		return false
+	}
+	for i := range b.words {
+		if b.words[i] != other.words[i] {
+			return false
+		}
+	}
+	if b.key == nil || other.key == nil {
+		return b.key == nil && other.key == nil
+	}
+	return *b.key == *other.key
+}
+
+// Sets the bits of b to the union of the bits of b and other, so b contains the entries of both.
+// Both filters must have the same length, k and key. n is estimated from the bits that are set and constraints of b are not checked.
+func (b *BigBloom) Union(other *BigBloom) error {
+	if err := b.checkCompatible(other); err != nil {
+		return err
+	}
+	for i := range b.words {
+		b.words[i] |= other.words[i]
+	}
+	b.n = b.estimateN()
+	b.isLoaded = b.isLoaded || other.isLoaded
+	return nil
+}
+
+// Sets the bits of b to the intersection of the bits of b and other, so b contains the entries in both and,
+// more often than either filter on its own, some entries that are only in one of them.
+// Both filters must have the same length, k and key. n is estimated from the bits that are set.
+func (b *BigBloom) Intersect(other *BigBloom) error {
+	if err := b.checkCompatible(other); err != nil {
+		return err
+	}
+	for i := range b.words {
+		b.words[i] &= other.words[i]
+	}
+	b.n = b.estimateN()
+	b.isLoaded = b.isLoaded || other.isLoaded
+	return nil
+}
+
+// Number of unique entries. Filters loaded from bytes start at 0
+func (b *BigBloom) N() int {
+	return b.n
+}
+
+// Get share of bits that are set
+func (b *BigBloom) FillRatio() float64 {
+	return float64(b.ones()) / float64(8*b.len)
+}
+
 func (b *BigBloom) String() string {
 	var buf strings.Builder
 
@@ -293,5 +400,171 @@ func (b *BigBloom) String() string {
 
 // converts bytes of bloom filter to hex string
 func (b *BigBloom) Hex() string {
-	return hex.EncodeToString(b.bs)
+	return hex.EncodeToString(b.Bytes())
+}
+
+// Get bytes of bloom filter. Can be loaded back in with NewBigBloomFromBytes
+func (b *BigBloom) Bytes() []byte {
+	bs := make([]byte, 8*len(b.words))
+	for i, word := range b.words {
+		binary.LittleEndian.PutUint64(bs[8*i:], word)
+	}
+	return bs[:b.len]
+}
+
+// Get encoding version of bloom filter encoded with MarshalBinary
+func BigBloomEncodingVersion(bs []byte) (int, error) {
+	if len(bs) < 1 {
+		return 0, newDecodeError("big bloom", "bloom filter encoding too short")
+	}
+	return int(bs[0]>>bigBloomVersionShift) & bigBloomVersionMask, nil
+}
+
+// Encodes bloom filter without its key, so it can be published. Keyed filters must be decoded by a filter given the key with AddKey.
+func (b *BigBloom) MarshalBinary() ([]byte, error) {
+	return b.marshalBinary(false), nil
+}
+
+// Encodes bloom filter with its key, for storing it privately
+func (b *BigBloom) MarshalBinaryWithKey() ([]byte, error) {
+	return b.marshalBinary(true), nil
+}
+
+// Decodes bloom filter encoded with MarshalBinary or MarshalBinaryWithKey of this or any earlier version. Constraints are not encoded.
+// An encoding of a keyed filter without the key keeps the key already added to b.
+func (b *BigBloom) UnmarshalBinary(bs []byte) error {
+	version, err := BigBloomEncodingVersion(bs)
+	if err != nil {
+		return err
+	}
+	if version > BIG_BLOOM_ENCODING_VERSION {
+		return &CompatibilityError{Version: version, Entry: nil, Reason: fmt.Sprintf("unsupported bloom filter encoding version %d", version)}
+	}
+	if len(bs) < 13 {
+		return newDecodeError("big bloom", "bloom filter encoding too short")
+	}
+	// version 0, from before the version field, has the same layout as version 1 with the version bits unset
+	flags := bs[0] &^ (bigBloomVersionMask << bigBloomVersionShift)
+	k := int(binary.BigEndian.Uint32(bs[1:5]))
+	n := binary.BigEndian.Uint64(bs[5:13])
+	bs = bs[13:]
+	if err := checkK(k); err != nil {
+		return err
+	}
+	if n > math.MaxInt {
+		return newDecodeError("big bloom", "number of entries does not fit in an int")
+	}
+
+	key := b.key
+	if flags&bigBloomKeyIncluded != 0 {
+		if len(bs) < BLOOM_KEY_LEN {
+			return newDecodeError("big bloom", "bloom filter encoding too short")
+		}
+		var included [BLOOM_KEY_LEN]byte
+		copy(included[:], bs)
+		key = &included
+		bs = bs[BLOOM_KEY_LEN:]
+	} else if flags&bigBloomKeyed == 0 {
+		key = nil
+	} else if key == nil {
+		return newParameterError("key", nil, "bloom filter encoding is keyed but does not include the key")
+	}
+	if len(bs) == 0 {
+		return newParameterError("len", 0, "bloom filter length cannot be 0")
+	}
+
+	b.n = int(n)
+	b.k = k
+	b.words = bytesToWords(bs)
+	b.len = len(bs)
+	b.cap = nil
+	b.maxFalsePositiveRate = nil
+	b.isLoaded = false
+	b.key = key
+	return nil
+}
+
+//
+// helpers
+//
+
+// flags of the encoding
+const (
+	// hashes with a key
+	bigBloomKeyed = 1 << iota
+
+	// key follows the header
+	bigBloomKeyIncluded
+)
+
+// the version is in the high 4 bits of the flags byte
+const (
+	bigBloomVersionShift = 4
+	bigBloomVersionMask  = 0xf
+)
+
+// encodes flags, k, n, the key if withKey is set, and the bytes of the filter
+func (b *BigBloom) marshalBinary(withKey bool) []byte {
+	var flags byte = BIG_BLOOM_ENCODING_VERSION << bigBloomVersionShift
+	if b.key != nil {
+		flags |= bigBloomKeyed
+		if withKey {
+			flags |= bigBloomKeyIncluded
+		}
+	}
+	bs := make([]byte, 0, 13+BLOOM_KEY_LEN+b.len)
+	bs = append(bs, flags)
+	bs = binary.BigEndian.AppendUint32(bs, uint32(b.k))
+	bs = binary.BigEndian.AppendUint64(bs, uint64(b.n))
+	if flags&bigBloomKeyIncluded != 0 {
+		bs = append(bs, b.key[:]...)
+	}
+	return append(bs, b.Bytes()...)
+}
+
+// number of words holding len bytes
+func wordsLen(len int) int {
+	return (len + 7) / 8
+}
+
+// packs bs into little endian words. The last word is padded with zeros
+func bytesToWords(bs []byte) []uint64 {
+	words := make([]uint64, wordsLen(len(bs)))
+	for i := range words {
+		var word [8]byte
+		copy(word[:], bs[8*i:])
+		words[i] = binary.LittleEndian.Uint64(word[:])
+	}
+	return words
+}
+
+// count bits that are set
+func (b *BigBloom) ones() int {
+	ones := 0
+	for _, word := range b.words {
+		ones += bits.OnesCount64(word)
+	}
+	return ones
+}
+
+// estimate number of unique entries from the bits that are set (Swamidass & Baldi, 2007): -m/k * ln(1 - ones/m)
+func (b *BigBloom) estimateN() int {
+	m := float64(8 * b.len)
+	ones := float64(b.ones())
+	if ones == m {
+		// every bit is set, any number of entries could be in the filter
+		return int(m)
+	}
+	return int(math.Round(-m / float64(b.k) * math.Log1p(-ones/m)))
+}
+
+// checks that b and other hash entries to the same bits
+func (b *BigBloom) checkCompatible(other *BigBloom) error {
+	if b.len != other.len || b.k != other.k {
+		return &MismatchError{Reason: "cannot combine bloom filters with different lengths or k"}
+	}
+	if (b.key == nil) != (other.key == nil) || (b.key != nil && *b.key != *other.key) {
+		return &MismatchError{Reason: "cannot combine bloom filters with different keys"}
+	}
+	return nil
 }
@@ -1,7 +1,11 @@
 package bloom
 
 import (
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"math"
+	"math/bits"
 	"testing"
 
 	"strconv"
@@ -28,6 +32,41 @@ func TestNewBigBloomFromK(t *testing.T) {
 	// test zero k
 	_, err := NewBigBloomFromK(32, 0)
 	assert.EqualError(t, err, "k cannot be less than 1")
+
+	// test k above the limit shared with decoding
+	_, err = NewBigBloomFromK(1000, BLOOM_MAX_K+1)
+	assert.EqualError(t, err, "k cannot be more than 255")
+	_, err = NewBigBloomFromBytes(make([]byte, 1000), BLOOM_MAX_K+1)
+	assert.EqualError(t, err, "k cannot be more than 255")
+
+	// k computed for a large filter with few entries is capped
+	b, err := NewBigBloomFromCap(1<<20, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, BLOOM_MAX_K, b.k)
+}
+
+func TestBigBloomMaxKRoundTrip(t *testing.T) {
+	key := [BLOOM_KEY_LEN]byte{1}
+	for _, keyed := range []bool{false, true} {
+		b, err := NewBigBloomFromK(1000, BLOOM_MAX_K)
+		assert.Nil(t, err)
+		if keyed {
+			assert.Nil(t, b.AddKey(key))
+		}
+		_, err = b.PutStr("a")
+		assert.Nil(t, err)
+
+		bs, err := b.MarshalBinary()
+		assert.Nil(t, err)
+		var decoded BigBloom
+		if keyed {
+			assert.Nil(t, decoded.AddKey(key))
+		}
+		assert.Nil(t, decoded.UnmarshalBinary(bs))
+		assert.True(t, b.Equal(&decoded))
+		exists, _ := decoded.ExistsStr("a")
+		assert.True(t, exists)
+	}
 }
 
 func TestNewBigBloomAlloc(t *testing.T) {
@@ -76,6 +115,79 @@ func TestNewBigBloomFromBytes(t *testing.T) {
 	bs = make([]byte, 1)
 	_, err = NewBigBloomFromBytes(bs, 0)
 	assert.EqualError(t, err, "k cannot be less than 1")
+
+	// bs is copied: changes to it do not show in the filter, and inserts do not write to it
+	bs = make([]byte, 8)
+	loaded, err := NewBigBloomFromBytes(bs, 2)
+	assert.Nil(t, err)
+	bs[0] = 0xff
+	assert.Equal(t, byte(0), loaded.Bytes()[0])
+	_, err = loaded.PutStr("a")
+	assert.Nil(t, err)
+	assert.Equal(t, byte(0xff), bs[0])
+	assert.NotEqual(t, make([]byte, 8), loaded.Bytes())
+	assert.True(t, loaded.isLoaded)
+}
+
+func TestBigBloomResetCloneEqual(t *testing.T) {
+	b, err := NewBigBloomAlloc(100, .01)
+	assert.Nil(t, err)
+	key, err := GenerateKey()
+	assert.Nil(t, err)
+	err = b.AddKey(key)
+	assert.Nil(t, err)
+	_, err = b.PutStr("a")
+	assert.Nil(t, err)
+
+	// clone is equal but does not share bits, constraints or key
+	c := b.Clone()
+	assert.True(t, b.Equal(c))
+	assert.Equal(t, b, c)
+	assert.NotSame(t, b.cap, c.cap)
+	assert.NotSame(t, b.maxFalsePositiveRate, c.maxFalsePositiveRate)
+	assert.NotSame(t, b.key, c.key)
+	_, err = c.PutStr("b")
+	assert.Nil(t, err)
+	assert.False(t, b.Equal(c))
+	exists, _ := b.ExistsStr("b")
+	assert.False(t, exists)
+
+	// reset removes entries but keeps k, key and constraints
+	c.Reset()
+	assert.Equal(t, 0, c.n)
+	assert.Equal(t, make([]byte, b.len), c.Bytes())
+	assert.Equal(t, 100, *c.cap)
+	assert.Equal(t, .01, *c.maxFalsePositiveRate)
+	assert.Equal(t, key, *c.key)
+	_, err = c.PutStr("a")
+	assert.Nil(t, err)
+	assert.True(t, b.Equal(c))
+
+	// the key is compared
+	unkeyed, err := NewBigBloomFromBytes(b.Bytes(), b.k)
+	assert.Nil(t, err)
+	assert.False(t, b.Equal(unkeyed))
+	assert.False(t, unkeyed.Equal(b))
+	err = unkeyed.AddKey(key)
+	assert.Nil(t, err)
+	assert.True(t, b.Equal(unkeyed))
+	otherKey, err := GenerateKey()
+	assert.Nil(t, err)
+	c.key = &otherKey
+	assert.False(t, b.Equal(c))
+
+	// filters loaded from bytes count entries again after a reset
+	unkeyed.Reset()
+	_, err = unkeyed.PutStr("a")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, unkeyed.n)
+	assert.False(t, unkeyed.isLoaded)
+
+	// different lengths
+	short, err := NewBigBloomFromK(b.len-1, b.k)
+	assert.Nil(t, err)
+	b.Reset()
+	assert.False(t, b.Equal(short))
 }
 
 // TestPutStr also tests PutBytes because PutStr calls PutBytes
@@ -252,6 +364,251 @@ func TestBigBloomAccuracyConstraint(t *testing.T) {
 }
 
 // tests huge bloom filter
+// crafts elements that together set every bit victim sets, so that victim becomes a false positive.
+// index is the attacker's model of the filter's hash functions
+func craftFalsePositive(k int, index func(bs []byte, i int) uint64, victim []byte) [][]byte {
+	var crafted [][]byte
+	for i := 0; i < k; i++ {
+		target := index(victim, i)
+		for n := 0; ; n++ {
+			candidate := []byte("attack" + strconv.Itoa(i) + "-" + strconv.Itoa(n))
+			found := false
+			for j := 0; j < k; j++ {
+				if index(candidate, j) == target {
+					found = true
+				}
+			}
+			if found {
+				crafted = append(crafted, candidate)
+				break
+			}
+		}
+	}
+	return crafted
+}
+
+func TestBigBloomKeyed(t *testing.T) {
+	keyA := [BLOOM_KEY_LEN]byte{1}
+	keyB := [BLOOM_KEY_LEN]byte{2}
+	len, k := 128, 3
+	victim := []byte("victim")
+
+	// test adding key to filter with entries
+	b, err := NewBigBloomFromK(len, k)
+	assert.Nil(t, err)
+	b.PutStr("a")
+	err = b.AddKey(keyA)
+	assert.EqualError(t, err, "cannot add key to bloom filter with entries")
+
+	newFilter := func(key *[BLOOM_KEY_LEN]byte) *BigBloom {
+		b, err := NewBigBloomFromK(len, k)
+		assert.Nil(t, err)
+		if key != nil {
+			assert.Nil(t, b.AddKey(*key))
+		}
+		return b
+	}
+	unkeyedIndex := func(bs []byte, i int) uint64 {
+		return hashIndex(bs, i, uint64(8*len))
+	}
+	keyedIndex := func(key [BLOOM_KEY_LEN]byte) func(bs []byte, i int) uint64 {
+		return func(bs []byte, i int) uint64 {
+			return keyedHashIndex(&key, bs, i, uint64(8*len))
+		}
+	}
+
+	type craftTest struct {
+		name     string
+		crafted  [][]byte
+		key      *[BLOOM_KEY_LEN]byte
+		polluted bool
+	}
+
+	againstUnkeyed := craftFalsePositive(k, unkeyedIndex, victim)
+	againstA := craftFalsePositive(k, keyedIndex(keyA), victim)
+	tests := []craftTest{
+		// without a key anyone can craft a false positive
+		{name: "unkeyed against unkeyed", crafted: againstUnkeyed, key: nil, polluted: true},
+		{name: "unkeyed against keyed", crafted: againstUnkeyed, key: &keyA, polluted: false},
+		// an attacker who learns key A can pollute filters with key A only
+		{name: "key A against key A", crafted: againstA, key: &keyA, polluted: true},
+		{name: "key A against key B", crafted: againstA, key: &keyB, polluted: false},
+		{name: "key A against unkeyed", crafted: againstA, key: nil, polluted: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := newFilter(test.key)
+			for _, bs := range test.crafted {
+				_, err := b.PutBytes(bs)
+				assert.Nil(t, err)
+			}
+			exists, _ := b.ExistsBytes(victim)
+			assert.Equal(t, test.polluted, exists)
+		})
+	}
+
+	// keyed filters have no false negatives
+	b = newFilter(&keyA)
+	for i := 0; i < 100; i++ {
+		b.PutStr(strconv.Itoa(i))
+	}
+	for i := 0; i < 100; i++ {
+		exists, _ := b.ExistsStr(strconv.Itoa(i))
+		assert.True(t, exists)
+	}
+}
+
+func TestBigBloomMarshalBinary(t *testing.T) {
+	key := [BLOOM_KEY_LEN]byte{1, 2, 3}
+	unkeyed, err := NewBigBloomFromK(64, 3)
+	assert.Nil(t, err)
+	keyed, err := NewBigBloomFromK(64, 3)
+	assert.Nil(t, err)
+	assert.Nil(t, keyed.AddKey(key))
+	for i := 0; i < 20; i++ {
+		unkeyed.PutStr(strconv.Itoa(i))
+		keyed.PutStr(strconv.Itoa(i))
+	}
+
+	// unkeyed round trip
+	bs, err := unkeyed.MarshalBinary()
+	assert.Nil(t, err)
+	assert.Equal(t, 13+64, len(bs))
+	var decoded BigBloom
+	assert.Nil(t, decoded.UnmarshalBinary(bs))
+	assert.Equal(t, unkeyed.Hex(), decoded.Hex())
+	assert.Equal(t, unkeyed.n, decoded.n)
+	assert.Equal(t, unkeyed.Accuracy(), decoded.Accuracy())
+
+	// keyed round trip with key
+	bs, err = keyed.MarshalBinaryWithKey()
+	assert.Nil(t, err)
+	assert.Equal(t, 13+BLOOM_KEY_LEN+64, len(bs))
+	decoded = BigBloom{}
+	assert.Nil(t, decoded.UnmarshalBinary(bs))
+	assert.Equal(t, key, *decoded.key)
+	for i := 0; i < 20; i++ {
+		exists, _ := decoded.ExistsStr(strconv.Itoa(i))
+		assert.True(t, exists)
+	}
+
+	// keyed without key can only be decoded by a filter with the key
+	bs, err = keyed.MarshalBinary()
+	assert.Nil(t, err)
+	assert.Equal(t, 13+64, len(bs))
+	decoded = BigBloom{}
+	err = decoded.UnmarshalBinary(bs)
+	assert.EqualError(t, err, "bloom filter encoding is keyed but does not include the key")
+	assert.Nil(t, decoded.AddKey(key))
+	assert.Nil(t, decoded.UnmarshalBinary(bs))
+	assert.Equal(t, keyed.Hex(), decoded.Hex())
+	for i := 0; i < 20; i++ {
+		exists, _ := decoded.ExistsStr(strconv.Itoa(i))
+		assert.True(t, exists)
+	}
+
+	// test invalid encodings
+	err = decoded.UnmarshalBinary(bs[:12])
+	assert.EqualError(t, err, "bloom filter encoding too short")
+	err = decoded.UnmarshalBinary(bs[:13])
+	assert.EqualError(t, err, "bloom filter length cannot be 0")
+	withKey, _ := keyed.MarshalBinaryWithKey()
+	err = decoded.UnmarshalBinary(withKey[:20])
+	assert.EqualError(t, err, "bloom filter encoding too short")
+	bs[4] = 0
+	err = decoded.UnmarshalBinary(bs)
+	assert.EqualError(t, err, "k cannot be less than 1")
+
+	// untrusted encodings cannot make checks slow or n negative
+	binary.BigEndian.PutUint32(bs[1:5], math.MaxUint32)
+	err = decoded.UnmarshalBinary(bs)
+	assert.EqualError(t, err, "k cannot be more than 255")
+	assert.True(t, errors.Is(err, ErrInvalidParameter))
+	binary.BigEndian.PutUint32(bs[1:5], 255)
+	assert.Nil(t, decoded.UnmarshalBinary(bs))
+	binary.BigEndian.PutUint32(bs[1:5], 3)
+	binary.BigEndian.PutUint64(bs[5:13], math.MaxUint64)
+	err = decoded.UnmarshalBinary(bs)
+	assert.EqualError(t, err, "number of entries does not fit in an int")
+	assert.True(t, errors.Is(err, ErrDecode))
+	assert.Equal(t, 255, decoded.k)
+	assert.GreaterOrEqual(t, decoded.N(), 0)
+}
+
+func TestBigBloomWords(t *testing.T) {
+	// bytes and hex are the same as when bits were stored in bytes, including for lengths that are not a multiple of 8
+	b, err := NewBigBloomFromK(13, 3)
+	assert.Nil(t, err)
+	key := [BLOOM_KEY_LEN]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	keyed, err := NewBigBloomFromK(13, 3)
+	assert.Nil(t, err)
+	assert.Nil(t, keyed.AddKey(key))
+	for _, s := range []string{"a", "b", "c", "d", "e"} {
+		b.PutStr(s)
+		keyed.PutStr(s)
+	}
+	assert.Equal(t, "10400000004081010029005204", b.Hex())
+	assert.Equal(t, "43014024040000008040028044", keyed.Hex())
+	assert.Equal(t, 13, len(b.Bytes()))
+	assert.Equal(t, 2, len(b.words))
+
+	// bytes round trip through words
+	loaded, err := NewBigBloomFromBytes(b.Bytes(), 3)
+	assert.Nil(t, err)
+	assert.True(t, b.Equal(loaded))
+	assert.Equal(t, b.Hex(), loaded.Hex())
+}
+
+func TestBigBloomUnionIntersect(t *testing.T) {
+	a, err := NewBigBloomAlloc(100, .01)
+	assert.Nil(t, err)
+	b, err := NewBigBloomAlloc(100, .01)
+	assert.Nil(t, err)
+	for i := 0; i < 40; i++ {
+		a.PutStr("a" + strconv.Itoa(i))
+		b.PutStr("b" + strconv.Itoa(i))
+	}
+	for i := 0; i < 10; i++ {
+		a.PutStr("both" + strconv.Itoa(i))
+		b.PutStr("both" + strconv.Itoa(i))
+	}
+	assert.InDelta(t, float64(a.ones())/float64(8*a.len), a.FillRatio(), 0)
+
+	union := a.Clone()
+	assert.Nil(t, union.Union(b))
+	intersection := a.Clone()
+	assert.Nil(t, intersection.Intersect(b))
+	for i := 0; i < 40; i++ {
+		for _, s := range []string{"a" + strconv.Itoa(i), "b" + strconv.Itoa(i)} {
+			exists, _ := union.ExistsStr(s)
+			assert.True(t, exists)
+		}
+	}
+	for i := 0; i < 10; i++ {
+		exists, _ := intersection.ExistsStr("both" + strconv.Itoa(i))
+		assert.True(t, exists)
+	}
+	assert.Greater(t, union.FillRatio(), a.FillRatio())
+	assert.Less(t, intersection.FillRatio(), a.FillRatio())
+
+	// n is estimated from the bits that are set. Bits set by entries in only one filter can coincide, so intersections are overestimated
+	assert.InDelta(t, 90, union.n, 5)
+	assert.GreaterOrEqual(t, intersection.n, 10)
+	assert.Less(t, intersection.n, a.n)
+
+	// incompatible filters
+	short, err := NewBigBloomFromK(a.len-1, a.k)
+	assert.Nil(t, err)
+	assert.EqualError(t, a.Union(short), "cannot combine bloom filters with different lengths or k")
+	keyed := b.Clone()
+	keyed.Reset()
+	key, err := GenerateKey()
+	assert.Nil(t, err)
+	assert.Nil(t, keyed.AddKey(key))
+	assert.EqualError(t, a.Intersect(keyed), "cannot combine bloom filters with different keys")
+}
+
 func TestTrillionBitBloom(t *testing.T) {
 	m := 125000000000
 	b, err := NewBigBloomFromCap(m, 100000)
@@ -296,3 +653,231 @@ func BenchmarkBigBloomExistsStr(b *testing.B) {
 		})
 	}
 }
+
+// insert for increasing bloom filter len. Hashing takes most of the time, so this is close to its baseline
+// and BenchmarkBigBloomBitIndexing shows the difference the words make
+func BenchmarkBigBloomPutBytes(b *testing.B) {
+	for i := 4096; i <= 1<<20; i *= 16 {
+		bloom, err := NewBigBloomFromK(i, 3)
+		assert.Nil(b, err)
+		entries := benchmarkEntries(1000)
+		b.Run(fmt.Sprintf("len_%d_bytes", i), func(b *testing.B) {
+			for j := 0; j < b.N; j++ {
+				bloom.Reset()
+				for _, entry := range entries {
+					bloom.PutBytes(entry)
+				}
+			}
+		})
+	}
+}
+
+// baseline for BenchmarkBigBloomPutBytes: bits set a byte at a time, as before bits were stored in words
+func BenchmarkBigBloomPutBytesBytes(b *testing.B) {
+	for i := 4096; i <= 1<<20; i *= 16 {
+		bs := make([]byte, i)
+		entries := benchmarkEntries(1000)
+		b.Run(fmt.Sprintf("len_%d_bytes", i), func(b *testing.B) {
+			for j := 0; j < b.N; j++ {
+				for k := range bs {
+					bs[k] = 0
+				}
+				for _, entry := range entries {
+					if !existsBytesByteIndexed(bs, entry, 3) {
+						putBytesByteIndexed(bs, entry, 3)
+					}
+				}
+			}
+		})
+	}
+}
+
+// exists for increasing bloom filter len. Like BenchmarkBigBloomPutBytes, hashing takes most of the time
+func BenchmarkBigBloomExistsBytes(b *testing.B) {
+	for i := 4096; i <= 1<<20; i *= 16 {
+		bloom, err := NewBigBloomFromK(i, 3)
+		assert.Nil(b, err)
+		entries := benchmarkEntries(1000)
+		for _, entry := range entries {
+			bloom.PutBytes(entry)
+		}
+		b.Run(fmt.Sprintf("len_%d_bytes", i), func(b *testing.B) {
+			for j := 0; j < b.N; j++ {
+				for _, entry := range entries {
+					bloom.ExistsBytes(entry)
+				}
+			}
+		})
+	}
+}
+
+// baseline for BenchmarkBigBloomExistsBytes: bits read a byte at a time, as before bits were stored in words
+func BenchmarkBigBloomExistsBytesBytes(b *testing.B) {
+	for i := 4096; i <= 1<<20; i *= 16 {
+		bs := make([]byte, i)
+		entries := benchmarkEntries(1000)
+		for _, entry := range entries {
+			putBytesByteIndexed(bs, entry, 3)
+		}
+		b.Run(fmt.Sprintf("len_%d_bytes", i), func(b *testing.B) {
+			for j := 0; j < b.N; j++ {
+				for _, entry := range entries {
+					existsBytesByteIndexed(bs, entry, 3)
+				}
+			}
+		})
+	}
+}
+
+// setting and checking bits at known indexes, which leaves out hashing, for increasing bloom filter len
+func BenchmarkBigBloomBitIndexing(b *testing.B) {
+	for i := 4096; i <= 1<<20; i *= 16 {
+		bloom, err := NewBigBloomFromK(i, 3)
+		assert.Nil(b, err)
+		bitIs := benchmarkBitIndexes(i)
+		b.Run(fmt.Sprintf("len_%d_bytes", i), func(b *testing.B) {
+			for j := 0; j < b.N; j++ {
+				for _, bitI := range bitIs {
+					bloom.words[bitI>>6] |= 1 << (bitI & 63)
+				}
+				for _, bitI := range bitIs {
+					_ = bloom.words[bitI>>6]&(1<<(bitI&63)) == 0
+				}
+			}
+		})
+	}
+}
+
+// baseline for BenchmarkBigBloomBitIndexing: byte index from a float division, as before bits were stored in words
+func BenchmarkBigBloomBitIndexingBytes(b *testing.B) {
+	for i := 4096; i <= 1<<20; i *= 16 {
+		bs := make([]byte, i)
+		bitIs := benchmarkBitIndexes(i)
+		b.Run(fmt.Sprintf("len_%d_bytes", i), func(b *testing.B) {
+			for j := 0; j < b.N; j++ {
+				for _, bitI := range bitIs {
+					byteI := int(math.Floor(float64(bitI) / float64(8)))
+					bs[byteI] = bs[byteI] | byte(1<<(bitI%8))
+				}
+				for _, bitI := range bitIs {
+					byteI := int(math.Floor(float64(bitI) / float64(8)))
+					bitFlip := byte(1 << (bitI % 8))
+					_ = bs[byteI] != bs[byteI]|bitFlip
+				}
+			}
+		})
+	}
+}
+
+// union of two filters for increasing bloom filter len
+func BenchmarkBigBloomUnion(b *testing.B) {
+	for i := 4096; i <= 1<<20; i *= 16 {
+		x, y := benchmarkFilledBigBlooms(b, i)
+		b.Run(fmt.Sprintf("len_%d_bytes", i), func(b *testing.B) {
+			for j := 0; j < b.N; j++ {
+				x.Union(y)
+			}
+		})
+	}
+}
+
+// baseline for BenchmarkBigBloomUnion: byte at a time, as before bits were stored in words
+func BenchmarkBigBloomUnionBytes(b *testing.B) {
+	for i := 4096; i <= 1<<20; i *= 16 {
+		x, y := benchmarkFilledBigBlooms(b, i)
+		xs, ys := x.Bytes(), y.Bytes()
+		b.Run(fmt.Sprintf("len_%d_bytes", i), func(b *testing.B) {
+			for j := 0; j < b.N; j++ {
+				for k := range xs {
+					xs[k] |= ys[k]
+				}
+			}
+		})
+	}
+}
+
+// count of set bits for increasing bloom filter len
+func BenchmarkBigBloomFillRatio(b *testing.B) {
+	for i := 4096; i <= 1<<20; i *= 16 {
+		x, _ := benchmarkFilledBigBlooms(b, i)
+		b.Run(fmt.Sprintf("len_%d_bytes", i), func(b *testing.B) {
+			for j := 0; j < b.N; j++ {
+				x.FillRatio()
+			}
+		})
+	}
+}
+
+// baseline for BenchmarkBigBloomFillRatio: byte at a time, as before bits were stored in words
+func BenchmarkBigBloomFillRatioBytes(b *testing.B) {
+	for i := 4096; i <= 1<<20; i *= 16 {
+		x, _ := benchmarkFilledBigBlooms(b, i)
+		xs := x.Bytes()
+		b.Run(fmt.Sprintf("len_%d_bytes", i), func(b *testing.B) {
+			for j := 0; j < b.N; j++ {
+				ones := 0
+				for _, byt := range xs {
+					ones += bits.OnesCount8(byt)
+				}
+				_ = float64(ones) / float64(8*len(xs))
+			}
+		})
+	}
+}
+
+// n distinct entries
+func benchmarkEntries(n int) [][]byte {
+	entries := make([][]byte, n)
+	for i := range entries {
+		entries[i] = []byte(strconv.Itoa(i))
+	}
+	return entries
+}
+
+// bit indexes of 1000 entries in a len-byte filter with k 3
+func benchmarkBitIndexes(len int) []uint64 {
+	var bitIs []uint64
+	for _, entry := range benchmarkEntries(1000) {
+		for i := 0; i < 3; i++ {
+			bitIs = append(bitIs, hashIndex(entry, i, uint64(8*len)))
+		}
+	}
+	return bitIs
+}
+
+// sets the bits of entry in bs the way BigBloom did before bits were stored in words
+func putBytesByteIndexed(bs, entry []byte, k int) {
+	totBits := len(bs) * 8
+	for i := 0; i < k; i++ {
+		bitI := hashIndex(entry, i, uint64(totBits))
+		byteI := int(math.Floor(float64(bitI) / float64(8)))
+		bs[byteI] = bs[byteI] | byte(1<<(bitI%8))
+	}
+}
+
+// checks the bits of entry in bs the way BigBloom did before bits were stored in words
+func existsBytesByteIndexed(bs, entry []byte, k int) bool {
+	totBits := len(bs) * 8
+	for i := 0; i < k; i++ {
+		bitI := hashIndex(entry, i, uint64(totBits))
+		byteI := int(math.Floor(float64(bitI) / float64(8)))
+		bitFlip := byte(1 << (bitI % 8))
+		if bs[byteI] != bs[byteI]|bitFlip {
+			return false
+		}
+	}
+	return true
+}
+
+// two len-byte filters with 1000 entries each
+func benchmarkFilledBigBlooms(b *testing.B, len int) (*BigBloom, *BigBloom) {
+	x, err := NewBigBloomFromK(len, 3)
+	assert.Nil(b, err)
+	y, err := NewBigBloomFromK(len, 3)
+	assert.Nil(b, err)
+	for j := 0; j < 1000; j++ {
+		x.PutStr("x" + strconv.Itoa(j))
+		y.PutStr("y" + strconv.Itoa(j))
+	}
+	return x, y
+}
@@ -4,7 +4,6 @@ import (
 	"crypto/sha256"
 	"encoding/binary"
 	"encoding/hex"
-	"errors"
 	"fmt"
 	"math"
 	"strings"
@@ -27,8 +26,43 @@ type Bloomer interface {
 	AddCapacityConstraint(int) error
 }
 
+// MembershipFilter is the method set shared by BigBloom and QuotientFilter, where F is the filter type itself
+type MembershipFilter[F any] interface {
+	// put in filter: returns the filter and an error if a constraint is violated
+	PutStr(string) (F, error)
+	PutBytes([]byte) (F, error)
+
+	// checks for existance: returns true if exists and float64 for false positive rate
+	ExistsStr(string) (bool, float64)
+	ExistsBytes([]byte) (bool, float64)
+
+	// checks accuracy: returns current false positive rate. returns -1 if accuracy cannot be calculated
+	Accuracy() float64
+
+	// add constraints to filter
+	AddAccuracyConstraint(float64) error
+	AddCapacityConstraint(int) error
+
+	// clear, copy and compare filters
+	Reset()
+	Clone() F
+
+	// Equal reports whether two filters answer every membership or estimate query the same.
+	// The number of entries, constraints and other settings that do not change answers are not compared.
+	// The Equal methods of all filters in this package follow this rule.
+	Equal(F) bool
+
+	Hex() string
+	String() string
+}
+
 const BLOOM_LEN = 64
 
+// largest number of hash functions of any filter. Unkeyed hashing appends the index of the hash function to the element as one byte,
+// so more hash functions would repeat earlier ones. Keyed filters have the same limit, so adding a key never makes a k invalid
+// and decoding an untrusted encoding hashes at most BLOOM_MAX_K times per check.
+const BLOOM_MAX_K = 255
+
 // Bloom type is a 512-bit bloom filter that uses SHA256 hashing with a nonce.
 type Bloom struct {
 	// current number of unique entries.
@@ -53,30 +87,14 @@ type Bloom struct {
 	isLoaded bool
 }
 
-type CapacityError struct {
-	cap int
-}
-
-func (e *CapacityError) Error() string {
-	return fmt.Sprintf("failed to add entry: bloom filter at max capacity %d", e.cap)
-}
-
-type AccuracyError struct {
-	acc float64
-}
-
-func (e *AccuracyError) Error() string {
-	return fmt.Sprintf("failed to add entry: bloom filter constrained by max false positive rate %f", e.acc)
-}
-
 //
 // Bloom type constructors
 //
 
 // Constructs len-byte bloom filter from k.
 func NewBloomFromK(k int) (*Bloom, error) {
-	if k < 1 {
-		return nil, errors.New("k cannot be less than 1")
+	if err := checkK(k); err != nil {
+		return nil, err
 	}
 	return &Bloom{
 		n:                    0,
@@ -91,7 +109,7 @@ func NewBloomFromK(k int) (*Bloom, error) {
 // Constructs len-byte bloom filter from capacity
 func NewBloomFromCap(cap int) (*Bloom, error) {
 	if cap < 1 {
-		return nil, errors.New("capacity cannot be less than 1")
+		return nil, newParameterError("cap", cap, "capacity cannot be less than 1")
 	}
 	return &Bloom{
 		n:                    0,
@@ -106,7 +124,7 @@ func NewBloomFromCap(cap int) (*Bloom, error) {
 // Constructs len-byte bloom filter from maxFalsePositiveRate
 func NewBloomFromAcc(maxFalsePositiveRate float64) (*Bloom, error) {
 	if maxFalsePositiveRate <= 0 || maxFalsePositiveRate >= 1 {
-		return nil, errors.New("false positive rate must be between 0 and 1")
+		return nil, newParameterError("maxFalsePositiveRate", maxFalsePositiveRate, "false positive rate must be between 0 and 1")
 	}
 	return &Bloom{
 		n:                    0,
@@ -122,8 +140,8 @@ func NewBloomFromAcc(maxFalsePositiveRate float64) (*Bloom, error) {
 // This is useful for loading in a Bloom filter over the wire.
 // This mechanism will disable accuracy calculations because n is unknown
 func NewBloomFromBytes(bs [BLOOM_LEN]byte, k int) (*Bloom, error) {
-	if k < 1 {
-		return nil, errors.New("k cannot be less than 1")
+	if err := checkK(k); err != nil {
+		return nil, err
 	}
 	return &Bloom{
 		n:                    0,
@@ -154,19 +172,18 @@ func (b *Bloom) PutBytes(bs []byte) (*Bloom, error) {
 	}
 
 	if b.cap != nil && b.n == *b.cap {
-		return b, &CapacityError{cap: *b.cap}
+		return b, &CapacityError{Cap: *b.cap, N: b.n}
 	}
 
 	if b.maxFalsePositiveRate != nil {
-		if falsePositiveRate(b.len, b.n+1, b.k) > *b.maxFalsePositiveRate {
-			return b, &AccuracyError{acc: *b.maxFalsePositiveRate}
+		if projected := falsePositiveRate(b.len, b.n+1, b.k); projected > *b.maxFalsePositiveRate {
+			return b, &AccuracyError{MaxFalsePositiveRate: *b.maxFalsePositiveRate, N: b.n, FalsePositiveRate: projected}
 		}
 	}
 
 	for i := 0; i < b.k; i++ {
 		// a single change in bs makes the whole SHA hash change, so an appended nonce is suitable
-		bsNonce := append(bs, byte(i))
-		var h [32]byte = sha256.Sum256(bsNonce)
+		var h [32]byte = nonceHash(bs, i)
 		// get a random uint64 number
 		bytes := h[0:8]
 		// find index of bit
@@ -194,8 +211,7 @@ func (b *Bloom) ExistsStr(s string) (bool, float64) {
 func (b *Bloom) ExistsBytes(bs []byte) (bool, float64) {
 	for i := 0; i < b.k; i++ {
 		// a single change in bs makes the whole SHA hash change, so an appended nonce is suitable
-		bsNonce := append(bs, byte(i))
-		var h [32]byte = sha256.Sum256(bsNonce)
+		var h [32]byte = nonceHash(bs, i)
 		// two bytes is more than enough to cover 512 possibilities
 		bytes := h[0:8]
 		// find index of bit
@@ -228,15 +244,15 @@ func (b *Bloom) Accuracy() float64 {
 // Constrains bloom from not adding more than cap insertions
 func (b *Bloom) AddCapacityConstraint(cap int) error {
 	if b.isLoaded {
-		return errors.New("cannot add constraints to loaded bloom filters")
+		return ErrLoaded
 	}
 	if cap < 1 {
-		return errors.New("capacity cannot be less than 1")
+		return newParameterError("cap", cap, "capacity cannot be less than 1")
 	}
 	if b.maxFalsePositiveRate != nil {
 		// check if contraints capacity and maxFalsePositiveRate are compatible together with this size bloom filter
 		if !constraintsCompatible(b.len, cap, b.k, *b.maxFalsePositiveRate) {
-			return errors.New("false positive rate will be higher at full capacity than the maxFalsePositiveRate provided")
+			return &ConstraintError{Cap: cap, MaxFalsePositiveRate: *b.maxFalsePositiveRate, FalsePositiveRate: falsePositiveRate(b.len, cap, b.k)}
 		}
 	}
 	b.cap = &cap
@@ -246,21 +262,47 @@ func (b *Bloom) AddCapacityConstraint(cap int) error {
 // Constrains bloom from not adding insertions that would cause accuracy to be worse than maxFalsePositiveRate
 func (b *Bloom) AddAccuracyConstraint(maxFalsePositiveRate float64) error {
 	if b.isLoaded {
-		return errors.New("cannot add constraints to loaded bloom filters")
+		return ErrLoaded
 	}
 	if maxFalsePositiveRate <= 0 || maxFalsePositiveRate >= 1 {
-		return errors.New("false positive rate must be between 0 and 1")
+		return newParameterError("maxFalsePositiveRate", maxFalsePositiveRate, "false positive rate must be between 0 and 1")
 	}
 	if b.cap != nil {
 		// check if contraints capacity and maxFalsePositiveRate are compatible together with this size bloom filter
 		if !constraintsCompatible(b.len, *b.cap, b.k, maxFalsePositiveRate) {
-			return errors.New("false positive rate will be higher at full capacity than the maxFalsePositiveRate provided")
+			return &ConstraintError{Cap: *b.cap, MaxFalsePositiveRate: maxFalsePositiveRate, FalsePositiveRate: falsePositiveRate(b.len, *b.cap, b.k)}
 		}
 	}
 	b.maxFalsePositiveRate = &maxFalsePositiveRate
 	return nil
 }
 
+// Removes all entries, keeping k and the constraints. A filter loaded from bytes is empty after a reset, so its accuracy is calculated again.
+func (b *Bloom) Reset() {
+	b.bs = [BLOOM_LEN]byte{}
+	b.n = 0
+	b.isLoaded = false
+}
+
+// Deep copies bloom filter, including its constraints
+func (b *Bloom) Clone() *Bloom {
+	cap, maxFalsePositiveRate := cloneConstraints(b.cap, b.maxFalsePositiveRate)
+	return &Bloom{
+		n:                    b.n,
+		k:                    b.k,
+		bs:                   b.bs,
+		len:                  b.len,
+		maxFalsePositiveRate: maxFalsePositiveRate,
+		cap:                  cap,
+		isLoaded:             b.isLoaded,
+	}
+}
+
+// Checks whether b and other answer every query the same
+func (b *Bloom) Equal(other *Bloom) bool {
+	return b.k == other.k && b.bs == other.bs
+}
+
 func (b *Bloom) String() string {
 	var buf strings.Builder
 
@@ -283,10 +325,72 @@ func (b *Bloom) Hex() string {
 	return hex.EncodeToString(b.bs[:])
 }
 
+// Get bytes of bloom filter. Can be loaded back in with NewBloomFromBytes
+func (b *Bloom) Bytes() [BLOOM_LEN]byte {
+	return b.bs
+}
+
 //
 // helpers
 //
 
+// checks that k is a valid number of hash functions
+func checkK(k int) error {
+	if k < 1 {
+		return newParameterError("k", k, "k cannot be less than 1")
+	}
+	if k > BLOOM_MAX_K {
+		return newParameterError("k", k, fmt.Sprintf("k cannot be more than %d", BLOOM_MAX_K))
+	}
+	return nil
+}
+
+// calculate index of hash function i for bs in a filter with m slots
+func hashIndex(bs []byte, i int, m uint64) uint64 {
+	sum := nonceHash(bs, i)
+	return binary.BigEndian.Uint64(sum[0:8]) % m
+}
+
+// calculate SHA256 hash of bs with nonce i appended
+// the nonce is hashed after bs without appending to it, so the caller's backing array is never written to
+func nonceHash(bs []byte, i int) [sha256.Size]byte {
+	h := sha256.New()
+	h.Write(bs)
+	h.Write([]byte{byte(i)})
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+	return sum
+}
+
+// calculate index of hash function i for bs in a filter with m slots using SipHash-2-4.
+// Every hash function is keyed with the second half of key xored with i, so the functions are independent
+func keyedHashIndex(key *[BLOOM_KEY_LEN]byte, bs []byte, i int, m uint64) uint64 {
+	k0 := binary.LittleEndian.Uint64(key[0:8])
+	k1 := binary.LittleEndian.Uint64(key[8:16]) ^ uint64(i)
+	return siphash24(k0, k1, bs) % m
+}
+
+// calculate 64-bit hash of bs from the first 8 bytes of its SHA256 hash
+func keyHash(bs []byte) uint64 {
+	h := sha256.Sum256(bs)
+	return binary.BigEndian.Uint64(h[0:8])
+}
+
+// copy constraint pointers so that a clone's constraints are not shared
+func cloneConstraints(cap *int, maxFalsePositiveRate *float64) (*int, *float64) {
+	var capCopy *int
+	if cap != nil {
+		c := *cap
+		capCopy = &c
+	}
+	var maxFalsePositiveRateCopy *float64
+	if maxFalsePositiveRate != nil {
+		p := *maxFalsePositiveRate
+		maxFalsePositiveRateCopy = &p
+	}
+	return capCopy, maxFalsePositiveRateCopy
+}
+
 // calculate false positive rate
 func falsePositiveRate(len, n, k int) float64 {
 	// equation: 1-((1 - (1/m))^nk)^k where m is bits, n is unique entries, and k is number of hashes
@@ -305,6 +409,23 @@ func constraintsCompatible(len, cap, k int, allowedMaxFalsePositiveRate float64)
 	return calcMaxFalsePositiveRate <= allowedMaxFalsePositiveRate
 }
 
+// calculate len of filter in bytes from capacity and accuracy
+func calcLenFromCapAcc(cap int, acc float64) int {
+	// math:
+	// eq1: k = ln(2) * m/n
+	// eq2: acc = (1 - (1 - e^(-kn/m))^k
+	// substitute k from eq1 into eq2 ...
+	// acc = (.5)^(ln(2) * m/n)
+	// log0.5(acc) = ln(2) * m/n
+	// m = (n * log0.5(acc))/ln(2)
+	// change of base ...
+	// m = (n * ln(acc)) / (ln(0.5) * ln(2))
+	numerator := float64(cap) * math.Log(acc)
+	denom := math.Log(.5) * math.Log(2)
+	mFloat := numerator / denom
+	return int(math.Ceil(mFloat / 8))
+}
+
 // calculate k from len of filter and capacity
 func calcKFromCap(len, n int) int {
 	m := len * 8
@@ -314,6 +435,9 @@ func calcKFromCap(len, n int) int {
 	var k int
 	if kFloat < 1 {
 		k = 1
+	} else if kFloat > BLOOM_MAX_K {
+		// a large filter for few entries could use more hash functions, but they would not lower the false positive rate much
+		k = BLOOM_MAX_K
 	} else {
 		k = int(math.Round(kFloat))
 	}
@@ -344,6 +468,8 @@ func calcKFromAcc(len int, acc float64) int {
 	if kFloat < 1 {
 		// k can't be less than 1
 		k = 1
+	} else if kFloat > BLOOM_MAX_K {
+		k = BLOOM_MAX_K
 	} else {
 		// k must be an int
 		k = int(math.Round(kFloat))
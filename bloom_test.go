@@ -180,6 +180,56 @@ func TestBloomAccuracy(t *testing.T) {
 	// rest of accuracy tested in TestFalsePositiveRate
 }
 
+func TestBloomResetCloneEqual(t *testing.T) {
+	b, err := NewBloomFromK(testk)
+	assert.Nil(t, err)
+	err = b.AddCapacityConstraint(10)
+	assert.Nil(t, err)
+	err = b.AddAccuracyConstraint(.5)
+	assert.Nil(t, err)
+	_, err = b.PutStr("a")
+	assert.Nil(t, err)
+
+	// clone is equal but does not share bits or constraints
+	c := b.Clone()
+	assert.True(t, b.Equal(c))
+	assert.Equal(t, b, c)
+	assert.NotSame(t, b.cap, c.cap)
+	assert.NotSame(t, b.maxFalsePositiveRate, c.maxFalsePositiveRate)
+	_, err = c.PutStr("b")
+	assert.Nil(t, err)
+	assert.False(t, b.Equal(c))
+	exists, _ := b.ExistsStr("b")
+	assert.False(t, exists)
+
+	// reset removes entries but keeps k and constraints
+	c.Reset()
+	assert.Equal(t, 0, c.n)
+	assert.Equal(t, [BLOOM_LEN]byte{}, c.Bytes())
+	assert.Equal(t, 10, *c.cap)
+	assert.Equal(t, .5, *c.maxFalsePositiveRate)
+	empty, err := NewBloomFromK(c.k)
+	assert.Nil(t, err)
+	assert.True(t, c.Equal(empty))
+
+	// filters loaded from bytes count entries again after a reset
+	loaded, err := NewBloomFromBytes(b.Bytes(), b.k)
+	assert.Nil(t, err)
+	assert.True(t, loaded.Equal(b))
+	loaded.Reset()
+	_, err = loaded.PutStr("a")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, loaded.n)
+	assert.False(t, loaded.isLoaded)
+	assert.True(t, loaded.Equal(b))
+
+	// different k
+	other, err := NewBloomFromK(b.k + 1)
+	assert.Nil(t, err)
+	b.Reset()
+	assert.False(t, b.Equal(other))
+}
+
 func TestFalsePositiveRate(t *testing.T) {
 	// wolfram alpha: https://www.wolframalpha.com/input?i2d=true&i=Power%5B%5C%2840%291-Power%5B%5C%2840%291%E2%88%92%5C%2840%29Divide%5B1%2C256%5D%5C%2841%29%5C%2841%29%2C3%5D%5C%2841%29%2C3%5D
 
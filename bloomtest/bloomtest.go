@@ -0,0 +1,204 @@
+// Package bloomtest measures the false positive rate of filters empirically and checks it against the rate they promise.
+// It inserts n random keys into a filter, probes it with keys that were never inserted, and compares the observed rate
+// with the filter's Accuracy() using a Wilson score confidence interval.
+package bloomtest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// Filter is the filter under test
+type Filter interface {
+	// inserts bs
+	Put(bs []byte) error
+
+	// checks for existance of bs
+	Exists(bs []byte) bool
+
+	// promised false positive rate
+	Accuracy() float64
+}
+
+// Result is the outcome of measuring a filter
+type Result struct {
+	// number of keys inserted
+	N int
+
+	// number of keys probed that were never inserted
+	Probes int
+
+	// number of probes the filter reported as present
+	FalsePositives int
+
+	// number of inserted keys the filter reported as missing. Always 0 for bloom filters
+	FalseNegatives int
+
+	// observed false positive rate: FalsePositives / Probes
+	Observed float64
+
+	// false positive rate promised by the filter after inserting the keys
+	Expected float64
+
+	// confidence of the interval, for example 0.999
+	Confidence float64
+
+	// bounds of the confidence interval of the observed rate
+	Lower float64
+	Upper float64
+}
+
+// filter with the PutBytes, ExistsBytes and Accuracy methods of the filters in package bloom
+type adapter[F any] struct {
+	put      func([]byte) (F, error)
+	exists   func([]byte) (bool, float64)
+	accuracy func() float64
+}
+
+//
+// Constructors
+//
+
+// Adapts the methods of a filter from package bloom to Filter, for example
+//
+//	b, _ := bloom.NewBigBloomAlloc(1000, .01)
+//	f := bloomtest.Adapt(b.PutBytes, b.ExistsBytes, b.Accuracy)
+func Adapt[F any](put func([]byte) (F, error), exists func([]byte) (bool, float64), accuracy func() float64) Filter {
+	return &adapter[F]{
+		put:      put,
+		exists:   exists,
+		accuracy: accuracy,
+	}
+}
+
+//
+// Functions
+//
+
+// Inserts n random keys into f, checks every one of them and probes f with probes keys that were never inserted.
+// confidence is the confidence of the interval around the observed rate, between 0 and 1.
+// seed seeds the keys so a measurement can be repeated.
+func Measure(f Filter, n, probes int, confidence float64, seed int64) (*Result, error) {
+	if n < 0 {
+		return nil, errors.New("number of keys cannot be negative")
+	}
+	if probes < 1 {
+		return nil, errors.New("number of probes cannot be less than 1")
+	}
+	if confidence <= 0 || confidence >= 1 {
+		return nil, errors.New("confidence must be between 0 and 1")
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	keys := make([][]byte, n)
+	for i := range keys {
+		keys[i] = randomKey(rng, insertedKey)
+		if err := f.Put(keys[i]); err != nil {
+			return nil, fmt.Errorf("failed to insert key %d of %d: %w", i+1, n, err)
+		}
+	}
+	falseNegatives := 0
+	for _, key := range keys {
+		if !f.Exists(key) {
+			falseNegatives++
+		}
+	}
+	falsePositives := 0
+	for i := 0; i < probes; i++ {
+		if f.Exists(randomKey(rng, probeKey)) {
+			falsePositives++
+		}
+	}
+
+	lower, upper := wilsonInterval(falsePositives, probes, confidence)
+	return &Result{
+		N:              n,
+		Probes:         probes,
+		FalsePositives: falsePositives,
+		FalseNegatives: falseNegatives,
+		Observed:       float64(falsePositives) / float64(probes),
+		Expected:       f.Accuracy(),
+		Confidence:     confidence,
+		Lower:          lower,
+		Upper:          upper,
+	}, nil
+}
+
+// Measures f like Measure and fails t if the measurement fails, a key is missing,
+// or the promised rate is outside the confidence interval of the observed rate.
+func Check(t testing.TB, f Filter, n, probes int, confidence float64, seed int64) *Result {
+	t.Helper()
+	r, err := Measure(f, n, probes, confidence, seed)
+	if err != nil {
+		t.Fatal(err)
+		return nil
+	}
+	if r.FalseNegatives > 0 {
+		t.Errorf("%d of %d inserted keys are missing", r.FalseNegatives, r.N)
+	}
+	if !r.Consistent() {
+		t.Errorf("promised false positive rate is outside the confidence interval: %s", r)
+	}
+	return r
+}
+
+//
+// Methods
+//
+
+// Checks whether the promised rate is within the confidence interval of the observed rate
+func (r *Result) Consistent() bool {
+	return r.Lower <= r.Expected && r.Expected <= r.Upper
+}
+
+func (r *Result) String() string {
+	return fmt.Sprintf("%d keys, %d/%d false positives: observed %f, expected %f, %g%% interval [%f, %f]", r.N, r.FalsePositives, r.Probes, r.Observed, r.Expected, 100*r.Confidence, r.Lower, r.Upper)
+}
+
+func (a *adapter[F]) Put(bs []byte) error {
+	_, err := a.put(bs)
+	return err
+}
+
+func (a *adapter[F]) Exists(bs []byte) bool {
+	exists, _ := a.exists(bs)
+	return exists
+}
+
+func (a *adapter[F]) Accuracy() float64 {
+	return a.accuracy()
+}
+
+//
+// helpers
+//
+
+// first byte of keys, which keeps inserted keys and probes disjoint
+const (
+	insertedKey byte = iota
+	probeKey
+)
+
+// number of random bytes in a key
+const keyLen = 16
+
+// random key starting with prefix
+func randomKey(rng *rand.Rand, prefix byte) []byte {
+	key := make([]byte, 1+keyLen)
+	key[0] = prefix
+	rng.Read(key[1:])
+	return key
+}
+
+// calculate Wilson score interval of a binomial proportion of successes out of trials
+func wilsonInterval(successes, trials int, confidence float64) (float64, float64) {
+	z := math.Sqrt2 * math.Erfinv(confidence)
+	n := float64(trials)
+	p := float64(successes) / n
+	center := (p + z*z/(2*n)) / (1 + z*z/n)
+	halfWidth := z / (1 + z*z/n) * math.Sqrt(p*(1-p)/n+z*z/(4*n*n))
+	return math.Max(0, center-halfWidth), math.Min(1, center+halfWidth)
+}
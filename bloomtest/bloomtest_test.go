@@ -0,0 +1,141 @@
+package bloomtest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nettijoe96/bloom"
+	"github.com/stretchr/testify/assert"
+)
+
+// confidence of the suite. High, so that the fixed seeds are not the only thing keeping the suite green
+const testConfidence = .999
+
+func TestMeasure(t *testing.T) {
+	b, err := bloom.NewBigBloomAlloc(100, .01)
+	assert.Nil(t, err)
+	f := Adapt(b.PutBytes, b.ExistsBytes, b.Accuracy)
+
+	// test invalid parameters
+	_, err = Measure(f, -1, 100, .99, 1)
+	assert.EqualError(t, err, "number of keys cannot be negative")
+	_, err = Measure(f, 10, 0, .99, 1)
+	assert.EqualError(t, err, "number of probes cannot be less than 1")
+	_, err = Measure(f, 10, 100, 1, 1)
+	assert.EqualError(t, err, "confidence must be between 0 and 1")
+
+	// insert errors are returned
+	_, err = Measure(f, 101, 100, .99, 1)
+	assert.True(t, errors.Is(err, bloom.ErrCapacity) || errors.Is(err, bloom.ErrAccuracy))
+}
+
+func TestMeasureSeeded(t *testing.T) {
+	measure := func() *Result {
+		b, err := bloom.NewBigBloomAlloc(1000, .05)
+		assert.Nil(t, err)
+		r, err := Measure(Adapt(b.PutBytes, b.ExistsBytes, b.Accuracy), 1000, 10000, .99, 42)
+		assert.Nil(t, err)
+		return r
+	}
+	r := measure()
+	assert.Equal(t, r, measure())
+	assert.Equal(t, 1000, r.N)
+	assert.Equal(t, 10000, r.Probes)
+	assert.Equal(t, 0, r.FalseNegatives)
+	assert.Equal(t, float64(r.FalsePositives)/10000, r.Observed)
+	assert.LessOrEqual(t, r.Lower, r.Observed)
+	assert.GreaterOrEqual(t, r.Upper, r.Observed)
+}
+
+func TestWilsonInterval(t *testing.T) {
+	// 95% interval of 10 successes out of 100
+	lower, upper := wilsonInterval(10, 100, .95)
+	assert.InDelta(t, .0552, lower, .0001)
+	assert.InDelta(t, .1744, upper, .0001)
+
+	// no successes still give an upper bound above 0
+	lower, upper = wilsonInterval(0, 1000, .95)
+	assert.Equal(t, float64(0), lower)
+	assert.InDelta(t, .0038, upper, .0001)
+
+	// a wider interval for more confidence
+	lower99, upper99 := wilsonInterval(10, 100, .99)
+	assert.Less(t, lower99, .0552)
+	assert.Greater(t, upper99, .1744)
+}
+
+func TestResultConsistent(t *testing.T) {
+	r := &Result{Expected: .01, Lower: .009, Upper: .012}
+	assert.True(t, r.Consistent())
+	r.Expected = .013
+	assert.False(t, r.Consistent())
+	r.Expected = .008
+	assert.False(t, r.Consistent())
+}
+
+// the filters in package bloom deliver the false positive rate they promise
+func TestBigBloomFalsePositiveRate(t *testing.T) {
+	tests := []struct {
+		cap int
+		acc float64
+	}{
+		{cap: 10000, acc: .1},
+		{cap: 10000, acc: .01},
+		{cap: 2000, acc: .05},
+	}
+	for _, test := range tests {
+		b, err := bloom.NewBigBloomAlloc(test.cap, test.acc)
+		assert.Nil(t, err)
+		r := Check(t, Adapt(b.PutBytes, b.ExistsBytes, b.Accuracy), test.cap/2, 100000, testConfidence, 1)
+		assert.LessOrEqual(t, r.Expected, test.acc)
+	}
+
+	// keyed hashing
+	b, err := bloom.NewBigBloomAlloc(10000, .01)
+	assert.Nil(t, err)
+	key := [bloom.BLOOM_KEY_LEN]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	assert.Nil(t, b.AddKey(key))
+	Check(t, Adapt(b.PutBytes, b.ExistsBytes, b.Accuracy), 10000, 100000, testConfidence, 2)
+}
+
+func TestBloomFalsePositiveRate(t *testing.T) {
+	for _, k := range []int{1, 3, 5} {
+		b, err := bloom.NewBloomFromK(k)
+		assert.Nil(t, err)
+		Check(t, Adapt(b.PutBytes, b.ExistsBytes, b.Accuracy), 20, 100000, testConfidence, int64(k))
+	}
+}
+
+// Accuracy is calculated from the number of unique entries, which does not count keys that were already false positives
+// when they were inserted. In a small filter near saturation enough keys are skipped that the promised rate is too low.
+func TestBloomFalsePositiveRateUndercounted(t *testing.T) {
+	b, err := bloom.NewBloomFromK(1)
+	assert.Nil(t, err)
+	r, err := Measure(Adapt(b.PutBytes, b.ExistsBytes, b.Accuracy), 50, 100000, testConfidence, 1)
+	assert.Nil(t, err)
+	assert.False(t, r.Consistent())
+	assert.Less(t, r.Expected, r.Lower)
+}
+
+func TestFixedBloomFalsePositiveRate(t *testing.T) {
+	small, err := bloom.NewFixedBloomFromCap[[256]byte](200)
+	assert.Nil(t, err)
+	Check(t, Adapt(small.PutBytes, small.ExistsBytes, small.Accuracy), 200, 100000, testConfidence, 3)
+
+	large, err := bloom.NewFixedBloomFromCap[[4096]byte](3000)
+	assert.Nil(t, err)
+	Check(t, Adapt(large.PutBytes, large.ExistsBytes, large.Accuracy), 3000, 100000, testConfidence, 4)
+}
+
+func TestQuotientFilterFalsePositiveRate(t *testing.T) {
+	f, err := bloom.NewQuotientFilter(12, 4)
+	assert.Nil(t, err)
+	Check(t, Adapt(f.PutBytes, f.ExistsBytes, f.Accuracy), 3000, 100000, testConfidence, 5)
+}
+
+func TestSaturatingBloomFalsePositiveRate(t *testing.T) {
+	s, err := bloom.NewSaturatingBloom(1000, .05, bloom.SaturationGrow)
+	assert.Nil(t, err)
+	r := Check(t, Adapt(s.PutBytes, s.ExistsBytes, s.Accuracy), 5000, 100000, testConfidence, 6)
+	assert.LessOrEqual(t, r.Expected, .05)
+}
@@ -0,0 +1,201 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Cascade is a CRLite-style cascade of bloom filters (Larisch et al., 2017) that encodes an exact split of a known universe of keys.
+// Level 0 holds the included keys, level 1 holds the excluded keys that are false positives of level 0,
+// level 2 holds the included keys that are false positives of level 1, and so on until a level has no false positives.
+// Contains never gives a wrong answer for keys of the universe the cascade was built from.
+// Keys outside of that universe get arbitrary answers.
+type Cascade struct {
+	// bloom filters of each level. Keys are salted with the index of their level
+	levels []*BigBloom
+}
+
+// limit on levels so the level count fits a byte. Construction is expected to end after a few dozen
+const maxCascadeLevels = 255
+
+// false positive rate of every level after the first
+const cascadeLevelFalsePositiveRate = 0.5
+
+//
+// Constructors
+//
+
+// Constructs cascade that contains every key of include and none of exclude. include and exclude must be disjoint.
+func NewCascade(include, exclude [][]byte) (*Cascade, error) {
+	included := make(map[string]bool, len(include))
+	for _, key := range include {
+		included[string(key)] = true
+	}
+	for _, key := range exclude {
+		if included[string(key)] {
+			return nil, newParameterError("exclude", key, "include and exclude sets must be disjoint")
+		}
+	}
+
+	c := &Cascade{
+		levels: nil,
+	}
+	insert, other := include, exclude
+	for len(insert) > 0 {
+		if len(c.levels) == maxCascadeLevels {
+			return nil, fmt.Errorf("cascade did not finish within %d levels", maxCascadeLevels)
+		}
+		level := len(c.levels)
+		b, err := NewBigBloomAlloc(len(insert), cascadeFalsePositiveRate(level, len(include), len(exclude)))
+		if err != nil {
+			return nil, err
+		}
+		// the cascade is exact however many false positives a level has, so constraints are not needed
+		b.cap = nil
+		b.maxFalsePositiveRate = nil
+		for _, key := range insert {
+			b.PutBytes(cascadeKey(level, key))
+		}
+
+		// keys of the other set that this level gets wrong must be encoded by the next level
+		var falsePositives [][]byte
+		for _, key := range other {
+			if exists, _ := b.ExistsBytes(cascadeKey(level, key)); exists {
+				falsePositives = append(falsePositives, key)
+			}
+		}
+		c.levels = append(c.levels, b)
+		insert, other = falsePositives, insert
+	}
+	return c, nil
+}
+
+//
+// Methods
+//
+
+// Checks for existance of key in cascade
+func (c *Cascade) Contains(key []byte) bool {
+	for i, b := range c.levels {
+		if exists, _ := b.ExistsBytes(cascadeKey(i, key)); !exists {
+			// even levels hold included keys, so missing from one means excluded
+			return i%2 == 1
+		}
+	}
+	// in every level, so in the set of the last level
+	return len(c.levels)%2 == 1
+}
+
+// Checks for existance of string key in cascade
+func (c *Cascade) ContainsStr(s string) bool {
+	return c.Contains([]byte(s))
+}
+
+// Number of levels
+func (c *Cascade) Levels() int {
+	return len(c.levels)
+}
+
+// Number of bytes used by the bloom filters of all levels
+func (c *Cascade) SizeBytes() int {
+	size := 0
+	for _, b := range c.levels {
+		size += b.len
+	}
+	return size
+}
+
+// Copies the cascade and the bloom filters of its levels. A cascade is built once from its universe, so it has no Reset
+func (c *Cascade) Clone() *Cascade {
+	levels := make([]*BigBloom, len(c.levels))
+	for i, b := range c.levels {
+		levels[i] = b.Clone()
+	}
+	return &Cascade{
+		levels: levels,
+	}
+}
+
+// Checks whether c and other answer every query the same: same levels
+func (c *Cascade) Equal(other *Cascade) bool {
+	if len(c.levels) != len(other.levels) {
+		return false
+	}
+	for i, b := range c.levels {
+		if !b.Equal(other.levels[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Encodes cascade as the number of levels followed by the k, length and bytes of each level
+func (c *Cascade) MarshalBinary() ([]byte, error) {
+	bs := make([]byte, 0, 1+5*len(c.levels)+c.SizeBytes())
+	bs = append(bs, byte(len(c.levels)))
+	for _, b := range c.levels {
+		bs = append(bs, byte(b.k))
+		bs = binary.BigEndian.AppendUint32(bs, uint32(b.len))
+		bs = append(bs, b.Bytes()...)
+	}
+	return bs, nil
+}
+
+// Decodes cascade encoded with MarshalBinary. Levels are loaded with NewBigBloomFromBytes.
+func (c *Cascade) UnmarshalBinary(bs []byte) error {
+	if len(bs) < 1 {
+		return newDecodeError("cascade", "cascade encoding too short")
+	}
+	numLevels := int(bs[0])
+	bs = bs[1:]
+	levels := make([]*BigBloom, 0, numLevels)
+	for i := 0; i < numLevels; i++ {
+		if len(bs) < 5 {
+			return newDecodeError("cascade", "cascade encoding too short")
+		}
+		k := int(bs[0])
+		length := int(binary.BigEndian.Uint32(bs[1:5]))
+		bs = bs[5:]
+		if len(bs) < length {
+			return newDecodeError("cascade", "cascade encoding too short")
+		}
+		b, err := NewBigBloomFromBytes(bs[:length:length], k)
+		if err != nil {
+			return err
+		}
+		levels = append(levels, b)
+		bs = bs[length:]
+	}
+	if len(bs) != 0 {
+		return newDecodeError("cascade", "cascade encoding has trailing bytes")
+	}
+	c.levels = levels
+	return nil
+}
+
+func (c *Cascade) String() string {
+	return fmt.Sprintf("bloom filter cascade: %d levels, %d bytes", len(c.levels), c.SizeBytes())
+}
+
+//
+// helpers
+//
+
+// calculate false positive rate of a level.
+// The first level uses |include|*sqrt(2)/|exclude| and the others 1/2, which minimizes the total size of the cascade as described in the CRLite paper
+func cascadeFalsePositiveRate(level, numInclude, numExclude int) float64 {
+	if level > 0 || numExclude == 0 {
+		return cascadeLevelFalsePositiveRate
+	}
+	return math.Min(cascadeLevelFalsePositiveRate, float64(numInclude)*math.Sqrt2/float64(numExclude))
+}
+
+// salts key with its level so that every level hashes keys independently.
+// key is copied so the caller's backing array is never written to
+func cascadeKey(level int, key []byte) []byte {
+	salted := make([]byte, 1+len(key))
+	salted[0] = byte(level)
+	copy(salted[1:], key)
+	return salted
+}
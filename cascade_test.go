@@ -0,0 +1,150 @@
+package bloom
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCascade(t *testing.T) {
+	// test overlapping sets
+	_, err := NewCascade(fuseTestKeys("key", 10), fuseTestKeys("key", 20))
+	assert.EqualError(t, err, "include and exclude sets must be disjoint")
+
+	type cascadeTest struct {
+		numInclude int
+		numExclude int
+	}
+
+	tests := []cascadeTest{
+		{numInclude: 0, numExclude: 100},
+		{numInclude: 100, numExclude: 0},
+		{numInclude: 1, numExclude: 1},
+		{numInclude: 1000, numExclude: 100000},
+		{numInclude: 10000, numExclude: 10000},
+	}
+
+	for _, test := range tests {
+		include := fuseTestKeys("revoked", test.numInclude)
+		exclude := fuseTestKeys("valid", test.numExclude)
+		c, err := NewCascade(include, exclude)
+		assert.Nil(t, err)
+		if test.numInclude == 0 {
+			assert.Equal(t, 0, c.Levels())
+		}
+		assert.Less(t, c.Levels(), 40)
+
+		// exact over the universe
+		for _, key := range include {
+			assert.True(t, c.Contains(key))
+		}
+		for _, key := range exclude {
+			assert.False(t, c.Contains(key))
+		}
+	}
+}
+
+func TestCascadeSize(t *testing.T) {
+	include := fuseTestKeys("revoked", 1000)
+	exclude := fuseTestKeys("valid", 100000)
+	c, err := NewCascade(include, exclude)
+	assert.Nil(t, err)
+
+	// levels after the first shrink by about half each time, so the first level dominates the size
+	first, err := NewBigBloomAlloc(1000, 1000*1.4142135623730951/100000)
+	assert.Nil(t, err)
+	assert.Greater(t, c.Levels(), 1)
+	assert.Less(t, c.SizeBytes(), 2*first.len)
+	assert.Equal(t, "bloom filter cascade: "+strconv.Itoa(c.Levels())+" levels, "+strconv.Itoa(c.SizeBytes())+" bytes", c.String())
+}
+
+func TestCascadeDoesNotModifyKeys(t *testing.T) {
+	// keys with spare capacity would be written to by an append
+	backing := make([]byte, 4, 8)
+	copy(backing, "key0")
+	include := [][]byte{backing}
+	c, err := NewCascade(include, fuseTestKeys("other", 100))
+	assert.Nil(t, err)
+	assert.True(t, c.Contains(backing))
+	assert.Equal(t, make([]byte, 4), backing[4:8])
+}
+
+func TestCascadeMarshalBinary(t *testing.T) {
+	include := fuseTestKeys("revoked", 500)
+	exclude := fuseTestKeys("valid", 5000)
+	c, err := NewCascade(include, exclude)
+	assert.Nil(t, err)
+
+	bs, err := c.MarshalBinary()
+	assert.Nil(t, err)
+	assert.Equal(t, 1+5*c.Levels()+c.SizeBytes(), len(bs))
+
+	var decoded Cascade
+	err = decoded.UnmarshalBinary(bs)
+	assert.Nil(t, err)
+	assert.Equal(t, c.Levels(), decoded.Levels())
+	for _, key := range include {
+		assert.True(t, decoded.Contains(key))
+	}
+	for _, key := range exclude {
+		assert.False(t, decoded.Contains(key))
+	}
+
+	// empty cascade
+	empty, err := NewCascade(nil, exclude)
+	assert.Nil(t, err)
+	emptyBs, err := empty.MarshalBinary()
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0}, emptyBs)
+
+	// test invalid encodings
+	err = decoded.UnmarshalBinary(nil)
+	assert.EqualError(t, err, "cascade encoding too short")
+	err = decoded.UnmarshalBinary(bs[:3])
+	assert.EqualError(t, err, "cascade encoding too short")
+	err = decoded.UnmarshalBinary(bs[:len(bs)-1])
+	assert.EqualError(t, err, "cascade encoding too short")
+	err = decoded.UnmarshalBinary(append(bs, 0))
+	assert.EqualError(t, err, "cascade encoding has trailing bytes")
+	bs[1] = 0
+	err = decoded.UnmarshalBinary(bs)
+	assert.EqualError(t, err, "k cannot be less than 1")
+}
+
+//
+// Benchmarks
+//
+
+func BenchmarkCascadeContains(b *testing.B) {
+	include := fuseTestKeys("revoked", 1000)
+	c, err := NewCascade(include, fuseTestKeys("valid", 100000))
+	assert.Nil(b, err)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Contains(include[i%len(include)])
+	}
+}
+
+func TestCascadeCloneEqual(t *testing.T) {
+	include, exclude := fuseTestKeys("revoked", 100), fuseTestKeys("valid", 1000)
+	c, err := NewCascade(include, exclude)
+	assert.Nil(t, err)
+
+	clone := c.Clone()
+	assert.True(t, c.Equal(clone))
+	for _, key := range include {
+		assert.True(t, clone.Contains(key))
+	}
+	for _, key := range exclude {
+		assert.False(t, clone.Contains(key))
+	}
+
+	// the levels of a clone are copies
+	clone.levels[0].words[0] ^= 1
+	assert.False(t, c.Equal(clone))
+
+	other, err := NewCascade(exclude, include)
+	assert.Nil(t, err)
+	assert.False(t, c.Equal(other))
+}
@@ -0,0 +1,385 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// CountMinSketch is a Count-Min sketch (Cormode & Muthukrishnan, 2005) for estimating the frequency of entries.
+// It uses the same SHA256-with-nonce hashing as BigBloom, with the row number as the nonce.
+// Estimates are never lower than the true count and, with probability 1-delta, are at most epsilon * total higher.
+type CountMinSketch struct {
+	// number of counters per row
+	width int
+
+	// number of rows, one per hash function
+	depth int
+
+	// depth rows of width counters
+	counters []uint64
+
+	// sum of all counts added
+	total uint64
+
+	// only increment the counters that are at the current minimum
+	conservative bool
+
+	// optional, maximum number of heavy hitters tracked. 0 disables tracking
+	heavyK int
+
+	// tracked heavy hitters and their estimated counts
+	heavy map[string]uint64
+}
+
+// HeavyHitter is an entry with one of the highest estimated counts
+type HeavyHitter struct {
+	Key   []byte
+	Count uint64
+}
+
+// 256 nonces are available for the row hashes
+const maxCountMinDepth = 256
+
+//
+// Constructors
+//
+
+// Constructs count-min sketch with depth rows of width counters
+func NewCountMinSketch(width, depth int) (*CountMinSketch, error) {
+	if width < 1 {
+		return nil, newParameterError("width", width, "width cannot be less than 1")
+	}
+	if depth < 1 || depth > maxCountMinDepth {
+		return nil, newParameterError("depth", depth, fmt.Sprintf("depth must be between 1 and %d", maxCountMinDepth))
+	}
+	return &CountMinSketch{
+		width:        width,
+		depth:        depth,
+		counters:     make([]uint64, width*depth),
+		total:        0,
+		conservative: false,
+		heavyK:       0,
+		heavy:        nil,
+	}, nil
+}
+
+// Constructs count-min sketch where estimates exceed the true count by at most epsilon * total with probability 1-delta
+func NewCountMinSketchAlloc(epsilon, delta float64) (*CountMinSketch, error) {
+	if epsilon <= 0 || epsilon >= 1 {
+		return nil, newParameterError("epsilon", epsilon, "epsilon must be between 0 and 1")
+	}
+	if delta <= 0 || delta >= 1 {
+		return nil, newParameterError("delta", delta, "delta must be between 0 and 1")
+	}
+
+	// math:
+	// width = e/epsilon
+	// depth = ln(1/delta)
+	width := int(math.Ceil(math.E / epsilon))
+	depth := int(math.Ceil(math.Log(1 / delta)))
+	return NewCountMinSketch(width, depth)
+}
+
+//
+// Methods
+//
+
+// Adds count occurences of string element
+func (c *CountMinSketch) AddStr(s string, count uint64) *CountMinSketch {
+	bs := []byte(s)
+	return c.Add(bs, count)
+}
+
+// Adds count occurences of bytes element
+func (c *CountMinSketch) Add(bs []byte, count uint64) *CountMinSketch {
+	if c.conservative {
+		// conservative update: only raise counters to the new minimum estimate
+		est := c.Estimate(bs) + count
+		for row := 0; row < c.depth; row++ {
+			i := c.index(bs, row)
+			if c.counters[i] < est {
+				c.counters[i] = est
+			}
+		}
+	} else {
+		for row := 0; row < c.depth; row++ {
+			c.counters[c.index(bs, row)] += count
+		}
+	}
+	c.total += count
+	if c.heavyK > 0 {
+		c.trackHeavy(bs, c.Estimate(bs))
+	}
+	return c
+}
+
+// Estimates number of occurences of a string element
+func (c *CountMinSketch) EstimateStr(s string) uint64 {
+	bs := []byte(s)
+	return c.Estimate(bs)
+}
+
+// Estimates number of occurences of a bytes element. This is the minimum counter over all rows.
+func (c *CountMinSketch) Estimate(bs []byte) uint64 {
+	var min uint64 = math.MaxUint64
+	for row := 0; row < c.depth; row++ {
+		if v := c.counters[c.index(bs, row)]; v < min {
+			min = v
+		}
+	}
+	return min
+}
+
+// Turns conservative update on or off. Conservative update gives lower estimates, but sketches using it cannot be merged
+// without losing the lower bound of the merged sketch.
+func (c *CountMinSketch) SetConservativeUpdate(conservative bool) {
+	c.conservative = conservative
+}
+
+// Sum of all counts added
+func (c *CountMinSketch) Total() uint64 {
+	return c.total
+}
+
+// Maximum amount an estimate exceeds the true count by, with probability 1-delta: e/width * total
+func (c *CountMinSketch) ErrorBound() float64 {
+	return math.E / float64(c.width) * float64(c.total)
+}
+
+// Adds counts of other into c. Both sketches must have the same width and depth.
+func (c *CountMinSketch) Merge(other *CountMinSketch) error {
+	if c.width != other.width || c.depth != other.depth {
+		return &MismatchError{Reason: "cannot merge count-min sketches of different dimensions"}
+	}
+	for i := range c.counters {
+		c.counters[i] += other.counters[i]
+	}
+	c.total += other.total
+	if c.heavyK > 0 {
+		// estimates of every tracked key may have changed
+		candidates := make([]string, 0, len(c.heavy)+len(other.heavy))
+		for key := range c.heavy {
+			candidates = append(candidates, key)
+		}
+		for key := range other.heavy {
+			candidates = append(candidates, key)
+		}
+		c.heavy = make(map[string]uint64, c.heavyK)
+		for _, key := range candidates {
+			c.trackHeavy([]byte(key), c.Estimate([]byte(key)))
+		}
+	}
+	return nil
+}
+
+// Tracks the k entries with the highest estimated counts from now on
+func (c *CountMinSketch) TrackHeavyHitters(k int) error {
+	if k < 1 {
+		return newParameterError("k", k, "number of heavy hitters cannot be less than 1")
+	}
+	c.heavyK = k
+	if c.heavy == nil {
+		c.heavy = make(map[string]uint64, k)
+	}
+	for len(c.heavy) > k {
+		c.evictLightest()
+	}
+	return nil
+}
+
+// Returns tracked heavy hitters sorted by current estimated count, highest first
+func (c *CountMinSketch) HeavyHitters() []HeavyHitter {
+	hitters := make([]HeavyHitter, 0, len(c.heavy))
+	for key := range c.heavy {
+		bs := []byte(key)
+		hitters = append(hitters, HeavyHitter{Key: bs, Count: c.Estimate(bs)})
+	}
+	sort.Slice(hitters, func(i, j int) bool {
+		if hitters[i].Count != hitters[j].Count {
+			return hitters[i].Count > hitters[j].Count
+		}
+		return string(hitters[i].Key) < string(hitters[j].Key)
+	})
+	return hitters
+}
+
+// Removes all counts and tracked heavy hitters, keeping the dimensions and settings
+func (c *CountMinSketch) Reset() {
+	for i := range c.counters {
+		c.counters[i] = 0
+	}
+	c.total = 0
+	if c.heavy != nil {
+		c.heavy = make(map[string]uint64, c.heavyK)
+	}
+}
+
+// Deep copies sketch, including its settings and tracked heavy hitters
+func (c *CountMinSketch) Clone() *CountMinSketch {
+	var heavy map[string]uint64
+	if c.heavy != nil {
+		heavy = make(map[string]uint64, len(c.heavy))
+		for key, count := range c.heavy {
+			heavy[key] = count
+		}
+	}
+	return &CountMinSketch{
+		width:        c.width,
+		depth:        c.depth,
+		counters:     append([]uint64(nil), c.counters...),
+		total:        c.total,
+		conservative: c.conservative,
+		heavyK:       c.heavyK,
+		heavy:        heavy,
+	}
+}
+
+// Checks whether c and other answer every query the same: same dimensions and counters
+func (c *CountMinSketch) Equal(other *CountMinSketch) bool {
+	if c.width != other.width || c.depth != other.depth {
+		return false
+	}
+	for i := range c.counters {
+		if c.counters[i] != other.counters[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Encodes sketch as: width, depth, total, conservative flag, heavy hitter k, counters and tracked heavy hitter keys.
+// Integers are big endian.
+func (c *CountMinSketch) MarshalBinary() ([]byte, error) {
+	size := 8*4 + 1 + 8*len(c.counters)
+	for key := range c.heavy {
+		size += 4 + len(key)
+	}
+	bs := make([]byte, 0, size)
+	bs = binary.BigEndian.AppendUint64(bs, uint64(c.width))
+	bs = binary.BigEndian.AppendUint64(bs, uint64(c.depth))
+	bs = binary.BigEndian.AppendUint64(bs, c.total)
+	if c.conservative {
+		bs = append(bs, 1)
+	} else {
+		bs = append(bs, 0)
+	}
+	bs = binary.BigEndian.AppendUint64(bs, uint64(c.heavyK))
+	for _, v := range c.counters {
+		bs = binary.BigEndian.AppendUint64(bs, v)
+	}
+	for _, hitter := range c.HeavyHitters() {
+		bs = binary.BigEndian.AppendUint32(bs, uint32(len(hitter.Key)))
+		bs = append(bs, hitter.Key...)
+	}
+	return bs, nil
+}
+
+// Decodes sketch encoded with MarshalBinary
+func (c *CountMinSketch) UnmarshalBinary(bs []byte) error {
+	const header = 8*3 + 1 + 8
+	if len(bs) < header {
+		return newDecodeError("count-min sketch", "count-min sketch encoding too short")
+	}
+	width := binary.BigEndian.Uint64(bs[0:8])
+	depth := binary.BigEndian.Uint64(bs[8:16])
+	total := binary.BigEndian.Uint64(bs[16:24])
+	conservative := bs[24] == 1
+	heavyK := binary.BigEndian.Uint64(bs[25:33])
+	bs = bs[header:]
+
+	if width < 1 || depth < 1 || depth > maxCountMinDepth {
+		return newDecodeError("count-min sketch", "count-min sketch encoding has invalid dimensions")
+	}
+	if heavyK > math.MaxInt {
+		return newDecodeError("count-min sketch", "count-min sketch encoding has invalid number of heavy hitters")
+	}
+	if uint64(len(bs))/8/depth < width {
+		return newDecodeError("count-min sketch", "count-min sketch encoding too short")
+	}
+	counters := make([]uint64, width*depth)
+	for i := range counters {
+		counters[i] = binary.BigEndian.Uint64(bs[8*i:])
+	}
+	bs = bs[8*len(counters):]
+
+	decoded := CountMinSketch{
+		width:        int(width),
+		depth:        int(depth),
+		counters:     counters,
+		total:        total,
+		conservative: conservative,
+		heavyK:       int(heavyK),
+	}
+	if heavyK > 0 {
+		// every encoded heavy hitter takes at least 4 bytes, so a large heavyK does not allocate a large map
+		size := heavyK
+		if encoded := uint64(len(bs) / 4); encoded < size {
+			size = encoded
+		}
+		decoded.heavy = make(map[string]uint64, size)
+	}
+	for len(bs) > 0 {
+		if len(bs) < 4 {
+			return newDecodeError("count-min sketch", "count-min sketch encoding has truncated heavy hitter")
+		}
+		keyLen := binary.BigEndian.Uint32(bs[0:4])
+		bs = bs[4:]
+		if uint64(len(bs)) < uint64(keyLen) || heavyK == 0 {
+			return newDecodeError("count-min sketch", "count-min sketch encoding has truncated heavy hitter")
+		}
+		key := bs[:keyLen]
+		decoded.heavy[string(key)] = decoded.Estimate(key)
+		bs = bs[keyLen:]
+	}
+	*c = decoded
+	return nil
+}
+
+func (c *CountMinSketch) String() string {
+	return fmt.Sprintf("count-min sketch: %d x %d counters, total %d", c.depth, c.width, c.total)
+}
+
+//
+// helpers
+//
+
+// calculate index of bs in counters for row
+func (c *CountMinSketch) index(bs []byte, row int) int {
+	return row*c.width + int(hashIndex(bs, row, uint64(c.width)))
+}
+
+// adds entry to heavy hitters if its count is higher than the lightest tracked entry
+func (c *CountMinSketch) trackHeavy(bs []byte, count uint64) {
+	key := string(bs)
+	if _, ok := c.heavy[key]; ok || len(c.heavy) < c.heavyK {
+		c.heavy[key] = count
+		return
+	}
+	lightest, lightestCount := c.lightest()
+	if count > lightestCount {
+		delete(c.heavy, lightest)
+		c.heavy[key] = count
+	}
+}
+
+// removes the tracked heavy hitter with the lowest count
+func (c *CountMinSketch) evictLightest() {
+	lightest, _ := c.lightest()
+	delete(c.heavy, lightest)
+}
+
+// finds the tracked heavy hitter with the lowest count
+func (c *CountMinSketch) lightest() (string, uint64) {
+	var lightest string
+	var lightestCount uint64 = math.MaxUint64
+	for key, count := range c.heavy {
+		// ties broken by key so that eviction is deterministic
+		if count < lightestCount || (count == lightestCount && key > lightest) {
+			lightest = key
+			lightestCount = count
+		}
+	}
+	return lightest, lightestCount
+}
@@ -0,0 +1,282 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCountMinSketch(t *testing.T) {
+	// test zero width
+	_, err := NewCountMinSketch(0, 4)
+	assert.EqualError(t, err, "width cannot be less than 1")
+	// test depth out of range
+	_, err = NewCountMinSketch(100, 0)
+	assert.EqualError(t, err, "depth must be between 1 and 256")
+	_, err = NewCountMinSketch(100, 257)
+	assert.EqualError(t, err, "depth must be between 1 and 256")
+}
+
+func TestNewCountMinSketchAlloc(t *testing.T) {
+	// test epsilon out of range
+	_, err := NewCountMinSketchAlloc(0, .01)
+	assert.EqualError(t, err, "epsilon must be between 0 and 1")
+	// test delta out of range
+	_, err = NewCountMinSketchAlloc(.01, 1)
+	assert.EqualError(t, err, "delta must be between 0 and 1")
+
+	type allocTest struct {
+		epsilon       float64
+		delta         float64
+		expectedWidth int
+		expectedDepth int
+	}
+
+	tests := []allocTest{
+		{
+			epsilon:       .01,
+			delta:         .01,
+			expectedWidth: 272, // ceil(e/0.01)
+			expectedDepth: 5,   // ceil(ln(100))
+		},
+		{
+			epsilon:       .001,
+			delta:         .0001,
+			expectedWidth: 2719,
+			expectedDepth: 10,
+		},
+	}
+
+	for _, test := range tests {
+		c, err := NewCountMinSketchAlloc(test.epsilon, test.delta)
+		assert.Nil(t, err)
+		assert.Equal(t, test.expectedWidth, c.width)
+		assert.Equal(t, test.expectedDepth, c.depth)
+	}
+}
+
+// zipf distributed stream of keys and their true counts
+func countMinStream(seed int64, n int) ([]string, map[string]uint64) {
+	rng := rand.New(rand.NewSource(seed))
+	zipf := rand.NewZipf(rng, 1.2, 1, 10000)
+	stream := make([]string, n)
+	counts := make(map[string]uint64)
+	for i := range stream {
+		key := strconv.FormatUint(zipf.Uint64(), 10)
+		stream[i] = key
+		counts[key]++
+	}
+	return stream, counts
+}
+
+func TestCountMinSketchEstimate(t *testing.T) {
+	stream, counts := countMinStream(1, 100000)
+
+	for _, conservative := range []bool{false, true} {
+		c, err := NewCountMinSketchAlloc(.001, .01)
+		assert.Nil(t, err)
+		c.SetConservativeUpdate(conservative)
+		for _, key := range stream {
+			c.AddStr(key, 1)
+		}
+		assert.Equal(t, uint64(len(stream)), c.Total())
+
+		// never underestimates and rarely exceeds the error bound
+		bound := c.ErrorBound()
+		exceeded := 0
+		for key, count := range counts {
+			est := c.EstimateStr(key)
+			assert.GreaterOrEqual(t, est, count)
+			if float64(est-count) > bound {
+				exceeded++
+			}
+		}
+		assert.LessOrEqual(t, float64(exceeded)/float64(len(counts)), .01)
+	}
+
+	// absent entries
+	c, err := NewCountMinSketch(100, 4)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(0), c.EstimateStr("missing"))
+	c.AddStr("present", 5)
+	assert.Equal(t, uint64(5), c.EstimateStr("present"))
+}
+
+func TestCountMinSketchConservativeUpdate(t *testing.T) {
+	stream, counts := countMinStream(2, 50000)
+
+	// a narrow sketch so that collisions matter
+	plain, err := NewCountMinSketch(200, 3)
+	assert.Nil(t, err)
+	conservative, err := NewCountMinSketch(200, 3)
+	assert.Nil(t, err)
+	conservative.SetConservativeUpdate(true)
+	for _, key := range stream {
+		plain.AddStr(key, 1)
+		conservative.AddStr(key, 1)
+	}
+
+	// conservative update is never worse and better overall
+	var plainErr, conservativeErr uint64
+	for key, count := range counts {
+		p := plain.EstimateStr(key)
+		c := conservative.EstimateStr(key)
+		assert.LessOrEqual(t, c, p)
+		assert.GreaterOrEqual(t, c, count)
+		plainErr += p - count
+		conservativeErr += c - count
+	}
+	assert.Less(t, conservativeErr, plainErr)
+}
+
+func TestCountMinSketchMerge(t *testing.T) {
+	a, err := NewCountMinSketch(500, 4)
+	assert.Nil(t, err)
+	b, err := NewCountMinSketch(500, 4)
+	assert.Nil(t, err)
+	whole, err := NewCountMinSketch(500, 4)
+	assert.Nil(t, err)
+
+	stream, _ := countMinStream(3, 20000)
+	for i, key := range stream {
+		if i%2 == 0 {
+			a.AddStr(key, 1)
+		} else {
+			b.AddStr(key, 1)
+		}
+		whole.AddStr(key, 1)
+	}
+	err = a.Merge(b)
+	assert.Nil(t, err)
+	assert.Equal(t, whole.counters, a.counters)
+	assert.Equal(t, whole.Total(), a.Total())
+
+	// test different dimensions
+	c, err := NewCountMinSketch(100, 4)
+	assert.Nil(t, err)
+	err = a.Merge(c)
+	assert.EqualError(t, err, "cannot merge count-min sketches of different dimensions")
+}
+
+func TestCountMinSketchHeavyHitters(t *testing.T) {
+	c, err := NewCountMinSketchAlloc(.001, .01)
+	assert.Nil(t, err)
+	err = c.TrackHeavyHitters(0)
+	assert.EqualError(t, err, "number of heavy hitters cannot be less than 1")
+	err = c.TrackHeavyHitters(3)
+	assert.Nil(t, err)
+
+	// zipf puts the most weight on the smallest values, starting at 0
+	stream, counts := countMinStream(4, 100000)
+	for _, key := range stream {
+		c.AddStr(key, 1)
+	}
+	hitters := c.HeavyHitters()
+	assert.Len(t, hitters, 3)
+	for i, expected := range []string{"0", "1", "2"} {
+		assert.Equal(t, expected, string(hitters[i].Key))
+		assert.GreaterOrEqual(t, hitters[i].Count, counts[expected])
+	}
+
+	// shrinking keeps the heaviest
+	err = c.TrackHeavyHitters(1)
+	assert.Nil(t, err)
+	hitters = c.HeavyHitters()
+	assert.Len(t, hitters, 1)
+	assert.Equal(t, "0", string(hitters[0].Key))
+
+	// merged heavy hitters
+	a, err := NewCountMinSketch(1000, 4)
+	assert.Nil(t, err)
+	a.TrackHeavyHitters(1)
+	b, err := NewCountMinSketch(1000, 4)
+	assert.Nil(t, err)
+	b.TrackHeavyHitters(1)
+	a.AddStr("x", 10)
+	a.AddStr("y", 8)
+	b.AddStr("y", 8)
+	a.Merge(b)
+	hitters = a.HeavyHitters()
+	assert.Equal(t, "y", string(hitters[0].Key))
+	assert.Equal(t, uint64(16), hitters[0].Count)
+}
+
+func TestCountMinSketchResetCloneEqual(t *testing.T) {
+	c, err := NewCountMinSketch(100, 4)
+	assert.Nil(t, err)
+	assert.Nil(t, c.TrackHeavyHitters(2))
+	c.AddStr("a", 3)
+	c.AddStr("b", 1)
+
+	// clone is equal but does not share counters or heavy hitters
+	clone := c.Clone()
+	assert.True(t, c.Equal(clone))
+	assert.Equal(t, c, clone)
+	clone.AddStr("c", 5)
+	assert.False(t, c.Equal(clone))
+	assert.Equal(t, uint64(0), c.EstimateStr("c"))
+	assert.Equal(t, 2, len(c.HeavyHitters()))
+
+	// reset removes counts and heavy hitters but keeps settings
+	clone.Reset()
+	assert.Equal(t, uint64(0), clone.Total())
+	assert.Equal(t, 0, len(clone.HeavyHitters()))
+	assert.Equal(t, 2, clone.heavyK)
+	clone.AddStr("a", 3)
+	clone.AddStr("b", 1)
+	assert.True(t, c.Equal(clone))
+
+	// different dimensions
+	other, err := NewCountMinSketch(100, 3)
+	assert.Nil(t, err)
+	assert.False(t, c.Equal(other))
+}
+
+func TestCountMinSketchMarshalBinary(t *testing.T) {
+	c, err := NewCountMinSketch(300, 5)
+	assert.Nil(t, err)
+	c.SetConservativeUpdate(true)
+	c.TrackHeavyHitters(2)
+	stream, _ := countMinStream(5, 5000)
+	for _, key := range stream {
+		c.AddStr(key, 1)
+	}
+
+	bs, err := c.MarshalBinary()
+	assert.Nil(t, err)
+	var decoded CountMinSketch
+	err = decoded.UnmarshalBinary(bs)
+	assert.Nil(t, err)
+	assert.Equal(t, c.width, decoded.width)
+	assert.Equal(t, c.depth, decoded.depth)
+	assert.Equal(t, c.counters, decoded.counters)
+	assert.Equal(t, c.Total(), decoded.Total())
+	assert.True(t, decoded.conservative)
+	assert.Equal(t, c.HeavyHitters(), decoded.HeavyHitters())
+
+	// test truncated encodings
+	err = decoded.UnmarshalBinary(bs[:10])
+	assert.EqualError(t, err, "count-min sketch encoding too short")
+	err = decoded.UnmarshalBinary(bs[:100])
+	assert.EqualError(t, err, "count-min sketch encoding too short")
+	err = decoded.UnmarshalBinary(bs[:len(bs)-1])
+	assert.EqualError(t, err, "count-min sketch encoding has truncated heavy hitter")
+
+	// test number of heavy hitters that does not fit an int, which would otherwise turn tracking off
+	invalid := append([]byte(nil), bs...)
+	binary.BigEndian.PutUint64(invalid[25:33], math.MaxUint64)
+	err = decoded.UnmarshalBinary(invalid)
+	assert.EqualError(t, err, "count-min sketch encoding has invalid number of heavy hitters")
+	assert.Equal(t, c.HeavyHitters(), decoded.HeavyHitters())
+
+	// a large number of heavy hitters that fits an int is kept
+	binary.BigEndian.PutUint64(invalid[25:33], math.MaxInt)
+	err = decoded.UnmarshalBinary(invalid)
+	assert.Nil(t, err)
+	assert.Equal(t, math.MaxInt, decoded.heavyK)
+	assert.Equal(t, c.HeavyHitters(), decoded.HeavyHitters())
+}
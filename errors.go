@@ -0,0 +1,217 @@
+package bloom
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors. The typed errors below match them with errors.Is, and errors.As gives their details.
+var (
+	// an entry was rejected because the filter is at its capacity constraint. Matched by *CapacityError
+	ErrCapacity = errors.New("bloom filter at max capacity")
+
+	// an entry was rejected because it would break the accuracy constraint. Matched by *AccuracyError
+	ErrAccuracy = errors.New("bloom filter constrained by max false positive rate")
+
+	// a constructor, constraint setter or decoder was given an invalid parameter. Matched by *ParameterError
+	ErrInvalidParameter = errors.New("invalid parameter")
+
+	// the capacity and accuracy constraints cannot both hold. Matched by *ConstraintError
+	ErrIncompatibleConstraints = errors.New("incompatible constraints")
+
+	// constraints cannot be added to filters loaded from bytes, since n is unknown
+	ErrLoaded = errors.New("cannot add constraints to loaded bloom filters")
+
+	// a quotient filter has no empty slot left
+	ErrFull = errors.New("failed to add entry: quotient filter is full")
+
+	// a quotient filter cannot have more slots. Matched by *SizeError
+	ErrMaxSize = errors.New("quotient filter cannot grow")
+
+	// an encoding was written by a version of the package that hashes differently or that this version cannot read,
+	// for example a stored filter missing an entry known to be in it. Matched by *CompatibilityError
+	ErrIncompatible = errors.New("bloom filter is incompatible")
+
+	// an encoding is truncated or malformed. Matched by *DecodeError
+	ErrDecode = errors.New("invalid encoding")
+
+	// filters cannot be merged, intersected or subtracted because their sizes or hashing differ. Matched by *MismatchError
+	ErrMismatch = errors.New("filters cannot be combined")
+
+	// a Guard key is not in the backing store, either because the filter ruled it out or because the loader did not find it
+	ErrNotFound = errors.New("key not found")
+)
+
+// CapacityError is returned when inserting into a filter at its capacity constraint
+type CapacityError struct {
+	// capacity constraint
+	Cap int
+
+	// number of unique entries
+	N int
+}
+
+func (e *CapacityError) Error() string {
+	return fmt.Sprintf("failed to add entry: bloom filter at max capacity %d", e.Cap)
+}
+
+func (e *CapacityError) Is(target error) bool {
+	return target == ErrCapacity
+}
+
+// AccuracyError is returned when inserting would make the false positive rate worse than the accuracy constraint
+type AccuracyError struct {
+	// accuracy constraint
+	MaxFalsePositiveRate float64
+
+	// number of unique entries
+	N int
+
+	// projected false positive rate with the rejected entry
+	FalsePositiveRate float64
+}
+
+func (e *AccuracyError) Error() string {
+	return fmt.Sprintf("failed to add entry: bloom filter constrained by max false positive rate %f", e.MaxFalsePositiveRate)
+}
+
+func (e *AccuracyError) Is(target error) bool {
+	return target == ErrAccuracy
+}
+
+// ParameterError is returned when a constructor or constraint setter is given an invalid parameter,
+// or when a decoded value is one that the constructors would reject, such as k of 0
+type ParameterError struct {
+	// name of the parameter
+	Param string
+
+	// invalid value
+	Value interface{}
+
+	// why the value is invalid
+	Reason string
+}
+
+func (e *ParameterError) Error() string {
+	return e.Reason
+}
+
+func (e *ParameterError) Is(target error) bool {
+	return target == ErrInvalidParameter
+}
+
+// ConstraintError is returned when a filter at its capacity constraint would have a false positive rate above its accuracy constraint
+type ConstraintError struct {
+	// capacity constraint
+	Cap int
+
+	// accuracy constraint
+	MaxFalsePositiveRate float64
+
+	// projected false positive rate at capacity
+	FalsePositiveRate float64
+}
+
+func (e *ConstraintError) Error() string {
+	return "false positive rate will be higher at full capacity than the maxFalsePositiveRate provided"
+}
+
+func (e *ConstraintError) Is(target error) bool {
+	return target == ErrIncompatibleConstraints
+}
+
+// CompatibilityError is returned when a loaded filter does not contain an entry known to be in it,
+// or when an encoding version is not supported
+type CompatibilityError struct {
+	// encoding version of the filter
+	Version int
+
+	// missing entry, nil if the filter is incompatible for another reason
+	Entry []byte
+
+	// why the filter is incompatible, if no entry is missing
+	Reason string
+}
+
+func (e *CompatibilityError) Error() string {
+	if e.Entry == nil && e.Reason != "" {
+		return e.Reason
+	}
+	return fmt.Sprintf("bloom filter is incompatible: encoding version %d does not contain known entry %x", e.Version, e.Entry)
+}
+
+func (e *CompatibilityError) Is(target error) bool {
+	return target == ErrIncompatible
+}
+
+// DecodeError is returned when an encoding is truncated or malformed
+type DecodeError struct {
+	// kind of filter the encoding is of
+	Format string
+
+	// what is wrong with the encoding
+	Reason string
+}
+
+func (e *DecodeError) Error() string {
+	return e.Reason
+}
+
+func (e *DecodeError) Is(target error) bool {
+	return target == ErrDecode
+}
+
+// MismatchError is returned when filters whose sizes or hashing differ are merged, intersected or subtracted
+type MismatchError struct {
+	// why the filters cannot be combined
+	Reason string
+}
+
+func (e *MismatchError) Error() string {
+	return e.Reason
+}
+
+func (e *MismatchError) Is(target error) bool {
+	return target == ErrMismatch
+}
+
+// SizeError is returned when a quotient filter would need more slots than it can have
+type SizeError struct {
+	// quotient bits of the filter
+	Q int
+
+	// remainder bits of the filter
+	R int
+
+	// why the filter cannot grow
+	Reason string
+}
+
+func (e *SizeError) Error() string {
+	return e.Reason
+}
+
+func (e *SizeError) Is(target error) bool {
+	return target == ErrMaxSize
+}
+
+//
+// helpers
+//
+
+// constructs error for invalid value of param
+func newParameterError(param string, value interface{}, reason string) error {
+	return &ParameterError{
+		Param:  param,
+		Value:  value,
+		Reason: reason,
+	}
+}
+
+// constructs error for a malformed encoding of format
+func newDecodeError(format, reason string) error {
+	return &DecodeError{
+		Format: format,
+		Reason: reason,
+	}
+}
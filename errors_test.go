@@ -0,0 +1,224 @@
+package bloom
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapacityError(t *testing.T) {
+	b, err := NewBigBloomFromK(128, 3)
+	assert.Nil(t, err)
+	assert.Nil(t, b.AddCapacityConstraint(2))
+	b.PutStr("a")
+	b.PutStr("b")
+	_, err = b.PutStr("c")
+	assert.True(t, errors.Is(err, ErrCapacity))
+	assert.False(t, errors.Is(err, ErrAccuracy))
+	var capErr *CapacityError
+	assert.True(t, errors.As(err, &capErr))
+	assert.Equal(t, 2, capErr.Cap)
+	assert.Equal(t, 2, capErr.N)
+	assert.EqualError(t, err, "failed to add entry: bloom filter at max capacity 2")
+}
+
+func TestAccuracyError(t *testing.T) {
+	b, err := NewBloomFromK(3)
+	assert.Nil(t, err)
+	assert.Nil(t, b.AddAccuracyConstraint(.01))
+	for i := 0; err == nil; i++ {
+		_, err = b.PutStr(strconv.Itoa(i))
+	}
+	assert.True(t, errors.Is(err, ErrAccuracy))
+	var accErr *AccuracyError
+	assert.True(t, errors.As(err, &accErr))
+	assert.Equal(t, .01, accErr.MaxFalsePositiveRate)
+	assert.Equal(t, b.n, accErr.N)
+	assert.Equal(t, falsePositiveRate(BLOOM_LEN, b.n+1, 3), accErr.FalsePositiveRate)
+	assert.Greater(t, accErr.FalsePositiveRate, .01)
+	assert.EqualError(t, err, "failed to add entry: bloom filter constrained by max false positive rate 0.010000")
+
+	// quotient filters too
+	f, err := NewQuotientFilter(10, 4)
+	assert.Nil(t, err)
+	assert.Nil(t, f.AddAccuracyConstraint(.01))
+	for i := 0; err == nil; i++ {
+		_, err = f.PutStr(strconv.Itoa(i))
+	}
+	assert.True(t, errors.As(err, &accErr))
+	assert.Equal(t, f.n, accErr.N)
+}
+
+func TestConstraintError(t *testing.T) {
+	b, err := NewBigBloomFromK(16, 3)
+	assert.Nil(t, err)
+	assert.Nil(t, b.AddCapacityConstraint(1000))
+	err = b.AddAccuracyConstraint(.01)
+	assert.True(t, errors.Is(err, ErrIncompatibleConstraints))
+	var constraintErr *ConstraintError
+	assert.True(t, errors.As(err, &constraintErr))
+	assert.Equal(t, 1000, constraintErr.Cap)
+	assert.Equal(t, .01, constraintErr.MaxFalsePositiveRate)
+	assert.Equal(t, falsePositiveRate(16, 1000, 3), constraintErr.FalsePositiveRate)
+
+	// loaded filters
+	loaded, err := NewBigBloomFromBytes(make([]byte, 16), 3)
+	assert.Nil(t, err)
+	assert.Equal(t, ErrLoaded, loaded.AddCapacityConstraint(10))
+
+	// full quotient filter
+	f, err := NewQuotientFilter(2, 20)
+	assert.Nil(t, err)
+	for i := 0; err == nil; i++ {
+		_, err = f.PutStr(strconv.Itoa(i))
+	}
+	assert.Equal(t, ErrFull, err)
+}
+
+func TestParameterErrors(t *testing.T) {
+	type parameterTest struct {
+		err   error
+		param string
+		value interface{}
+	}
+
+	newErr := func(_ interface{}, err error) error {
+		return err
+	}
+	b, _ := NewBigBloomFromK(16, 3)
+	q, _ := NewQuotientFilter(8, 8)
+	full, _ := NewBigBloomFromK(16, 3)
+	full.PutStr("a")
+	var decoded BigBloom
+
+	tests := []parameterTest{
+		{err: newErr(NewBloomFromK(0)), param: "k", value: 0},
+		{err: newErr(NewBloomFromCap(-1)), param: "cap", value: -1},
+		{err: newErr(NewBloomFromAcc(2)), param: "maxFalsePositiveRate", value: 2.0},
+		{err: newErr(NewBigBloomFromBytes(nil, 3)), param: "bs", value: []byte(nil)},
+		{err: newErr(NewBigBloomAlloc(10, 0)), param: "maxFalsePositiveRate", value: 0.0},
+		{err: b.AddCapacityConstraint(0), param: "cap", value: 0},
+		{err: b.AddAccuracyConstraint(1), param: "maxFalsePositiveRate", value: 1.0},
+		{err: newErr(NewRotatingBloom(0, 10, .01, time.Minute)), param: "generations", value: 0},
+		{err: newErr(NewStableBloom(100, 3, 9, 10, 1)), param: "d", value: 9},
+		{err: newErr(NewCountMinSketch(0, 4)), param: "width", value: 0},
+		{err: newErr(NewIBLT(10, 0)), param: "k", value: 0},
+		{err: newErr(NewQuotientFilter(40, 8)), param: "q", value: 40},
+		{err: q.AddCapacityConstraint(0), param: "cap", value: 0},
+		{err: newErr(NewEthBloomFromBytes([]byte{1})), param: "bs", value: []byte{1}},
+		{err: newErr(NewPlan(0, 0, -8, 0)), param: "m", value: -8},
+		{err: newErr(New(WithK(-1))), param: "k", value: -1},
+		{err: newErr(New(WithK(BLOOM_MAX_K + 1))), param: "k", value: BLOOM_MAX_K + 1},
+		{err: newErr(NewPlan(0, 0, 1024, BLOOM_MAX_K+1)), param: "k", value: BLOOM_MAX_K + 1},
+		{err: newErr(New(WithK(1))), param: "opts", value: nil},
+		{err: newErr(NewFilter[int](nil, WithK(1))), param: "encode", value: nil},
+		{err: full.AddKey([BLOOM_KEY_LEN]byte{}), param: "key", value: nil},
+		{err: decoded.UnmarshalBinary([]byte{0x10, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}), param: "k", value: 0},
+	}
+
+	for _, test := range tests {
+		assert.True(t, errors.Is(test.err, ErrInvalidParameter))
+		var paramErr *ParameterError
+		assert.True(t, errors.As(test.err, &paramErr))
+		assert.Equal(t, test.param, paramErr.Param)
+		assert.Equal(t, test.value, paramErr.Value)
+		assert.Equal(t, paramErr.Reason, test.err.Error())
+	}
+}
+
+func TestCompatibilityErrors(t *testing.T) {
+	newer := make([]byte, 14)
+	newer[0] = (BIG_BLOOM_ENCODING_VERSION + 1) << bigBloomVersionShift
+	var decoded BigBloom
+
+	err := decoded.UnmarshalBinary(newer)
+	assert.True(t, errors.Is(err, ErrIncompatible))
+	var compatibilityErr *CompatibilityError
+	assert.True(t, errors.As(err, &compatibilityErr))
+	assert.Equal(t, BIG_BLOOM_ENCODING_VERSION+1, compatibilityErr.Version)
+	assert.Nil(t, compatibilityErr.Entry)
+	assert.Equal(t, compatibilityErr.Reason, err.Error())
+}
+
+func TestDecodeErrors(t *testing.T) {
+	type decodeTest struct {
+		err    error
+		format string
+	}
+
+	newErr := func(_ interface{}, err error) error {
+		return err
+	}
+	var big BigBloom
+	var c Cascade
+	var cms CountMinSketch
+	var f8 BinaryFuse8
+	var f16 BinaryFuse16
+	var iblt IBLT
+	fuse8, _ := NewBinaryFuse8(fuseTestKeys("key", 10))
+	encoded8, _ := fuse8.MarshalBinary()
+
+	tests := []decodeTest{
+		{err: newErr(BigBloomEncodingVersion(nil)), format: "big bloom"},
+		{err: big.UnmarshalBinary([]byte{0x10}), format: "big bloom"},
+		{err: c.UnmarshalBinary(nil), format: "cascade"},
+		{err: c.UnmarshalBinary([]byte{0, 1}), format: "cascade"},
+		{err: cms.UnmarshalBinary(make([]byte, 10)), format: "count-min sketch"},
+		{err: cms.UnmarshalBinary(make([]byte, 33)), format: "count-min sketch"},
+		{err: f8.UnmarshalBinary(nil), format: "binary fuse"},
+		{err: f16.UnmarshalBinary(encoded8), format: "binary fuse"},
+		{err: iblt.UnmarshalBinary(make([]byte, 7)), format: "IBLT"},
+		{err: iblt.UnmarshalBinary(make([]byte, 32)), format: "IBLT"},
+	}
+
+	for _, test := range tests {
+		assert.True(t, errors.Is(test.err, ErrDecode))
+		assert.False(t, errors.Is(test.err, ErrInvalidParameter))
+		var decodeErr *DecodeError
+		assert.True(t, errors.As(test.err, &decodeErr))
+		assert.Equal(t, test.format, decodeErr.Format)
+		assert.Equal(t, decodeErr.Reason, test.err.Error())
+	}
+}
+
+func TestMismatchErrors(t *testing.T) {
+	a, _ := NewBigBloomFromK(16, 3)
+	short, _ := NewBigBloomFromK(15, 3)
+	keyed, _ := NewBigBloomFromK(16, 3)
+	keyed.AddKey([BLOOM_KEY_LEN]byte{1})
+	cms, _ := NewCountMinSketch(10, 4)
+	narrow, _ := NewCountMinSketch(9, 4)
+	iblt, _ := NewIBLT(30, 3)
+	small, _ := NewIBLT(15, 3)
+	q, _ := NewQuotientFilter(8, 8)
+	otherBits, _ := NewQuotientFilter(8, 9)
+
+	for _, err := range []error{
+		a.Union(short),
+		a.Intersect(keyed),
+		cms.Merge(narrow),
+		func() error { _, err := iblt.Subtract(small); return err }(),
+		q.Merge(otherBits),
+	} {
+		assert.True(t, errors.Is(err, ErrMismatch))
+		assert.False(t, errors.Is(err, ErrIncompatible))
+		var mismatchErr *MismatchError
+		assert.True(t, errors.As(err, &mismatchErr))
+		assert.Equal(t, mismatchErr.Reason, err.Error())
+	}
+}
+
+func TestSizeErrors(t *testing.T) {
+	f, err := NewQuotientFilter(4, 1)
+	assert.Nil(t, err)
+	err = f.Resize()
+	assert.True(t, errors.Is(err, ErrMaxSize))
+	var sizeErr *SizeError
+	assert.True(t, errors.As(err, &sizeErr))
+	assert.Equal(t, 4, sizeErr.Q)
+	assert.Equal(t, 1, sizeErr.R)
+	assert.EqualError(t, err, "cannot resize quotient filter: no remainder bits left")
+}
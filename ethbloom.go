@@ -0,0 +1,150 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const ETH_BLOOM_LEN = 256
+
+// EthBloom is the 2048-bit bloom filter Ethereum block headers and receipts carry in their logsBloom field.
+// Every item sets 3 bits, each chosen by the low 11 bits of one of the first three byte pairs of keccak256(item).
+// Bits are numbered from the end of the filter, so bit 0 is the lowest bit of the last byte.
+type EthBloom [ETH_BLOOM_LEN]byte
+
+//
+// Constructors
+//
+
+// Load Ethereum bloom filter from the 256 bytes of a logsBloom field
+func NewEthBloomFromBytes(bs []byte) (*EthBloom, error) {
+	if len(bs) != ETH_BLOOM_LEN {
+		return nil, newParameterError("bs", bs, fmt.Sprintf("ethereum bloom filter must be %d bytes", ETH_BLOOM_LEN))
+	}
+	var b EthBloom
+	copy(b[:], bs)
+	return &b, nil
+}
+
+// Load Ethereum bloom filter from a hex string with or without 0x prefix, as returned by JSON-RPC
+func NewEthBloomFromHex(s string) (*EthBloom, error) {
+	bs, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil {
+		return nil, err
+	}
+	return NewEthBloomFromBytes(bs)
+}
+
+//
+// Methods
+//
+
+// Inserts item into bloom filter
+func (b *EthBloom) Add(item []byte) {
+	for _, bit := range ethBloomBits(item) {
+		b[ethBloomByte(bit)] |= ethBloomMask(bit)
+	}
+}
+
+// Inserts a log's address and topics into bloom filter, the same way a receipt's logsBloom is built
+func (b *EthBloom) AddLog(address []byte, topics [][]byte) {
+	b.Add(address)
+	for _, topic := range topics {
+		b.Add(topic)
+	}
+}
+
+// Checks for existance of item in bloom filter
+func (b *EthBloom) Test(item []byte) bool {
+	for _, bit := range ethBloomBits(item) {
+		mask := ethBloomMask(bit)
+		if b[ethBloomByte(bit)]&mask != mask {
+			return false
+		}
+	}
+	return true
+}
+
+// Combines other into b, like the block header logsBloom combines the logsBloom of every receipt
+func (b *EthBloom) Or(other *EthBloom) {
+	for i := range b {
+		b[i] |= other[i]
+	}
+}
+
+// Removes all items
+func (b *EthBloom) Reset() {
+	*b = EthBloom{}
+}
+
+// Copies the bloom filter
+func (b *EthBloom) Clone() *EthBloom {
+	c := *b
+	return &c
+}
+
+// Checks whether b and other answer every query the same
+func (b *EthBloom) Equal(other *EthBloom) bool {
+	return *b == *other
+}
+
+// The 256 bytes of the bloom filter
+func (b *EthBloom) Bytes() []byte {
+	return b[:]
+}
+
+// converts bytes of bloom filter to hex string
+func (b *EthBloom) Hex() string {
+	return hex.EncodeToString(b[:])
+}
+
+// Encodes bloom filter as 0x-prefixed hex, like the logsBloom field of JSON-RPC responses
+func (b EthBloom) MarshalText() ([]byte, error) {
+	return []byte("0x" + b.Hex()), nil
+}
+
+// Decodes bloom filter from hex with or without 0x prefix
+func (b *EthBloom) UnmarshalText(text []byte) error {
+	decoded, err := NewEthBloomFromHex(string(text))
+	if err != nil {
+		return err
+	}
+	*b = *decoded
+	return nil
+}
+
+func (b *EthBloom) String() string {
+	return fmt.Sprintf("%d-bit ethereum bloom filter", 8*ETH_BLOOM_LEN)
+}
+
+// Checks for existance of topic, or any other item such as a log address, in bloom filter bin
+func BloomLookup(bin EthBloom, topic []byte) bool {
+	return bin.Test(topic)
+}
+
+//
+// helpers
+//
+
+// calculate the 3 bits item sets
+func ethBloomBits(item []byte) [3]uint {
+	h := Keccak256(item)
+	var bits [3]uint
+	for i := range bits {
+		// low 11 bits of each byte pair index 2048 bits
+		bits[i] = uint(binary.BigEndian.Uint16(h[2*i:]) & 0x7ff)
+	}
+	return bits
+}
+
+// calculate index of byte that holds bit. Bit 0 is in the last byte
+func ethBloomByte(bit uint) int {
+	return ETH_BLOOM_LEN - 1 - int(bit/8)
+}
+
+// calculate mask of bit within its byte
+func ethBloomMask(bit uint) byte {
+	return byte(1 << (bit % 8))
+}
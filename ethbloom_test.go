@@ -0,0 +1,234 @@
+package bloom
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	bs, err := hex.DecodeString(s)
+	assert.Nil(t, err)
+	return bs
+}
+
+func TestKeccak256(t *testing.T) {
+	type keccakTest struct {
+		data     []byte
+		expected string
+	}
+
+	tests := []keccakTest{
+		{
+			data:     []byte(""),
+			expected: "c5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470",
+		},
+		{
+			data:     []byte("abc"),
+			expected: "4e03657aea45a94fc7d47ba826c8d667c0d1e6e33a64a036ec44f58fa12d6c45",
+		},
+		{
+			// ERC-20 Transfer event signature
+			data:     []byte("Transfer(address,address,uint256)"),
+			expected: "ddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef",
+		},
+	}
+
+	for _, test := range tests {
+		h := Keccak256(test.data)
+		assert.Equal(t, test.expected, hex.EncodeToString(h[:]))
+	}
+
+	// inputs around the 136 byte block size
+	for _, n := range []int{135, 136, 137, 272} {
+		h := Keccak256(make([]byte, n))
+		assert.NotEqual(t, Keccak256(make([]byte, n+1)), h)
+	}
+}
+
+// positives and negatives from go-ethereum's core/types TestBloom
+func TestEthBloomAddTest(t *testing.T) {
+	positive := []string{"testtest", "test", "hallo", "other"}
+	negative := []string{"tes", "lo"}
+
+	var b EthBloom
+	for _, data := range positive {
+		b.Add([]byte(data))
+	}
+	for _, data := range positive {
+		assert.True(t, b.Test([]byte(data)))
+		assert.True(t, BloomLookup(b, []byte(data)))
+	}
+	for _, data := range negative {
+		assert.False(t, b.Test([]byte(data)))
+		assert.False(t, BloomLookup(b, []byte(data)))
+	}
+}
+
+// expected hash from go-ethereum's core/types TestBloomExtensively
+func TestEthBloomExtensively(t *testing.T) {
+	var b EthBloom
+	for i := 0; i < 100; i++ {
+		b.Add([]byte(fmt.Sprintf("xxxxxxxxxx data %d yyyyyyyyyyyyyy", i)))
+	}
+	h := Keccak256(b.Bytes())
+	assert.Equal(t, "c8d3ca65cdb4874300a9e39475508f23ed6da09fdbc487f89a2dcf50b09eb263", hex.EncodeToString(h[:]))
+
+	// loading the bytes gives the same filter
+	loaded, err := NewEthBloomFromBytes(b.Bytes())
+	assert.Nil(t, err)
+	assert.Equal(t, b, *loaded)
+}
+
+// receipt with a single ERC-20 Transfer log, from go-ethereum's eth_getTransactionReceipt test data
+func TestEthBloomReceipt(t *testing.T) {
+	address := mustDecodeHex(t, "0000000000000000000000000000000000031ec7")
+	topics := [][]byte{
+		mustDecodeHex(t, "ddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"),
+		mustDecodeHex(t, "000000000000000000000000703c4b2bd70c169f5717101caee543299fc946c7"),
+		mustDecodeHex(t, "0000000000000000000000000000000000000000000000000000000000000003"),
+	}
+	logsBloom := "0x00000000000000000000008000000000000000000000000000000000000000000000000000000000000000000000000000000000020000000000000000000000000000800000000000000008000000000000000000000000000000000020000000080000000000000000000000000000000000000000000000000010000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000800000000000000000400000000002000000000000800000000000000000000000000000000000000000000000000000000000000000000000000000000020000000000000000000000000"
+
+	var b EthBloom
+	b.AddLog(address, topics)
+	text, err := b.MarshalText()
+	assert.Nil(t, err)
+	assert.Equal(t, logsBloom, string(text))
+
+	// look up the log in the bloom as found in the receipt
+	var receipt struct {
+		LogsBloom EthBloom `json:"logsBloom"`
+	}
+	err = json.Unmarshal([]byte(`{"logsBloom":"`+logsBloom+`"}`), &receipt)
+	assert.Nil(t, err)
+	assert.True(t, BloomLookup(receipt.LogsBloom, address))
+	for _, topic := range topics {
+		assert.True(t, BloomLookup(receipt.LogsBloom, topic))
+	}
+	// Approval event signature is not in the bloom
+	approval := Keccak256([]byte("Approval(address,address,uint256)"))
+	assert.False(t, BloomLookup(receipt.LogsBloom, approval[:]))
+}
+
+// ethHeaderFixture is an ethereum mainnet block header stored in testdata/ethheaders.json with every log of the block
+type ethHeaderFixture struct {
+	// block number
+	Number uint64 `json:"number"`
+
+	// block hash, to look the block up
+	Hash string `json:"hash"`
+
+	// logsBloom of the header
+	LogsBloom string `json:"logsBloom"`
+
+	// logs of every receipt of the block
+	Logs []struct {
+		Address string   `json:"address"`
+		Topics  []string `json:"topics"`
+	} `json:"logs"`
+}
+
+// a block header's logsBloom is built from the address and topics of every log in the block
+func TestEthBloomHeader(t *testing.T) {
+	bs, err := os.ReadFile("testdata/ethheaders.json")
+	assert.Nil(t, err)
+	var fixtures []ethHeaderFixture
+	assert.Nil(t, json.Unmarshal(bs, &fixtures))
+	assert.NotEmpty(t, fixtures)
+
+	for _, fixture := range fixtures {
+		expected, err := NewEthBloomFromHex(fixture.LogsBloom)
+		assert.Nil(t, err)
+		var rebuilt EthBloom
+		for _, log := range fixture.Logs {
+			topics := make([][]byte, len(log.Topics))
+			for i, topic := range log.Topics {
+				topics[i] = mustDecodeHex(t, strings.TrimPrefix(topic, "0x"))
+			}
+			rebuilt.AddLog(mustDecodeHex(t, strings.TrimPrefix(log.Address, "0x")), topics)
+		}
+		assert.Equal(t, expected.Hex(), rebuilt.Hex(), "block %d %s", fixture.Number, fixture.Hash)
+	}
+}
+
+// ORing receipt blooms does not depend on their order
+func TestEthBloomOr(t *testing.T) {
+	// receipt from TestEthBloomReceipt and a receipt with an Approval log of another contract
+	transfer, err := NewEthBloomFromHex("0x00000000000000000000008000000000000000000000000000000000000000000000000000000000000000000000000000000000020000000000000000000000000000800000000000000008000000000000000000000000000000000020000000080000000000000000000000000000000000000000000000000010000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000800000000000000000400000000002000000000000800000000000000000000000000000000000000000000000000000000000000000000000000000000020000000000000000000000000")
+	assert.Nil(t, err)
+	approvalAddress := mustDecodeHex(t, "00000000000000000000000000000000000a11ce")
+	approvalTopic := Keccak256([]byte("Approval(address,address,uint256)"))
+	var approval EthBloom
+	approval.AddLog(approvalAddress, [][]byte{approvalTopic[:]})
+
+	var header EthBloom
+	for _, receipt := range []*EthBloom{transfer, &approval} {
+		header.Or(receipt)
+	}
+	for i := range header {
+		assert.Equal(t, transfer[i]|approval[i], header[i])
+	}
+	assert.True(t, header.Test(approvalAddress))
+	assert.True(t, header.Test(approvalTopic[:]))
+	assert.True(t, header.Test(mustDecodeHex(t, "0000000000000000000000000000000000031ec7")))
+	assert.NotEqual(t, *transfer, header)
+
+	// the order of receipts does not matter and ORing a receipt again changes nothing
+	var reversed EthBloom
+	reversed.Or(&approval)
+	reversed.Or(transfer)
+	reversed.Or(transfer)
+	assert.Equal(t, header, reversed)
+}
+
+func TestNewEthBloomFromBytes(t *testing.T) {
+	_, err := NewEthBloomFromBytes(make([]byte, 255))
+	assert.EqualError(t, err, "ethereum bloom filter must be 256 bytes")
+	_, err = NewEthBloomFromHex("0x1234")
+	assert.EqualError(t, err, "ethereum bloom filter must be 256 bytes")
+	_, err = NewEthBloomFromHex("0xzz")
+	assert.NotNil(t, err)
+
+	// bytes are copied
+	bs := make([]byte, ETH_BLOOM_LEN)
+	b, err := NewEthBloomFromBytes(bs)
+	assert.Nil(t, err)
+	bs[0] = 1
+	assert.Equal(t, byte(0), b[0])
+	assert.Equal(t, "2048-bit ethereum bloom filter", b.String())
+}
+
+//
+// Benchmarks
+//
+
+func BenchmarkEthBloomTest(b *testing.B) {
+	var bloom EthBloom
+	topic := Keccak256([]byte("Transfer(address,address,uint256)"))
+	bloom.Add(topic[:])
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bloom.Test(topic[:])
+	}
+}
+
+func TestEthBloomResetCloneEqual(t *testing.T) {
+	var b EthBloom
+	b.Add([]byte("test"))
+
+	c := b.Clone()
+	assert.True(t, b.Equal(c))
+	c.Add([]byte("other"))
+	assert.False(t, b.Equal(c))
+	assert.False(t, b.Test([]byte("other")))
+
+	c.Reset()
+	assert.True(t, c.Equal(&EthBloom{}))
+	assert.True(t, b.Test([]byte("test")))
+}
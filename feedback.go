@@ -0,0 +1,292 @@
+package bloom
+
+import (
+	"errors"
+	"fmt"
+)
+
+// RebuildFunc fills a fresh filter with every entry of the source of truth, for example the keys of a backing store
+type RebuildFunc func(b *BigBloom) error
+
+// DriftEvent describes the observed false positive rate of a FeedbackBloom drifting above its maxFalsePositiveRate
+type DriftEvent struct {
+	// observed false positive rate: false positives / (false positives + negative answers)
+	Observed float64
+
+	// false positive rate promised by the filter's Accuracy()
+	Expected float64
+
+	// maximum false positive rate of the filter
+	MaxFalsePositiveRate float64
+
+	// number of reported false positives in the window
+	FalsePositives int
+
+	// number of checks of entries not in the filter in the window, false positives included
+	Samples int
+
+	// whether the filter was rebuilt
+	Rebuilt bool
+
+	// error of the rebuild, if any. The old filter is kept when the rebuild fails
+	Err error
+}
+
+// FeedbackBloom is a bloom filter that is told when it lied. A caller that checks the source of truth after a positive answer
+// reports confirmed false positives, and the filter compares the observed false positive rate with maxFalsePositiveRate.
+// Negative answers are always right, so the observed rate is false positives / (false positives + negative answers).
+// Once a window holds minSamples such checks and the observed rate is above maxFalsePositiveRate, the filter drifts:
+// it is rebuilt if it has a RebuildFunc, the callbacks are called, and a new window starts.
+type FeedbackBloom struct {
+	// live filter
+	b *BigBloom
+
+	// maximum number of unique entries
+	cap int
+
+	// maximum false positive rate, observed or promised
+	maxFalsePositiveRate float64
+
+	// number of checks of entries not in the filter before the observed rate is trusted
+	minSamples int
+
+	// optional, fills a fresh filter when the filter drifts
+	rebuild RebuildFunc
+
+	// generates the key of every fresh filter. GenerateKey outside of tests
+	generateKey func() ([BLOOM_KEY_LEN]byte, error)
+
+	// number of negative answers in the window
+	negatives int
+
+	// number of reported false positives in the window
+	falsePositives int
+
+	// called in order after every drift
+	callbacks []func(DriftEvent)
+
+	// number of drifts so far
+	drifts int
+
+	// number of successful rebuilds so far
+	rebuilds int
+}
+
+//
+// Constructors
+//
+
+// Constructs feedback bloom filter for cap entries at maxFalsePositiveRate. The observed rate is trusted after minSamples checks of entries
+// not in the filter. rebuild is optional: without it a drift only calls the callbacks.
+func NewFeedbackBloom(cap int, maxFalsePositiveRate float64, minSamples int, rebuild RebuildFunc) (*FeedbackBloom, error) {
+	if minSamples < 1 {
+		return nil, newParameterError("minSamples", minSamples, "minimum number of samples cannot be less than 1")
+	}
+	b, err := NewBigBloomAlloc(cap, maxFalsePositiveRate)
+	if err != nil {
+		return nil, err
+	}
+	return &FeedbackBloom{
+		b:                    b,
+		cap:                  cap,
+		maxFalsePositiveRate: maxFalsePositiveRate,
+		minSamples:           minSamples,
+		rebuild:              rebuild,
+		generateKey:          GenerateKey,
+		negatives:            0,
+		falsePositives:       0,
+		callbacks:            nil,
+		drifts:               0,
+		rebuilds:             0,
+	}, nil
+}
+
+//
+// Methods
+//
+
+// Registers fn to be called after every drift, once the filter has been rebuilt.
+// Callbacks are called in the order they were registered.
+func (f *FeedbackBloom) OnDrift(fn func(DriftEvent)) {
+	f.callbacks = append(f.callbacks, fn)
+}
+
+// Inserts string element into the filter
+func (f *FeedbackBloom) PutStr(s string) (*FeedbackBloom, error) {
+	bs := []byte(s)
+	return f.PutBytes(bs)
+}
+
+// Inserts bytes element into the filter
+func (f *FeedbackBloom) PutBytes(bs []byte) (*FeedbackBloom, error) {
+	_, err := f.b.PutBytes(bs)
+	return f, err
+}
+
+// Checks for existance of a string in the filter. Returns boolean and false positive rate.
+func (f *FeedbackBloom) ExistsStr(s string) (bool, float64) {
+	bs := []byte(s)
+	return f.ExistsBytes(bs)
+}
+
+// Checks for existance of bytes element in the filter. Returns boolean and false positive rate.
+// A negative answer counts towards the observed false positive rate.
+func (f *FeedbackBloom) ExistsBytes(bs []byte) (bool, float64) {
+	exists, acc := f.b.ExistsBytes(bs)
+	if !exists {
+		f.negatives++
+	}
+	return exists, acc
+}
+
+// Reports that the filter answered true for a string that the source of truth does not contain
+func (f *FeedbackBloom) ReportFalsePositiveStr(s string) error {
+	bs := []byte(s)
+	return f.ReportFalsePositiveBytes(bs)
+}
+
+// Reports that the filter answered true for bytes that the source of truth does not contain.
+// Returns an error if the filter does not contain bs, or if the report makes the filter drift and the rebuild fails.
+func (f *FeedbackBloom) ReportFalsePositiveBytes(bs []byte) error {
+	if exists, _ := f.b.ExistsBytes(bs); !exists {
+		return errors.New("entry is not in the filter so it cannot be a false positive")
+	}
+	f.falsePositives++
+	if f.samples() < f.minSamples || f.Observed() <= f.maxFalsePositiveRate {
+		return nil
+	}
+	return f.drift()
+}
+
+// Fills a fresh filter with the RebuildFunc and replaces the live filter with it. Starts a new window.
+// The fresh filter hashes with a new random key, so the false positives of the live filter are unlikely to be false positives of the fresh one.
+// The live filter is kept if the rebuild fails.
+func (f *FeedbackBloom) Rebuild() error {
+	if f.rebuild == nil {
+		return errors.New("feedback bloom filter has no rebuild function")
+	}
+	b, err := NewBigBloomAlloc(f.cap, f.maxFalsePositiveRate)
+	if err != nil {
+		return err
+	}
+	key, err := f.generateKey()
+	if err != nil {
+		return fmt.Errorf("failed to generate key: %w", err)
+	}
+	if err := b.AddKey(key); err != nil {
+		return err
+	}
+	if err := f.rebuild(b); err != nil {
+		return fmt.Errorf("failed to rebuild bloom filter: %w", err)
+	}
+	f.b = b
+	f.rebuilds++
+	f.resetWindow()
+	return nil
+}
+
+// Get observed false positive rate in the current window. 0 before any check of an entry not in the filter.
+func (f *FeedbackBloom) Observed() float64 {
+	samples := f.samples()
+	if samples == 0 {
+		return 0
+	}
+	return float64(f.falsePositives) / float64(samples)
+}
+
+// Get false positive rate promised by the live filter
+func (f *FeedbackBloom) Accuracy() float64 {
+	return f.b.Accuracy()
+}
+
+// Number of unique entries in the live filter
+func (f *FeedbackBloom) N() int {
+	return f.b.n
+}
+
+// Number of reported false positives in the current window
+func (f *FeedbackBloom) FalsePositives() int {
+	return f.falsePositives
+}
+
+// Number of times the observed rate has drifted above maxFalsePositiveRate
+func (f *FeedbackBloom) Drifts() int {
+	return f.drifts
+}
+
+// Number of times the filter has been rebuilt
+func (f *FeedbackBloom) Rebuilds() int {
+	return f.rebuilds
+}
+
+// Removes all entries from the live filter and starts a new window. The numbers of drifts and rebuilds are kept
+func (f *FeedbackBloom) Reset() {
+	f.b.Reset()
+	f.resetWindow()
+}
+
+// Copies the filter and its window. Callbacks are not copied, so the copy can be used as a snapshot without calling them
+func (f *FeedbackBloom) Clone() *FeedbackBloom {
+	return &FeedbackBloom{
+		b:                    f.b.Clone(),
+		cap:                  f.cap,
+		maxFalsePositiveRate: f.maxFalsePositiveRate,
+		minSamples:           f.minSamples,
+		rebuild:              f.rebuild,
+		generateKey:          f.generateKey,
+		negatives:            f.negatives,
+		falsePositives:       f.falsePositives,
+		callbacks:            nil,
+		drifts:               f.drifts,
+		rebuilds:             f.rebuilds,
+	}
+}
+
+// Checks whether f and other answer every query the same: same live filter
+func (f *FeedbackBloom) Equal(other *FeedbackBloom) bool {
+	return f.b.Equal(other.b)
+}
+
+func (f *FeedbackBloom) String() string {
+	return fmt.Sprintf("feedback bloom filter: %d unique entries, observed false positive rate %f (%d/%d), expected %f, max %f", f.b.n, f.Observed(), f.falsePositives, f.samples(), f.b.Accuracy(), f.maxFalsePositiveRate)
+}
+
+//
+// helpers
+//
+
+// number of checks of entries not in the filter in the window
+func (f *FeedbackBloom) samples() int {
+	return f.negatives + f.falsePositives
+}
+
+// starts a new window
+func (f *FeedbackBloom) resetWindow() {
+	f.negatives = 0
+	f.falsePositives = 0
+}
+
+// rebuilds the filter if it can, calls the callbacks and starts a new window
+func (f *FeedbackBloom) drift() error {
+	event := DriftEvent{
+		Observed:             f.Observed(),
+		Expected:             f.b.Accuracy(),
+		MaxFalsePositiveRate: f.maxFalsePositiveRate,
+		FalsePositives:       f.falsePositives,
+		Samples:              f.samples(),
+		Rebuilt:              false,
+		Err:                  nil,
+	}
+	f.drifts++
+	if f.rebuild != nil {
+		event.Err = f.Rebuild()
+		event.Rebuilt = event.Err == nil
+	}
+	// a failed rebuild still starts a new window, so the callbacks are not called on every report
+	f.resetWindow()
+
+	for _, fn := range f.callbacks {
+		fn(event)
+	}
+	return event.Err
+}
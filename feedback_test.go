@@ -0,0 +1,210 @@
+package bloom
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewFeedbackBloom(t *testing.T) {
+	_, err := NewFeedbackBloom(10, .1, 0, nil)
+	assert.EqualError(t, err, "minimum number of samples cannot be less than 1")
+	_, err = NewFeedbackBloom(10, 0, 10, nil)
+	assert.NotNil(t, err)
+
+	f, err := NewFeedbackBloom(10, .1, 10, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, float64(0), f.Observed())
+	assert.Equal(t, 0, f.N())
+	assert.EqualError(t, f.Rebuild(), "feedback bloom filter has no rebuild function")
+}
+
+func TestFeedbackBloomObserved(t *testing.T) {
+	f, err := NewFeedbackBloom(1, .5, 100, nil)
+	assert.Nil(t, err)
+	_, err = f.PutStr("stored")
+	assert.Nil(t, err)
+
+	// only entries the filter answers true for can be false positives
+	missing := feedbackProbe(f.b, false)
+	assert.EqualError(t, f.ReportFalsePositiveStr(missing), "entry is not in the filter so it cannot be a false positive")
+	assert.Equal(t, 0, f.FalsePositives())
+
+	for i := 0; i < 3; i++ {
+		exists, _ := f.ExistsStr(missing)
+		assert.False(t, exists)
+	}
+	assert.Nil(t, f.ReportFalsePositiveStr(feedbackProbe(f.b, true)))
+	assert.Equal(t, 1, f.FalsePositives())
+	assert.Equal(t, .25, f.Observed())
+	assert.Equal(t, 0, f.Drifts())
+	assert.Equal(t, fmt.Sprintf("feedback bloom filter: 1 unique entries, observed false positive rate 0.250000 (1/4), expected %f, max 0.500000", f.Accuracy()), f.String())
+}
+
+func TestFeedbackBloomDrift(t *testing.T) {
+	store := []string{"a", "b", "c"}
+	rebuild := func(b *BigBloom) error {
+		for _, s := range store {
+			if _, err := b.PutStr(s); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	f, err := NewFeedbackBloom(3, .2, 5, rebuild)
+	assert.Nil(t, err)
+	// a fixed key keeps the rebuilt filter the same on every run
+	f.generateKey = func() ([BLOOM_KEY_LEN]byte, error) {
+		return [BLOOM_KEY_LEN]byte{1}, nil
+	}
+	for _, s := range store {
+		f.PutStr(s)
+	}
+	var events []DriftEvent
+	f.OnDrift(func(e DriftEvent) {
+		events = append(events, e)
+	})
+
+	// below minSamples a high observed rate is not trusted
+	falsePositive := feedbackProbe(f.b, true)
+	for i := 0; i < 4; i++ {
+		assert.Nil(t, f.ReportFalsePositiveStr(falsePositive))
+	}
+	assert.Equal(t, 0, f.Drifts())
+	assert.Equal(t, float64(1), f.Observed())
+
+	assert.Nil(t, f.ReportFalsePositiveStr(falsePositive))
+	assert.Equal(t, 1, f.Drifts())
+	assert.Equal(t, 1, f.Rebuilds())
+	assert.Equal(t, []DriftEvent{{
+		Observed:             1,
+		Expected:             events[0].Expected,
+		MaxFalsePositiveRate: .2,
+		FalsePositives:       5,
+		Samples:              5,
+		Rebuilt:              true,
+		Err:                  nil,
+	}}, events)
+	assert.Equal(t, f.Accuracy(), events[0].Expected)
+
+	// the rebuilt filter holds the store and starts a new window
+	assert.Equal(t, 3, f.N())
+	assert.Equal(t, 0, f.FalsePositives())
+	assert.Equal(t, float64(0), f.Observed())
+	for _, s := range store {
+		exists, _ := f.ExistsStr(s)
+		assert.True(t, exists)
+	}
+
+	// the rebuilt filter hashes with a new key, so the reported false positive is gone
+	assert.Equal(t, [BLOOM_KEY_LEN]byte{1}, *f.b.key)
+	exists, _ := f.ExistsStr(falsePositive)
+	assert.False(t, exists)
+}
+
+func TestFeedbackBloomResetCloneEqual(t *testing.T) {
+	f, err := NewFeedbackBloom(10, .1, 100, nil)
+	assert.Nil(t, err)
+	drifts := 0
+	f.OnDrift(func(e DriftEvent) {
+		drifts++
+	})
+	_, err = f.PutStr("a")
+	assert.Nil(t, err)
+	f.ExistsStr("missing")
+
+	c := f.Clone()
+	assert.True(t, f.Equal(c))
+	assert.Equal(t, f.Observed(), c.Observed())
+	_, err = c.PutStr("b")
+	assert.Nil(t, err)
+	assert.False(t, f.Equal(c))
+
+	// clones do not call the callbacks
+	c.minSamples = 1
+	assert.Nil(t, c.ReportFalsePositiveStr("a"))
+	assert.Equal(t, 1, c.Drifts())
+	assert.Equal(t, 0, drifts)
+
+	f.Reset()
+	assert.Equal(t, 0, f.N())
+	assert.Equal(t, float64(0), f.Observed())
+	empty, err := NewFeedbackBloom(10, .1, 100, nil)
+	assert.Nil(t, err)
+	assert.True(t, f.Equal(empty))
+}
+
+func TestFeedbackBloomDriftWithoutRebuild(t *testing.T) {
+	f, err := NewFeedbackBloom(1, .5, 1, nil)
+	assert.Nil(t, err)
+	f.PutStr("stored")
+	var events []DriftEvent
+	f.OnDrift(func(e DriftEvent) {
+		events = append(events, e)
+	})
+
+	// exactly at the max does not drift
+	f.ExistsStr(feedbackProbe(f.b, false))
+	assert.Nil(t, f.ReportFalsePositiveStr(feedbackProbe(f.b, true)))
+	assert.Empty(t, events)
+
+	assert.Nil(t, f.ReportFalsePositiveStr(feedbackProbe(f.b, true)))
+	assert.Len(t, events, 1)
+	assert.False(t, events[0].Rebuilt)
+	assert.Equal(t, 1, f.Drifts())
+	assert.Equal(t, 0, f.Rebuilds())
+	assert.Equal(t, 1, f.N())
+	assert.Equal(t, 0, f.FalsePositives())
+}
+
+func TestFeedbackBloomRebuildError(t *testing.T) {
+	storeErr := errors.New("store unavailable")
+	f, err := NewFeedbackBloom(1, .5, 1, func(b *BigBloom) error {
+		return storeErr
+	})
+	assert.Nil(t, err)
+	f.PutStr("stored")
+	var events []DriftEvent
+	f.OnDrift(func(e DriftEvent) {
+		events = append(events, e)
+	})
+
+	err = f.ReportFalsePositiveStr(feedbackProbe(f.b, true))
+	assert.True(t, errors.Is(err, storeErr))
+	assert.EqualError(t, err, "failed to rebuild bloom filter: store unavailable")
+	assert.Len(t, events, 1)
+	assert.False(t, events[0].Rebuilt)
+	assert.Equal(t, err, events[0].Err)
+
+	// the live filter is kept
+	assert.Equal(t, 1, f.N())
+	exists, _ := f.ExistsStr("stored")
+	assert.True(t, exists)
+	assert.Equal(t, 0, f.Rebuilds())
+
+	// the key of the fresh filter cannot be generated
+	keyErr := errors.New("no entropy")
+	f.generateKey = func() ([BLOOM_KEY_LEN]byte, error) {
+		return [BLOOM_KEY_LEN]byte{}, keyErr
+	}
+	err = f.Rebuild()
+	assert.True(t, errors.Is(err, keyErr))
+	assert.EqualError(t, err, "failed to generate key: no entropy")
+	assert.Equal(t, 0, f.Rebuilds())
+}
+
+//
+// helpers
+//
+
+// first probe string whose existance in b is exists
+func feedbackProbe(b *BigBloom, exists bool) string {
+	for i := 0; ; i++ {
+		s := fmt.Sprintf("probe-%d", i)
+		if found, _ := b.ExistsStr(s); found == exists {
+			return s
+		}
+	}
+}
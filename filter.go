@@ -0,0 +1,129 @@
+package bloom
+
+import (
+	"encoding"
+	"encoding/binary"
+)
+
+// Filter is a bloom filter of typed keys. Every key is converted to bytes by its encoder before hashing,
+// so the encoders must be canonical: services that encode the same key must produce the same bytes
+// whatever their platform, or their filters will not match.
+type Filter[K any] struct {
+	// filter of the encoded keys
+	b *BigBloom
+
+	// converts keys to bytes
+	encode Encoder[K]
+}
+
+// Encoder converts a key to its canonical bytes
+type Encoder[K any] func(K) ([]byte, error)
+
+// Integer is any integer type
+type Integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+//
+// Encoders
+//
+
+// Encodes integer as 8 big endian bytes of its 64-bit two's complement value,
+// so a number gives the same bytes whatever its type and the size of int on the platform.
+// Negative numbers give the same bytes as the uint64 with the same bits, for example -1 and math.MaxUint64.
+func IntegerEncoder[K Integer](key K) ([]byte, error) {
+	return binary.BigEndian.AppendUint64(nil, uint64(key)), nil
+}
+
+// Encodes string as its bytes, the same as PutStr
+func StringEncoder[K ~string](key K) ([]byte, error) {
+	return []byte(key), nil
+}
+
+// Encodes 16-byte keys such as UUIDs as their bytes
+func Bytes16Encoder[K ~[16]byte](key K) ([]byte, error) {
+	return append([]byte(nil), key[:]...), nil
+}
+
+// Encodes key with its MarshalBinary method
+func BinaryMarshalerEncoder[K encoding.BinaryMarshaler](key K) ([]byte, error) {
+	return key.MarshalBinary()
+}
+
+//
+// Constructors
+//
+
+// Constructs filter of keys encoded by encode. opts are the same as New
+func NewFilter[K any](encode Encoder[K], opts ...Option) (*Filter[K], error) {
+	if encode == nil {
+		return nil, newParameterError("encode", nil, "encoder cannot be nil")
+	}
+	b, err := New(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Filter[K]{
+		b:      b,
+		encode: encode,
+	}, nil
+}
+
+//
+// Methods
+//
+
+// Inserts key into filter. Returns an error if key cannot be encoded or a constraint is violated.
+func (f *Filter[K]) Put(key K) (*Filter[K], error) {
+	bs, err := f.encode(key)
+	if err != nil {
+		return f, err
+	}
+	if _, err := f.b.PutBytes(bs); err != nil {
+		return f, err
+	}
+	return f, nil
+}
+
+// Checks for existance of key in filter. Returns boolean and false positive rate, or an error if key cannot be encoded.
+func (f *Filter[K]) Exists(key K) (bool, float64, error) {
+	bs, err := f.encode(key)
+	if err != nil {
+		return false, 1, err
+	}
+	exists, acc := f.b.ExistsBytes(bs)
+	return exists, acc, nil
+}
+
+// Get false positive rate
+func (f *Filter[K]) Accuracy() float64 {
+	return f.b.Accuracy()
+}
+
+// Underlying bloom filter of the encoded keys, for serializing or querying with encoded bytes
+func (f *Filter[K]) BigBloom() *BigBloom {
+	return f.b
+}
+
+// Removes all keys
+func (f *Filter[K]) Reset() {
+	f.b.Reset()
+}
+
+// Copies the filter. The copy encodes keys with the same encoder
+func (f *Filter[K]) Clone() *Filter[K] {
+	return &Filter[K]{
+		b:      f.b.Clone(),
+		encode: f.encode,
+	}
+}
+
+// Checks whether f and other answer every query the same: same underlying filter.
+// Encoders are functions and cannot be compared, so filters of the same K are assumed to encode keys the same
+func (f *Filter[K]) Equal(other *Filter[K]) bool {
+	return f.b.Equal(other.b)
+}
+
+func (f *Filter[K]) String() string {
+	return f.b.String()
+}
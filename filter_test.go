@@ -0,0 +1,156 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type userID uint32
+
+type uuid [16]byte
+
+type point struct {
+	x, y int32
+}
+
+func (p point) MarshalBinary() ([]byte, error) {
+	if p.x < 0 {
+		return nil, errors.New("negative x")
+	}
+	bs := binary.BigEndian.AppendUint32(nil, uint32(p.x))
+	return binary.BigEndian.AppendUint32(bs, uint32(p.y)), nil
+}
+
+// the encodings are part of the wire format of filters, so they must never change
+func TestEncoders(t *testing.T) {
+	encodeHex := func(bs []byte, err error) string {
+		assert.Nil(t, err)
+		return hex.EncodeToString(bs)
+	}
+
+	assert.Equal(t, "0000000000000001", encodeHex(IntegerEncoder(uint64(1))))
+	assert.Equal(t, "0000000000000001", encodeHex(IntegerEncoder(int8(1))))
+	assert.Equal(t, "0000000000000001", encodeHex(IntegerEncoder(userID(1))))
+	assert.Equal(t, "ffffffffffffffff", encodeHex(IntegerEncoder(-1)))
+	assert.Equal(t, "ffffffffffffffff", encodeHex(IntegerEncoder(int16(-1))))
+	assert.Equal(t, "ffffffffffffffff", encodeHex(IntegerEncoder(uint64(math.MaxUint64))))
+	assert.Equal(t, "0102030405060708", encodeHex(IntegerEncoder(0x0102030405060708)))
+	assert.Equal(t, "68656c6c6f", encodeHex(StringEncoder("hello")))
+	assert.Equal(t, "000102030405060708090a0b0c0d0e0f", encodeHex(Bytes16Encoder(uuid{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15})))
+	assert.Equal(t, "0000000100000002", encodeHex(BinaryMarshalerEncoder(point{x: 1, y: 2})))
+}
+
+func TestNewFilter(t *testing.T) {
+	_, err := NewFilter[uint64](nil, WithCapacity(100), WithFalsePositiveRate(.01))
+	assert.EqualError(t, err, "encoder cannot be nil")
+	_, err = NewFilter(IntegerEncoder[uint64], WithCapacity(100))
+	assert.EqualError(t, err, "exactly two of capacity, false positive rate, length and k must be given")
+}
+
+func TestFilterPutExists(t *testing.T) {
+	f, err := NewFilter(IntegerEncoder[uint64], WithCapacity(1000), WithFalsePositiveRate(.01), WithConstraints())
+	assert.Nil(t, err)
+	for i := uint64(0); i < 1000; i++ {
+		_, err := f.Put(i)
+		assert.Nil(t, err)
+	}
+	for i := uint64(0); i < 1000; i++ {
+		exists, acc, err := f.Exists(i)
+		assert.Nil(t, err)
+		assert.True(t, exists)
+		assert.Equal(t, f.Accuracy(), acc)
+	}
+
+	// keys match bytes encoded elsewhere
+	exists, _ := f.BigBloom().ExistsBytes([]byte{0, 0, 0, 0, 0, 0, 0, 7})
+	assert.True(t, exists)
+	assert.Equal(t, f.BigBloom().String(), f.String())
+
+	// integer keys of other types and sizes match the same filter
+	other, err := NewFilter(IntegerEncoder[int32], WithBytes(f.BigBloom().Bytes()), WithK(f.BigBloom().k))
+	assert.Nil(t, err)
+	exists, _, err = other.Exists(int32(7))
+	assert.Nil(t, err)
+	assert.True(t, exists)
+}
+
+func TestFilterResetCloneEqual(t *testing.T) {
+	f, err := NewFilter(IntegerEncoder[uint64], WithCapacity(100), WithFalsePositiveRate(.01))
+	assert.Nil(t, err)
+	_, err = f.Put(1)
+	assert.Nil(t, err)
+
+	c := f.Clone()
+	assert.True(t, f.Equal(c))
+	_, err = c.Put(2)
+	assert.Nil(t, err)
+	assert.False(t, f.Equal(c))
+	exists, _, _ := f.Exists(2)
+	assert.False(t, exists)
+
+	c.Reset()
+	exists, _, _ = c.Exists(1)
+	assert.False(t, exists)
+	f.Reset()
+	assert.True(t, f.Equal(c))
+}
+
+func TestFilterKeyTypes(t *testing.T) {
+	// strings match PutStr
+	strs, err := NewFilter(StringEncoder[string], WithLength(128), WithK(3))
+	assert.Nil(t, err)
+	_, err = strs.Put("hello")
+	assert.Nil(t, err)
+	b, err := NewBigBloomFromK(128, 3)
+	assert.Nil(t, err)
+	b.PutStr("hello")
+	assert.Equal(t, b.Hex(), strs.BigBloom().Hex())
+
+	uuids, err := NewFilter(Bytes16Encoder[uuid], WithLength(128), WithK(3))
+	assert.Nil(t, err)
+	_, err = uuids.Put(uuid{1})
+	assert.Nil(t, err)
+	exists, _, _ := uuids.Exists(uuid{1})
+	assert.True(t, exists)
+
+	points, err := NewFilter(BinaryMarshalerEncoder[point], WithLength(128), WithK(3))
+	assert.Nil(t, err)
+	_, err = points.Put(point{x: 1, y: 2})
+	assert.Nil(t, err)
+	exists, _, _ = points.Exists(point{x: 1, y: 2})
+	assert.True(t, exists)
+	// encoding errors are returned
+	_, err = points.Put(point{x: -1})
+	assert.EqualError(t, err, "negative x")
+	_, _, err = points.Exists(point{x: -1})
+	assert.EqualError(t, err, "negative x")
+
+	// custom encoder
+	custom, err := NewFilter(func(p point) ([]byte, error) {
+		return []byte(strconv.Itoa(int(p.x)) + "," + strconv.Itoa(int(p.y))), nil
+	}, WithLength(128), WithK(3))
+	assert.Nil(t, err)
+	_, err = custom.Put(point{x: 1, y: 2})
+	assert.Nil(t, err)
+	exists, _ = custom.BigBloom().ExistsStr("1,2")
+	assert.True(t, exists)
+}
+
+//
+// Benchmarks
+//
+
+func BenchmarkFilterPutUint64(b *testing.B) {
+	f, err := NewFilter(IntegerEncoder[uint64], WithCapacity(b.N+1), WithFalsePositiveRate(.01))
+	assert.Nil(b, err)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f.Put(uint64(i))
+	}
+}
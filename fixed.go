@@ -0,0 +1,276 @@
+package bloom
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// FixedSize is the bytes of a fixed-size bloom filter
+type FixedSize interface {
+	~[64]byte | ~[256]byte | ~[1024]byte | ~[2048]byte | ~[4096]byte
+}
+
+// FixedBloom is a bloom filter stored in a byte array of size A, so it can live on the stack and inserts and checks never allocate on the heap.
+// Indexes are derived from the size of A with the same SHA256 hashing with a nonce as BigBloom, so the bytes of a FixedBloom
+// can be loaded into a BigBloom of the same length with NewBigBloomFromBytes.
+type FixedBloom[A FixedSize] struct {
+	// current number of unique entries
+	n int
+
+	// number of hash functions
+	k int
+
+	// bloom filter bytes
+	bs A
+
+	// optional, maximum number of unique entries allowed
+	cap *int
+
+	// optional, the maximum allowed false positive rate until no more entries accepted
+	maxFalsePositiveRate *float64
+
+	// is loaded using FromBytes. This is used to ignore accuracy calculations
+	isLoaded bool
+}
+
+// 512-bit fixed-size bloom filter. Unlike Bloom, it hashes the same way as BigBloom
+type Bloom64 = FixedBloom[[64]byte]
+
+// 2048-bit fixed-size bloom filter
+type Bloom256 = FixedBloom[[256]byte]
+
+// 8192-bit fixed-size bloom filter
+type Bloom1K = FixedBloom[[1024]byte]
+
+// 16384-bit fixed-size bloom filter
+type Bloom2K = FixedBloom[[2048]byte]
+
+// 32768-bit fixed-size bloom filter
+type Bloom4K = FixedBloom[[4096]byte]
+
+//
+// Constructors
+//
+
+// Constructs fixed-size bloom filter from k.
+func NewFixedBloomFromK[A FixedSize](k int) (*FixedBloom[A], error) {
+	if err := checkK(k); err != nil {
+		return nil, err
+	}
+	return &FixedBloom[A]{
+		n:                    0,
+		k:                    k,
+		maxFalsePositiveRate: nil,
+		cap:                  nil,
+		isLoaded:             false,
+	}, nil
+}
+
+// Constructs fixed-size bloom filter from capacity
+func NewFixedBloomFromCap[A FixedSize](cap int) (*FixedBloom[A], error) {
+	if cap < 1 {
+		return nil, newParameterError("cap", cap, "capacity cannot be less than 1")
+	}
+	var bs A
+	return &FixedBloom[A]{
+		n:                    0,
+		k:                    calcKFromCap(len(bs), cap),
+		maxFalsePositiveRate: nil,
+		cap:                  nil,
+		isLoaded:             false,
+	}, nil
+}
+
+// Constructs fixed-size bloom filter from maxFalsePositiveRate
+func NewFixedBloomFromAcc[A FixedSize](maxFalsePositiveRate float64) (*FixedBloom[A], error) {
+	if maxFalsePositiveRate <= 0 || maxFalsePositiveRate >= 1 {
+		return nil, newParameterError("maxFalsePositiveRate", maxFalsePositiveRate, "false positive rate must be between 0 and 1")
+	}
+	var bs A
+	return &FixedBloom[A]{
+		n:                    0,
+		k:                    calcKFromAcc(len(bs), maxFalsePositiveRate),
+		maxFalsePositiveRate: nil,
+		cap:                  nil,
+		isLoaded:             false,
+	}, nil
+}
+
+// Load fixed-size bloom filter from bytes of bloom filter and k
+// This mechanism will disable accuracy calculations because n is unknown
+func NewFixedBloomFromBytes[A FixedSize](bs A, k int) (*FixedBloom[A], error) {
+	if err := checkK(k); err != nil {
+		return nil, err
+	}
+	return &FixedBloom[A]{
+		n:                    0,
+		k:                    k,
+		bs:                   bs,
+		maxFalsePositiveRate: nil,
+		cap:                  nil,
+		isLoaded:             true,
+	}, nil
+}
+
+//
+// Methods
+//
+
+// Inserts string element into bloom filter. Returns an error if a constraint is violated.
+func (b *FixedBloom[A]) PutStr(s string) (*FixedBloom[A], error) {
+	bs := []byte(s)
+	return b.PutBytes(bs)
+}
+
+// Inserts bytes element into bloom filter. Returns an error if a constraint is violated.
+func (b *FixedBloom[A]) PutBytes(bs []byte) (*FixedBloom[A], error) {
+	// if exists already just return filter and don't increase n
+	if exists, _ := b.ExistsBytes(bs); exists {
+		return b, nil
+	}
+
+	if b.cap != nil && b.n == *b.cap {
+		return b, &CapacityError{Cap: *b.cap, N: b.n}
+	}
+
+	if b.maxFalsePositiveRate != nil {
+		if projected := falsePositiveRate(len(b.bs), b.n+1, b.k); projected > *b.maxFalsePositiveRate {
+			return b, &AccuracyError{MaxFalsePositiveRate: *b.maxFalsePositiveRate, N: b.n, FalsePositiveRate: projected}
+		}
+	}
+
+	totBits := uint64(8 * len(b.bs))
+	for i := 0; i < b.k; i++ {
+		bitI := hashIndex(bs, i, totBits)
+		// set bit to 1
+		b.bs[bitI>>3] |= 1 << (bitI & 7)
+	}
+	b.n++
+	return b, nil
+}
+
+// Checks for existance of a string in a bloom filter. Returns boolean and false positive rate.
+func (b *FixedBloom[A]) ExistsStr(s string) (bool, float64) {
+	bs := []byte(s)
+	return b.ExistsBytes(bs)
+}
+
+// Checks for existance of bytes element in a bloom filter. Returns boolean and false positive rate.
+func (b *FixedBloom[A]) ExistsBytes(bs []byte) (bool, float64) {
+	totBits := uint64(8 * len(b.bs))
+	for i := 0; i < b.k; i++ {
+		bitI := hashIndex(bs, i, totBits)
+		// it doesn't exists if the bit is 0
+		if b.bs[bitI>>3]&(1<<(bitI&7)) == 0 {
+			return false, 1
+		}
+	}
+	return true, b.Accuracy()
+}
+
+// Get false positive rate
+// -1 means cannot be calcuated because it is loaded in
+func (b *FixedBloom[A]) Accuracy() float64 {
+	if b.isLoaded {
+		return -1
+	}
+	if b.n == 0 {
+		return 1
+	}
+	return falsePositiveRate(len(b.bs), b.n, b.k)
+}
+
+// Constrains bloom from not adding more than cap insertions
+func (b *FixedBloom[A]) AddCapacityConstraint(cap int) error {
+	if b.isLoaded {
+		return ErrLoaded
+	}
+	if cap < 1 {
+		return newParameterError("cap", cap, "capacity cannot be less than 1")
+	}
+	if b.maxFalsePositiveRate != nil {
+		// check if contraints capacity and maxFalsePositiveRate are compatible together with this size bloom filter
+		if !constraintsCompatible(len(b.bs), cap, b.k, *b.maxFalsePositiveRate) {
+			return &ConstraintError{Cap: cap, MaxFalsePositiveRate: *b.maxFalsePositiveRate, FalsePositiveRate: falsePositiveRate(len(b.bs), cap, b.k)}
+		}
+	}
+	b.cap = &cap
+	return nil
+}
+
+// Constrains bloom from not adding insertions that would cause accuracy to be worse than maxFalsePositiveRate
+func (b *FixedBloom[A]) AddAccuracyConstraint(maxFalsePositiveRate float64) error {
+	if b.isLoaded {
+		return ErrLoaded
+	}
+	if maxFalsePositiveRate <= 0 || maxFalsePositiveRate >= 1 {
+		return newParameterError("maxFalsePositiveRate", maxFalsePositiveRate, "false positive rate must be between 0 and 1")
+	}
+	if b.cap != nil {
+		// check if contraints capacity and maxFalsePositiveRate are compatible together with this size bloom filter
+		if !constraintsCompatible(len(b.bs), *b.cap, b.k, maxFalsePositiveRate) {
+			return &ConstraintError{Cap: *b.cap, MaxFalsePositiveRate: maxFalsePositiveRate, FalsePositiveRate: falsePositiveRate(len(b.bs), *b.cap, b.k)}
+		}
+	}
+	b.maxFalsePositiveRate = &maxFalsePositiveRate
+	return nil
+}
+
+// Removes all entries, keeping k and the constraints. A filter loaded from bytes is empty after a reset, so its accuracy is calculated again.
+func (b *FixedBloom[A]) Reset() {
+	var bs A
+	b.bs = bs
+	b.n = 0
+	b.isLoaded = false
+}
+
+// Deep copies bloom filter, including its constraints
+func (b *FixedBloom[A]) Clone() *FixedBloom[A] {
+	cap, maxFalsePositiveRate := cloneConstraints(b.cap, b.maxFalsePositiveRate)
+	return &FixedBloom[A]{
+		n:                    b.n,
+		k:                    b.k,
+		bs:                   b.bs,
+		maxFalsePositiveRate: maxFalsePositiveRate,
+		cap:                  cap,
+		isLoaded:             b.isLoaded,
+	}
+}
+
+// Checks whether b and other answer every query the same
+func (b *FixedBloom[A]) Equal(other *FixedBloom[A]) bool {
+	return b.k == other.k && b.bs == other.bs
+}
+
+func (b *FixedBloom[A]) String() string {
+	var buf strings.Builder
+
+	buf.WriteString(fmt.Sprintf("%d-bit bloom filter: %d unique entries", 8*len(b.bs), b.n))
+	if b.cap != nil {
+		buf.WriteString(fmt.Sprintf(", max cap %d", *b.cap))
+	}
+	if b.maxFalsePositiveRate != nil {
+		buf.WriteString(fmt.Sprintf(", max false positive rate %f", *b.maxFalsePositiveRate))
+	}
+	if b.cap == nil && b.maxFalsePositiveRate == nil {
+		buf.WriteString(", no constraints")
+	}
+
+	return buf.String()
+}
+
+// converts bytes of bloom filter to hex string
+func (b *FixedBloom[A]) Hex() string {
+	// arrays of different sizes cannot be sliced generically, so the bytes are copied one at a time
+	bs := make([]byte, len(b.bs))
+	for i := range bs {
+		bs[i] = b.bs[i]
+	}
+	return hex.EncodeToString(bs)
+}
+
+// Get bytes of bloom filter. Can be loaded back in with NewFixedBloomFromBytes
+func (b *FixedBloom[A]) Bytes() A {
+	return b.bs
+}
@@ -0,0 +1,147 @@
+package bloom
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewFixedBloom(t *testing.T) {
+	_, err := NewFixedBloomFromK[[256]byte](0)
+	assert.EqualError(t, err, "k cannot be less than 1")
+	_, err = NewFixedBloomFromCap[[256]byte](0)
+	assert.EqualError(t, err, "capacity cannot be less than 1")
+	_, err = NewFixedBloomFromAcc[[256]byte](1)
+	assert.EqualError(t, err, "false positive rate must be between 0 and 1")
+	_, err = NewFixedBloomFromBytes([256]byte{}, 0)
+	assert.EqualError(t, err, "k cannot be less than 1")
+
+	// k is derived from the size
+	small, err := NewFixedBloomFromCap[[64]byte](100)
+	assert.Nil(t, err)
+	assert.Equal(t, calcKFromCap(64, 100), small.k)
+	large, err := NewFixedBloomFromCap[[4096]byte](100)
+	assert.Nil(t, err)
+	assert.Equal(t, calcKFromCap(4096, 100), large.k)
+	acc, err := NewFixedBloomFromAcc[[1024]byte](.01)
+	assert.Nil(t, err)
+	assert.Equal(t, calcKFromAcc(1024, .01), acc.k)
+}
+
+func TestFixedBloomSizes(t *testing.T) {
+	testFixedBloom[[64]byte](t, "512-bit bloom filter: 10 unique entries, no constraints")
+	testFixedBloom[[256]byte](t, "2048-bit bloom filter: 10 unique entries, no constraints")
+	testFixedBloom[[1024]byte](t, "8192-bit bloom filter: 10 unique entries, no constraints")
+	testFixedBloom[[2048]byte](t, "16384-bit bloom filter: 10 unique entries, no constraints")
+	testFixedBloom[[4096]byte](t, "32768-bit bloom filter: 10 unique entries, no constraints")
+}
+
+// inserts and checks entries in a fixed-size bloom filter and compares it to a BigBloom of the same length
+func testFixedBloom[A FixedSize](t *testing.T, expectedString string) {
+	b, err := NewFixedBloomFromK[A](3)
+	assert.Nil(t, err)
+	var bs A
+	big, err := NewBigBloomFromK(len(bs), 3)
+	assert.Nil(t, err)
+	for i := 0; i < 10; i++ {
+		_, err = b.PutStr(strconv.Itoa(i))
+		assert.Nil(t, err)
+		big.PutStr(strconv.Itoa(i))
+	}
+	for i := 0; i < 10; i++ {
+		exists, acc := b.ExistsStr(strconv.Itoa(i))
+		assert.True(t, exists)
+		assert.Equal(t, falsePositiveRate(len(bs), 10, 3), acc)
+	}
+	assert.Equal(t, expectedString, b.String())
+
+	// same bits as BigBloom
+	assert.Equal(t, big.Hex(), b.Hex())
+
+	// bytes round trip
+	loaded, err := NewFixedBloomFromBytes(b.Bytes(), 3)
+	assert.Nil(t, err)
+	assert.True(t, b.Equal(loaded))
+	assert.Equal(t, float64(-1), loaded.Accuracy())
+	assert.ErrorIs(t, loaded.AddCapacityConstraint(10), ErrLoaded)
+
+	// clone and reset
+	c := b.Clone()
+	assert.Equal(t, b, c)
+	c.Reset()
+	assert.Equal(t, bs, c.Bytes())
+	assert.False(t, b.Equal(c))
+	assert.Equal(t, 10, b.n)
+}
+
+func TestFixedBloomConstraints(t *testing.T) {
+	b, err := NewFixedBloomFromK[[64]byte](3)
+	assert.Nil(t, err)
+	assert.Nil(t, b.AddCapacityConstraint(2))
+	err = b.AddAccuracyConstraint(.0000001)
+	assert.ErrorIs(t, err, ErrIncompatibleConstraints)
+	_, err = b.PutStr("a")
+	assert.Nil(t, err)
+	_, err = b.PutStr("b")
+	assert.Nil(t, err)
+	_, err = b.PutStr("c")
+	assert.EqualError(t, err, "failed to add entry: bloom filter at max capacity 2")
+
+	b, err = NewFixedBloomFromK[[64]byte](3)
+	assert.Nil(t, err)
+	assert.Nil(t, b.AddAccuracyConstraint(.01))
+	for i := 0; err == nil; i++ {
+		_, err = b.PutStr(strconv.Itoa(i))
+	}
+	assert.ErrorIs(t, err, ErrAccuracy)
+	assert.LessOrEqual(t, b.Accuracy(), .01)
+}
+
+func TestFixedBloomZeroAlloc(t *testing.T) {
+	key := []byte("request-id")
+	allocs := testing.AllocsPerRun(100, func() {
+		var b Bloom2K
+		b.k = 3
+		b.PutBytes(key)
+		b.ExistsBytes(key)
+	})
+	assert.Equal(t, float64(0), allocs)
+
+	b, err := NewFixedBloomFromAcc[[4096]byte](.01)
+	assert.Nil(t, err)
+	allocs = testing.AllocsPerRun(100, func() {
+		b.PutBytes(key)
+		b.ExistsBytes(key)
+	})
+	assert.Equal(t, float64(0), allocs)
+}
+
+//
+// Benchmarks
+//
+
+// benchmark for exists for each size
+func BenchmarkFixedBloomExistsBytes(b *testing.B) {
+	b.Run("64", benchmarkFixedBloomExistsBytes[[64]byte])
+	b.Run("256", benchmarkFixedBloomExistsBytes[[256]byte])
+	b.Run("1024", benchmarkFixedBloomExistsBytes[[1024]byte])
+	b.Run("2048", benchmarkFixedBloomExistsBytes[[2048]byte])
+	b.Run("4096", benchmarkFixedBloomExistsBytes[[4096]byte])
+}
+
+func benchmarkFixedBloomExistsBytes[A FixedSize](b *testing.B) {
+	bloom, err := NewFixedBloomFromK[A](3)
+	assert.Nil(b, err)
+	keys := make([][]byte, 100)
+	for j := range keys {
+		keys[j] = []byte(fmt.Sprint(j))
+		bloom.PutBytes(keys[j])
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bloom.ExistsBytes(keys[i%len(keys)])
+	}
+}
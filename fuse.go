@@ -0,0 +1,410 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// BinaryFuse8 is a binary fuse filter (Graf & Lemire, 2022) with 8-bit fingerprints for static sets.
+// It is built once from all of its keys, cannot be added to, and has a false positive rate of 1/256
+// using 9 to 9.5 bits per key for large sets, compared to the 11.5 bits per key a BigBloom needs for the same rate.
+type BinaryFuse8 struct {
+	binaryFuse[uint8]
+}
+
+// BinaryFuse16 is a binary fuse filter with 16-bit fingerprints for static sets.
+// It has a false positive rate of 1/65536 using 18 to 19 bits per key for large sets.
+type BinaryFuse16 struct {
+	binaryFuse[uint16]
+}
+
+type fuseFingerprint interface {
+	~uint8 | ~uint16
+}
+
+// binaryFuse holds the implementation shared by all fingerprint sizes.
+// Every key maps to one cell in each of 3 consecutive segments and the xor of those cells is the key's fingerprint.
+type binaryFuse[T fuseFingerprint] struct {
+	// seed mixed into the key hashes. Construction retries with a new seed until every key can be placed
+	seed uint64
+
+	// number of cells per segment, a power of 2
+	segmentLength uint32
+
+	// segmentLength - 1
+	segmentLengthMask uint32
+
+	// number of segments a key's first cell can be in
+	segmentCount uint32
+
+	// segmentCount * segmentLength
+	segmentCountLength uint32
+
+	// number of unique keys
+	n int
+
+	// (segmentCount + 2) * segmentLength fingerprint cells
+	fingerprints []T
+}
+
+// number of cells each key maps to
+const fuseArity = 3
+
+// number of seeds tried before construction gives up. Each attempt fails with probability well below 1%
+const fuseMaxAttempts = 100
+
+//
+// Constructors
+//
+
+// Constructs binary fuse filter with 8-bit fingerprints from keys. Duplicate keys are ignored.
+func NewBinaryFuse8(keys [][]byte) (*BinaryFuse8, error) {
+	f := &BinaryFuse8{}
+	if err := f.populate(keys); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Constructs binary fuse filter with 16-bit fingerprints from keys. Duplicate keys are ignored.
+func NewBinaryFuse16(keys [][]byte) (*BinaryFuse16, error) {
+	f := &BinaryFuse16{}
+	if err := f.populate(keys); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+//
+// Methods
+//
+
+// Checks for existance of a string in the filter
+func (f *binaryFuse[T]) ContainsStr(s string) bool {
+	bs := []byte(s)
+	return f.Contains(bs)
+}
+
+// Checks for existance of bytes element in the filter
+func (f *binaryFuse[T]) Contains(bs []byte) bool {
+	hash := fuseMix(keyHash(bs), f.seed)
+	h0, h1, h2 := f.indices(hash)
+	return fuseFingerprintOf[T](hash)^f.fingerprints[h0]^f.fingerprints[h1]^f.fingerprints[h2] == 0
+}
+
+// Get false positive rate: 1/2^(fingerprint bits)
+func (f *binaryFuse[T]) Accuracy() float64 {
+	return 1 / math.Pow(2, float64(f.fingerprintBits()))
+}
+
+// Number of unique keys the filter was built from
+func (f *binaryFuse[T]) N() int {
+	return f.n
+}
+
+// Size of the fingerprint cells in bytes
+func (f *binaryFuse[T]) SizeBytes() int {
+	return len(f.fingerprints) * f.fingerprintBits() / 8
+}
+
+// Bits of storage per key
+func (f *binaryFuse[T]) BitsPerKey() float64 {
+	if f.n == 0 {
+		return 0
+	}
+	return float64(8*f.SizeBytes()) / float64(f.n)
+}
+
+// Copies the filter. A binary fuse filter is built once from all of its keys, so it has no Reset
+func (f *BinaryFuse8) Clone() *BinaryFuse8 {
+	return &BinaryFuse8{f.clone()}
+}
+
+// Checks whether f and other answer every query the same: same seed, segments and fingerprints
+func (f *BinaryFuse8) Equal(other *BinaryFuse8) bool {
+	return f.equal(&other.binaryFuse)
+}
+
+// Copies the filter. A binary fuse filter is built once from all of its keys, so it has no Reset
+func (f *BinaryFuse16) Clone() *BinaryFuse16 {
+	return &BinaryFuse16{f.clone()}
+}
+
+// Checks whether f and other answer every query the same: same seed, segments and fingerprints
+func (f *BinaryFuse16) Equal(other *BinaryFuse16) bool {
+	return f.equal(&other.binaryFuse)
+}
+
+// Encodes filter as: fingerprint bits, seed, segment length, segment count, n and the fingerprints. Integers are big endian.
+func (f *binaryFuse[T]) MarshalBinary() ([]byte, error) {
+	bs := make([]byte, 0, 1+8+4+4+8+f.SizeBytes())
+	bs = append(bs, byte(f.fingerprintBits()))
+	bs = binary.BigEndian.AppendUint64(bs, f.seed)
+	bs = binary.BigEndian.AppendUint32(bs, f.segmentLength)
+	bs = binary.BigEndian.AppendUint32(bs, f.segmentCount)
+	bs = binary.BigEndian.AppendUint64(bs, uint64(f.n))
+	for _, fp := range f.fingerprints {
+		if f.fingerprintBits() == 8 {
+			bs = append(bs, byte(fp))
+		} else {
+			bs = binary.BigEndian.AppendUint16(bs, uint16(fp))
+		}
+	}
+	return bs, nil
+}
+
+// Decodes filter encoded with MarshalBinary
+func (f *binaryFuse[T]) UnmarshalBinary(bs []byte) error {
+	const header = 1 + 8 + 4 + 4 + 8
+	if len(bs) < header {
+		return newDecodeError("binary fuse", "binary fuse encoding too short")
+	}
+	if int(bs[0]) != f.fingerprintBits() {
+		return newDecodeError("binary fuse", fmt.Sprintf("binary fuse encoding has %d-bit fingerprints, expected %d-bit", bs[0], f.fingerprintBits()))
+	}
+	decoded := binaryFuse[T]{
+		seed:          binary.BigEndian.Uint64(bs[1:9]),
+		segmentLength: binary.BigEndian.Uint32(bs[9:13]),
+		segmentCount:  binary.BigEndian.Uint32(bs[13:17]),
+		n:             int(binary.BigEndian.Uint64(bs[17:25])),
+	}
+	if decoded.n < 0 {
+		return newDecodeError("binary fuse", "binary fuse encoding has invalid number of keys")
+	}
+	if decoded.segmentLength == 0 || decoded.segmentLength&(decoded.segmentLength-1) != 0 || decoded.segmentCount == 0 {
+		return newDecodeError("binary fuse", "binary fuse encoding has invalid segments")
+	}
+	// in uint64 so a crafted header cannot wrap around to a small number of cells. Indexes are uint32, so cells must fit
+	cells := (uint64(decoded.segmentCount) + fuseArity - 1) * uint64(decoded.segmentLength)
+	if cells > math.MaxUint32 {
+		return newDecodeError("binary fuse", "binary fuse encoding has invalid segments")
+	}
+	decoded.segmentLengthMask = decoded.segmentLength - 1
+	decoded.segmentCountLength = decoded.segmentCount * decoded.segmentLength
+	bs = bs[header:]
+	fpBytes := uint64(f.fingerprintBits() / 8)
+	if uint64(len(bs)) != cells*fpBytes {
+		return newDecodeError("binary fuse", "binary fuse encoding has wrong number of fingerprints")
+	}
+	decoded.fingerprints = make([]T, cells)
+	for i := range decoded.fingerprints {
+		if fpBytes == 1 {
+			decoded.fingerprints[i] = T(bs[i])
+		} else {
+			decoded.fingerprints[i] = T(binary.BigEndian.Uint16(bs[2*i:]))
+		}
+	}
+	*f = decoded
+	return nil
+}
+
+func (f *binaryFuse[T]) String() string {
+	return fmt.Sprintf("binary fuse filter: %d keys, %d-bit fingerprints, %d bytes", f.n, f.fingerprintBits(), f.SizeBytes())
+}
+
+//
+// helpers
+//
+
+// builds the filter from keys, retrying with new seeds until peeling succeeds
+func (f *binaryFuse[T]) populate(keys [][]byte) error {
+	// hash and remove duplicates. fuseMix is a bijection, so unique hashes stay unique for every seed
+	hashes := make([]uint64, len(keys))
+	for i, key := range keys {
+		hashes[i] = keyHash(key)
+	}
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i] < hashes[j] })
+	unique := hashes[:0]
+	for i, h := range hashes {
+		if i == 0 || h != hashes[i-1] {
+			unique = append(unique, h)
+		}
+	}
+	hashes = unique
+	size := len(hashes)
+
+	f.initParameters(size)
+	capacity := len(f.fingerprints)
+
+	// count of keys in each cell shifted left by 2. The low 2 bits are the xor of which of the 3 cells (0, 1 or 2) this is for each key,
+	// so that when only one key is left it is known which of its cells this is
+	t2count := make([]uint32, capacity)
+	// xor of the mixed hashes of the keys in each cell
+	t2hash := make([]uint64, capacity)
+	// peeled keys and which of their cells they were peeled from, in peeling order
+	stackHash := make([]uint64, 0, size)
+	stackFound := make([]uint8, 0, size)
+	queue := make([]uint32, 0, capacity)
+
+	rngCounter := uint64(0)
+	for attempt := 0; ; attempt++ {
+		if attempt == fuseMaxAttempts {
+			return errors.New("failed to construct binary fuse filter: no seed found")
+		}
+		f.seed = splitmix64(&rngCounter)
+		for i := range t2count {
+			t2count[i] = 0
+			t2hash[i] = 0
+		}
+		stackHash = stackHash[:0]
+		stackFound = stackFound[:0]
+
+		for _, h := range hashes {
+			hash := fuseMix(h, f.seed)
+			h0, h1, h2 := f.indices(hash)
+			for j, cell := range [fuseArity]uint32{h0, h1, h2} {
+				t2count[cell] += 4
+				t2count[cell] ^= uint32(j)
+				t2hash[cell] ^= hash
+			}
+		}
+
+		// peel cells that hold a single key until none are left
+		queue = queue[:0]
+		for i := range t2count {
+			if t2count[i]>>2 == 1 {
+				queue = append(queue, uint32(i))
+			}
+		}
+		for len(queue) > 0 {
+			cell := queue[len(queue)-1]
+			queue = queue[:len(queue)-1]
+			if t2count[cell]>>2 != 1 {
+				continue
+			}
+			hash := t2hash[cell]
+			found := uint8(t2count[cell] & 3)
+			stackHash = append(stackHash, hash)
+			stackFound = append(stackFound, found)
+			h0, h1, h2 := f.indices(hash)
+			for j, other := range [fuseArity]uint32{h0, h1, h2} {
+				t2count[other] -= 4
+				t2count[other] ^= uint32(j)
+				t2hash[other] ^= hash
+				if t2count[other]>>2 == 1 {
+					queue = append(queue, other)
+				}
+			}
+		}
+		if len(stackHash) == size {
+			break
+		}
+	}
+
+	// assign fingerprints in reverse peeling order, so that each key's remaining cell is still free when it is set
+	for i := size - 1; i >= 0; i-- {
+		hash := stackHash[i]
+		h0, h1, h2 := f.indices(hash)
+		cells := [fuseArity]uint32{h0, h1, h2}
+		found := stackFound[i]
+		fp := fuseFingerprintOf[T](hash)
+		for j, cell := range cells {
+			if uint8(j) != found {
+				fp ^= f.fingerprints[cell]
+			}
+		}
+		f.fingerprints[cells[found]] = fp
+	}
+	f.n = size
+	return nil
+}
+
+// sets the segment sizes for size keys
+func (f *binaryFuse[T]) initParameters(size int) {
+	// segment length and size factor from the reference implementation (github.com/FastFilter/xorfilter) for arity 3
+	segmentLength := uint32(4)
+	if size > 0 {
+		segmentLength = uint32(1) << int(math.Floor(math.Log(float64(size))/math.Log(3.33)+2.25))
+	}
+	if segmentLength > 262144 {
+		segmentLength = 262144
+	}
+	capacity := uint32(0)
+	if size > 1 {
+		sizeFactor := math.Max(1.125, 0.875+0.25*math.Log(1000000)/math.Log(float64(size)))
+		capacity = uint32(math.Round(float64(size) * sizeFactor))
+	}
+	segmentCount := (capacity + segmentLength - 1) / segmentLength
+	if segmentCount <= fuseArity-1 {
+		segmentCount = 1
+	} else {
+		segmentCount -= fuseArity - 1
+	}
+
+	f.segmentLength = segmentLength
+	f.segmentLengthMask = segmentLength - 1
+	f.segmentCount = segmentCount
+	f.segmentCountLength = segmentCount * segmentLength
+	f.fingerprints = make([]T, (segmentCount+fuseArity-1)*segmentLength)
+}
+
+// calculate the 3 cells of a mixed hash. The first cell is in any segment and the others are in the next two segments
+func (f *binaryFuse[T]) indices(hash uint64) (uint32, uint32, uint32) {
+	hi, _ := bits.Mul64(hash, uint64(f.segmentCountLength))
+	h0 := uint32(hi)
+	h1 := h0 + f.segmentLength
+	h2 := h1 + f.segmentLength
+	h1 ^= uint32(hash>>18) & f.segmentLengthMask
+	h2 ^= uint32(hash) & f.segmentLengthMask
+	return h0, h1, h2
+}
+
+// number of bits in a fingerprint
+func (f *binaryFuse[T]) fingerprintBits() int {
+	// all bits set tells the size of T
+	if uint64(^T(0)) == math.MaxUint8 {
+		return 8
+	}
+	return 16
+}
+
+// calculate fingerprint from mixed hash
+func fuseFingerprintOf[T fuseFingerprint](hash uint64) T {
+	return T(hash ^ hash>>32)
+}
+
+// copy of f that does not share fingerprints
+func (f *binaryFuse[T]) clone() binaryFuse[T] {
+	c := *f
+	c.fingerprints = make([]T, len(f.fingerprints))
+	copy(c.fingerprints, f.fingerprints)
+	return c
+}
+
+// compares everything that decides the answer to a query. n only counts the keys
+func (f *binaryFuse[T]) equal(other *binaryFuse[T]) bool {
+	if f.seed != other.seed || f.segmentLength != other.segmentLength || f.segmentCount != other.segmentCount || len(f.fingerprints) != len(other.fingerprints) {
+		return false
+	}
+	for i := range f.fingerprints {
+		if f.fingerprints[i] != other.fingerprints[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// mixes seed into hash. This is a bijection of hash for a fixed seed
+func fuseMix(hash, seed uint64) uint64 {
+	// murmur3 64-bit finalizer
+	h := hash + seed
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// returns the next number of the splitmix64 sequence
+func splitmix64(state *uint64) uint64 {
+	*state += 0x9E3779B97F4A7C15
+	z := *state
+	z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+	z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+	return z ^ (z >> 31)
+}
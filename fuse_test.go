@@ -0,0 +1,224 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func fuseTestKeys(prefix string, n int) [][]byte {
+	keys := make([][]byte, n)
+	for i := range keys {
+		keys[i] = []byte(prefix + strconv.Itoa(i))
+	}
+	return keys
+}
+
+func TestNewBinaryFuse8(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 3, 10, 100, 1000, 100000} {
+		t.Run(fmt.Sprintf("keys_%d", n), func(t *testing.T) {
+			keys := fuseTestKeys("key", n)
+			f, err := NewBinaryFuse8(keys)
+			assert.Nil(t, err)
+			assert.Equal(t, n, f.N())
+			// no false negatives
+			for _, key := range keys {
+				assert.True(t, f.Contains(key))
+			}
+		})
+	}
+}
+
+func TestNewBinaryFuse16(t *testing.T) {
+	for _, n := range []int{0, 1, 10, 1000, 100000} {
+		t.Run(fmt.Sprintf("keys_%d", n), func(t *testing.T) {
+			keys := fuseTestKeys("key", n)
+			f, err := NewBinaryFuse16(keys)
+			assert.Nil(t, err)
+			assert.Equal(t, n, f.N())
+			for _, key := range keys {
+				assert.True(t, f.Contains(key))
+			}
+		})
+	}
+}
+
+func TestBinaryFuseDuplicates(t *testing.T) {
+	keys := append(fuseTestKeys("key", 100), fuseTestKeys("key", 100)...)
+	f, err := NewBinaryFuse8(keys)
+	assert.Nil(t, err)
+	assert.Equal(t, 100, f.N())
+	assert.True(t, f.ContainsStr("key0"))
+	assert.True(t, f.ContainsStr("key99"))
+}
+
+// compares size and false positive rate with a BigBloom sized for the same keys and rate
+func TestBinaryFuseComparedToBigBloom(t *testing.T) {
+	n := 100000
+	probes := 1000000
+	keys := fuseTestKeys("key", n)
+
+	type comparisonTest struct {
+		name       string
+		acc        float64
+		build      func() (interface{ Contains([]byte) bool }, int, error)
+		maxBitsKey float64
+	}
+
+	tests := []comparisonTest{
+		{
+			name: "fuse8",
+			acc:  1.0 / 256,
+			build: func() (interface{ Contains([]byte) bool }, int, error) {
+				f, err := NewBinaryFuse8(keys)
+				return f, f.SizeBytes(), err
+			},
+			maxBitsKey: 9.6,
+		},
+		{
+			name: "fuse16",
+			acc:  1.0 / 65536,
+			build: func() (interface{ Contains([]byte) bool }, int, error) {
+				f, err := NewBinaryFuse16(keys)
+				return f, f.SizeBytes(), err
+			},
+			maxBitsKey: 19.2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f, fuseBytes, err := test.build()
+			assert.Nil(t, err)
+			b, err := NewBigBloomAlloc(n, test.acc)
+			assert.Nil(t, err)
+			for _, key := range keys {
+				_, err := b.PutBytes(key)
+				assert.Nil(t, err)
+			}
+
+			// fuse filter uses less space
+			assert.Less(t, fuseBytes, b.len)
+			assert.LessOrEqual(t, float64(8*fuseBytes)/float64(n), test.maxBitsKey)
+
+			// both have close to the same false positive rate on keys never inserted
+			fuseFalsePositives := 0
+			bloomFalsePositives := 0
+			for i := 0; i < probes; i++ {
+				probe := []byte("probe" + strconv.Itoa(i))
+				if f.Contains(probe) {
+					fuseFalsePositives++
+				}
+				if exists, _ := b.ExistsBytes(probe); exists {
+					bloomFalsePositives++
+				}
+			}
+			fuseRate := float64(fuseFalsePositives) / float64(probes)
+			bloomRate := float64(bloomFalsePositives) / float64(probes)
+			assert.InDelta(t, test.acc, fuseRate, test.acc/2)
+			assert.InDelta(t, test.acc, bloomRate, test.acc/2)
+		})
+	}
+}
+
+func TestBinaryFuseMarshalBinary(t *testing.T) {
+	keys := fuseTestKeys("key", 1000)
+
+	f8, err := NewBinaryFuse8(keys)
+	assert.Nil(t, err)
+	bs, err := f8.MarshalBinary()
+	assert.Nil(t, err)
+	assert.Equal(t, 25+f8.SizeBytes(), len(bs))
+	var decoded8 BinaryFuse8
+	err = decoded8.UnmarshalBinary(bs)
+	assert.Nil(t, err)
+	assert.Equal(t, *f8, decoded8)
+	for _, key := range keys {
+		assert.True(t, decoded8.Contains(key))
+	}
+
+	f16, err := NewBinaryFuse16(keys)
+	assert.Nil(t, err)
+	bs16, err := f16.MarshalBinary()
+	assert.Nil(t, err)
+	var decoded16 BinaryFuse16
+	err = decoded16.UnmarshalBinary(bs16)
+	assert.Nil(t, err)
+	assert.Equal(t, *f16, decoded16)
+
+	// test invalid encodings
+	err = decoded8.UnmarshalBinary(bs[:10])
+	assert.EqualError(t, err, "binary fuse encoding too short")
+	err = decoded8.UnmarshalBinary(bs16)
+	assert.EqualError(t, err, "binary fuse encoding has 16-bit fingerprints, expected 8-bit")
+	err = decoded8.UnmarshalBinary(bs[:len(bs)-1])
+	assert.EqualError(t, err, "binary fuse encoding has wrong number of fingerprints")
+	bs[12] = 3
+	err = decoded8.UnmarshalBinary(bs)
+	assert.EqualError(t, err, "binary fuse encoding has invalid segments")
+
+	// segment count near 2^32 used to wrap around to zero cells, so Contains indexed an empty slice
+	header := make([]byte, 25)
+	header[0] = 8
+	binary.BigEndian.PutUint32(header[9:13], 4)
+	binary.BigEndian.PutUint32(header[13:17], math.MaxUint32-1)
+	err = decoded8.UnmarshalBinary(header)
+	assert.EqualError(t, err, "binary fuse encoding has invalid segments")
+	// a failed decode leaves the filter as it was
+	assert.Equal(t, *f8, decoded8)
+	assert.True(t, decoded8.Contains(keys[0]))
+	binary.BigEndian.PutUint32(header[13:17], 1)
+	binary.BigEndian.PutUint64(header[17:25], math.MaxUint64)
+	err = decoded8.UnmarshalBinary(append(header, make([]byte, 12)...))
+	assert.EqualError(t, err, "binary fuse encoding has invalid number of keys")
+}
+
+func TestBinaryFuseAccuracy(t *testing.T) {
+	f8, err := NewBinaryFuse8(fuseTestKeys("key", 10))
+	assert.Nil(t, err)
+	assert.Equal(t, 1.0/256, f8.Accuracy())
+	f16, err := NewBinaryFuse16(fuseTestKeys("key", 10))
+	assert.Nil(t, err)
+	assert.Equal(t, 1.0/65536, f16.Accuracy())
+}
+
+//
+// Benchmarks
+//
+
+func BenchmarkBinaryFuse8Contains(b *testing.B) {
+	keys := fuseTestKeys("key", 100000)
+	f, err := NewBinaryFuse8(keys)
+	assert.Nil(b, err)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f.Contains(keys[i%len(keys)])
+	}
+}
+
+func TestBinaryFuseCloneEqual(t *testing.T) {
+	keys := fuseTestKeys("key", 1000)
+	f8, err := NewBinaryFuse8(keys)
+	assert.Nil(t, err)
+	c8 := f8.Clone()
+	assert.True(t, f8.Equal(c8))
+	for _, key := range keys {
+		assert.True(t, c8.Contains(key))
+	}
+	c8.fingerprints[0]++
+	assert.False(t, f8.Equal(c8))
+	other8, err := NewBinaryFuse8(fuseTestKeys("other", 1000))
+	assert.Nil(t, err)
+	assert.False(t, f8.Equal(other8))
+
+	f16, err := NewBinaryFuse16(keys)
+	assert.Nil(t, err)
+	c16 := f16.Clone()
+	assert.True(t, f16.Equal(c16))
+	c16.fingerprints[0]++
+	assert.False(t, f16.Equal(c16))
+}
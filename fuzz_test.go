@@ -0,0 +1,221 @@
+package bloom
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Fuzz targets for the invariants of Bloom and BigBloom. go test runs them on the seed corpus in testdata/fuzz,
+// and go test -fuzz=FuzzBloom explores further.
+
+// key of keyed fuzzed filters
+var fuzzKey = [BLOOM_KEY_LEN]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+
+// no false negatives after any sequence of puts, puts leave the caller's bytes alone, and the bytes round trip
+func FuzzBloom(f *testing.F) {
+	f.Add([]byte("\x01a\x01b\x01c"), uint8(3))
+	f.Add([]byte("\x00\x05hello\x05world"), uint8(0))
+	f.Fuzz(func(t *testing.T, data []byte, k uint8) {
+		b, err := NewBloomFromK(int(k%8) + 1)
+		assert.Nil(t, err)
+		entries := fuzzEntries(data)
+		putAll(t, data, entries, func(entry []byte) error {
+			_, err := b.PutBytes(entry)
+			return err
+		})
+		for _, entry := range entries {
+			assertUnchanged(t, data, func() {
+				exists, _ := b.ExistsBytes(entry)
+				assert.True(t, exists, "false negative for %q", entry)
+			})
+		}
+
+		// serialization round trip
+		loaded, err := NewBloomFromBytes(b.Bytes(), b.k)
+		assert.Nil(t, err)
+		assert.True(t, b.Equal(loaded))
+		assert.Equal(t, b.Hex(), loaded.Hex())
+		for _, entry := range entries {
+			exists, _ := loaded.ExistsBytes(entry)
+			assert.True(t, exists)
+		}
+	})
+}
+
+// no false negatives after any sequence of puts, puts leave the caller's bytes alone, and the encodings round trip
+func FuzzBigBloom(f *testing.F) {
+	f.Add([]byte("\x01a\x01b\x01c"), uint16(13), uint8(3), false)
+	f.Add([]byte("\x03abc\x03abc\x00"), uint16(64), uint8(7), true)
+	f.Fuzz(func(t *testing.T, data []byte, length uint16, k uint8, keyed bool) {
+		b, err := NewBigBloomFromK(int(length%512)+1, int(k%8)+1)
+		assert.Nil(t, err)
+		if keyed {
+			assert.Nil(t, b.AddKey(fuzzKey))
+		}
+		entries := fuzzEntries(data)
+		putAll(t, data, entries, func(entry []byte) error {
+			_, err := b.PutBytes(entry)
+			return err
+		})
+		assertContainsAll(t, b, entries)
+
+		// serialization round trips
+		bs, err := b.MarshalBinaryWithKey()
+		assert.Nil(t, err)
+		var decoded BigBloom
+		assert.Nil(t, decoded.UnmarshalBinary(bs))
+		assert.True(t, b.Equal(&decoded))
+		assert.Equal(t, b.n, decoded.n)
+		assertContainsAll(t, &decoded, entries)
+
+		loaded, err := NewBigBloomFromBytes(b.Bytes(), b.k)
+		assert.Nil(t, err)
+		assert.Equal(t, b.Hex(), loaded.Hex())
+		if keyed {
+			assert.Nil(t, loaded.AddKey(fuzzKey))
+		}
+		assert.True(t, b.Equal(loaded))
+	})
+}
+
+// the union of two filters contains every entry and every bit of both
+func FuzzUnion(f *testing.F) {
+	f.Add([]byte("\x01a\x01b"), []byte("\x01c\x01d"), uint16(8), uint8(2))
+	f.Add([]byte(""), []byte("\x04same"), uint16(1), uint8(5))
+	f.Fuzz(func(t *testing.T, dataA, dataB []byte, length uint16, k uint8) {
+		entriesA, entriesB := fuzzEntries(dataA), fuzzEntries(dataB)
+
+		// BigBloom
+		a, err := NewBigBloomFromK(int(length%512)+1, int(k%8)+1)
+		assert.Nil(t, err)
+		b := a.Clone()
+		putAll(t, dataA, entriesA, func(entry []byte) error {
+			_, err := a.PutBytes(entry)
+			return err
+		})
+		putAll(t, dataB, entriesB, func(entry []byte) error {
+			_, err := b.PutBytes(entry)
+			return err
+		})
+		union := a.Clone()
+		assert.Nil(t, union.Union(b))
+		assertContainsAll(t, union, entriesA)
+		assertContainsAll(t, union, entriesB)
+		for i, word := range union.words {
+			assert.Equal(t, word, word|a.words[i]|b.words[i])
+		}
+		assert.GreaterOrEqual(t, union.FillRatio(), a.FillRatio())
+		assert.GreaterOrEqual(t, union.FillRatio(), b.FillRatio())
+
+		// Bloom, by the union of its bytes
+		smallA, err := NewBloomFromK(int(k%8) + 1)
+		assert.Nil(t, err)
+		smallB := smallA.Clone()
+		putAll(t, dataA, entriesA, func(entry []byte) error {
+			_, err := smallA.PutBytes(entry)
+			return err
+		})
+		putAll(t, dataB, entriesB, func(entry []byte) error {
+			_, err := smallB.PutBytes(entry)
+			return err
+		})
+		bsA, bsB := smallA.Bytes(), smallB.Bytes()
+		var bs [BLOOM_LEN]byte
+		for i := range bs {
+			bs[i] = bsA[i] | bsB[i]
+		}
+		smallUnion, err := NewBloomFromBytes(bs, smallA.k)
+		assert.Nil(t, err)
+		for _, entry := range append(entriesA, entriesB...) {
+			exists, _ := smallUnion.ExistsBytes(entry)
+			assert.True(t, exists)
+		}
+	})
+}
+
+// capacity and accuracy constraints are never exceeded, whatever is inserted
+func FuzzConstraints(f *testing.F) {
+	f.Add([]byte("\x01a\x01b\x01c\x01d"), uint8(2), uint16(500))
+	f.Add([]byte("\x02aa\x02bb\x02cc\x02dd\x02ee\x02ff"), uint8(40), uint16(1))
+	f.Fuzz(func(t *testing.T, data []byte, maxCap uint8, acc uint16) {
+		capacity := int(maxCap%50) + 1
+		maxFalsePositiveRate := float64(acc%999+1) / 1000
+		entries := fuzzEntries(data)
+
+		big, err := NewBigBloomAlloc(capacity, maxFalsePositiveRate)
+		assert.Nil(t, err)
+		for _, entry := range entries {
+			_, err := big.PutBytes(entry)
+			assertConstraintError(t, err)
+			assert.LessOrEqual(t, big.n, capacity)
+			assert.LessOrEqual(t, big.Accuracy(), maxFalsePositiveRate)
+		}
+
+		small, err := NewBloomFromCap(capacity)
+		assert.Nil(t, err)
+		assert.Nil(t, small.AddCapacityConstraint(capacity))
+		if err := small.AddAccuracyConstraint(maxFalsePositiveRate); err != nil {
+			assert.True(t, errors.Is(err, ErrIncompatibleConstraints))
+			return
+		}
+		for _, entry := range entries {
+			_, err := small.PutBytes(entry)
+			assertConstraintError(t, err)
+			assert.LessOrEqual(t, small.n, capacity)
+			assert.LessOrEqual(t, small.Accuracy(), maxFalsePositiveRate)
+		}
+	})
+}
+
+//
+// helpers
+//
+
+// split data into entries, each prefixed with its length. Entries are slices of data, so appending to one would overwrite the next
+func fuzzEntries(data []byte) [][]byte {
+	var entries [][]byte
+	for len(data) > 0 {
+		n := int(data[0]) % 32
+		data = data[1:]
+		if n > len(data) {
+			n = len(data)
+		}
+		entries = append(entries, data[:n])
+		data = data[n:]
+	}
+	return entries
+}
+
+// puts every entry, checking that data, which the entries are slices of, is never written to
+func putAll(t *testing.T, data []byte, entries [][]byte, put func([]byte) error) {
+	for _, entry := range entries {
+		assertUnchanged(t, data, func() {
+			assert.Nil(t, put(entry))
+		})
+	}
+}
+
+// asserts fn does not change data
+func assertUnchanged(t *testing.T, data []byte, fn func()) {
+	before := append([]byte(nil), data...)
+	fn()
+	assert.True(t, bytes.Equal(before, data), "caller's bytes changed from %q to %q", before, data)
+}
+
+// asserts every entry exists in b
+func assertContainsAll(t *testing.T, b *BigBloom, entries [][]byte) {
+	for _, entry := range entries {
+		exists, _ := b.ExistsBytes(entry)
+		assert.True(t, exists, "false negative for %q", entry)
+	}
+}
+
+// asserts err is nil or a constraint error
+func assertConstraintError(t *testing.T, err error) {
+	if err != nil {
+		assert.True(t, errors.Is(err, ErrCapacity) || errors.Is(err, ErrAccuracy), "unexpected error %v", err)
+	}
+}
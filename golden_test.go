@@ -0,0 +1,142 @@
+package bloom
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// goldenVector is a filter stored in testdata/golden.json. Changing the hashing would change its bytes and break stored filters
+type goldenVector struct {
+	Name string `json:"name"`
+
+	// Bloom or BigBloom
+	Type string `json:"type"`
+
+	// number of bytes
+	Len int `json:"len"`
+
+	// number of hash functions
+	K int `json:"k"`
+
+	// optional, hex of the SipHash key
+	Key string `json:"key"`
+
+	// hex of each inserted entry
+	Entries []string `json:"entries"`
+
+	// expected Hex() of the filter
+	Hex string `json:"hex"`
+
+	// hex of MarshalBinaryWithKey for each encoding version
+	Encodings map[string]string `json:"encodings"`
+}
+
+func TestGoldenVectors(t *testing.T) {
+	bs, err := os.ReadFile("testdata/golden.json")
+	assert.Nil(t, err)
+	var vectors []goldenVector
+	assert.Nil(t, json.Unmarshal(bs, &vectors))
+	assert.NotEmpty(t, vectors)
+
+	for _, v := range vectors {
+		t.Run(v.Name, func(t *testing.T) {
+			entries := make([][]byte, len(v.Entries))
+			for i, entry := range v.Entries {
+				entries[i], err = hex.DecodeString(entry)
+				assert.Nil(t, err)
+			}
+
+			switch v.Type {
+			case "Bloom":
+				b, err := NewBloomFromK(v.K)
+				assert.Nil(t, err)
+				for _, entry := range entries {
+					_, err = b.PutBytes(entry)
+					assert.Nil(t, err)
+				}
+				assert.Equal(t, v.Len, BLOOM_LEN)
+				assert.Equal(t, v.Hex, b.Hex())
+			case "BigBloom":
+				b, err := NewBigBloomFromK(v.Len, v.K)
+				assert.Nil(t, err)
+				if v.Key != "" {
+					var key [BLOOM_KEY_LEN]byte
+					_, err = hex.Decode(key[:], []byte(v.Key))
+					assert.Nil(t, err)
+					assert.Nil(t, b.AddKey(key))
+				}
+				for _, entry := range entries {
+					_, err = b.PutBytes(entry)
+					assert.Nil(t, err)
+				}
+				assert.Equal(t, v.Hex, b.Hex())
+
+				// the current encoding is unchanged
+				encoded, err := b.MarshalBinaryWithKey()
+				assert.Nil(t, err)
+				assert.Equal(t, v.Encodings[strconv.Itoa(BIG_BLOOM_ENCODING_VERSION)], hex.EncodeToString(encoded))
+
+				// every version loads to the same filter
+				for version := 0; version <= BIG_BLOOM_ENCODING_VERSION; version++ {
+					encoding, ok := v.Encodings[strconv.Itoa(version)]
+					assert.True(t, ok, "missing encoding version %d", version)
+					bs, err := hex.DecodeString(encoding)
+					assert.Nil(t, err)
+					decodedVersion, err := BigBloomEncodingVersion(bs)
+					assert.Nil(t, err)
+					assert.Equal(t, version, decodedVersion)
+					loaded, err := LoadBigBloom(bs, entries...)
+					assert.Nil(t, err)
+					assert.True(t, b.Equal(loaded))
+					assert.Equal(t, b.n, loaded.n)
+				}
+			default:
+				t.Fatalf("unknown type %s", v.Type)
+			}
+		})
+	}
+}
+
+func TestLoadBigBloom(t *testing.T) {
+	b, err := NewBigBloomFromK(64, 3)
+	assert.Nil(t, err)
+	b.PutStr("stored")
+	bs, err := b.MarshalBinary()
+	assert.Nil(t, err)
+	version, err := BigBloomEncodingVersion(bs)
+	assert.Nil(t, err)
+	assert.Equal(t, BIG_BLOOM_ENCODING_VERSION, version)
+
+	loaded, err := LoadBigBloom(bs, []byte("stored"))
+	assert.Nil(t, err)
+	assert.True(t, b.Equal(loaded))
+
+	// a filter built with different hashing is missing known entries
+	other, err := NewBigBloomFromK(64, 3)
+	assert.Nil(t, err)
+	assert.Nil(t, other.AddKey(fuzzKey))
+	other.PutStr("stored")
+	bs, err = other.MarshalBinaryWithKey()
+	assert.Nil(t, err)
+	bs[0] &^= bigBloomKeyed | bigBloomKeyIncluded
+	bs = append(bs[:13], bs[13+BLOOM_KEY_LEN:]...)
+	_, err = LoadBigBloom(bs, []byte("stored"))
+	assert.True(t, errors.Is(err, ErrIncompatible))
+	var compatibilityErr *CompatibilityError
+	assert.True(t, errors.As(err, &compatibilityErr))
+	assert.Equal(t, []byte("stored"), compatibilityErr.Entry)
+	assert.EqualError(t, err, "bloom filter is incompatible: encoding version 1 does not contain known entry 73746f726564")
+
+	// newer versions cannot be loaded
+	bs[0] = bs[0]&^(bigBloomVersionMask<<bigBloomVersionShift) | (BIG_BLOOM_ENCODING_VERSION+1)<<bigBloomVersionShift
+	_, err = LoadBigBloom(bs)
+	assert.EqualError(t, err, "unsupported bloom filter encoding version 2")
+	_, err = BigBloomEncodingVersion(nil)
+	assert.EqualError(t, err, "bloom filter encoding too short")
+}
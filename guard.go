@@ -0,0 +1,223 @@
+package bloom
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// Loader reads the value of key from the backing store. It returns an error matching ErrNotFound if the store does not have key.
+type Loader[K comparable, V any] func(key K) (V, error)
+
+// GuardStats is a snapshot of the lookups of a Guard
+type GuardStats struct {
+	// number of calls to Get
+	Lookups uint64
+
+	// number of lookups the filter ruled out without calling the loader
+	Skips uint64
+
+	// number of calls to the loader
+	Loads uint64
+
+	// number of lookups that waited for a load of the same key already in flight instead of calling the loader
+	Shared uint64
+
+	// number of loads that found the key
+	Hits uint64
+
+	// number of loads that did not find a key the filter answered true for
+	FalsePositives uint64
+
+	// number of loads that failed with an error other than ErrNotFound
+	Errors uint64
+
+	// whether the filter reached a constraint, so every lookup goes to the loader
+	Bypassed bool
+}
+
+// Guard is a cache-aside helper that puts a BigBloom in front of a loader. Lookups of keys the filter rules out return ErrNotFound
+// without calling the loader. Concurrent lookups of the same key share a single load, so a burst of lookups of a missing key
+// that the filter cannot rule out calls the loader once. Guard is safe for concurrent use.
+//
+// Every key written to the backing store must be added with Add or Write, otherwise lookups of it are skipped.
+// Keys cannot be removed from a bloom filter, so deleted keys keep going to the loader until the filter is rebuilt.
+type Guard[K comparable, V any] struct {
+	// guards b and bypassed
+	mu sync.RWMutex
+
+	// keys of the backing store
+	b *BigBloom
+
+	// whether b reached a constraint and no longer holds every key
+	bypassed bool
+
+	// bytes of a key in b
+	encode func(K) []byte
+
+	// reads the backing store
+	load Loader[K, V]
+
+	// guards inflight
+	inflightMu sync.Mutex
+
+	// loads in flight by key
+	inflight map[K]*guardCall[V]
+
+	// counters of GuardStats
+	lookups        atomic.Uint64
+	skips          atomic.Uint64
+	loads          atomic.Uint64
+	shared         atomic.Uint64
+	hits           atomic.Uint64
+	falsePositives atomic.Uint64
+	errors         atomic.Uint64
+}
+
+// a load in flight, shared by every lookup of its key
+type guardCall[V any] struct {
+	// closed when the load is done
+	done chan struct{}
+
+	// result of the load
+	value V
+	err   error
+}
+
+//
+// Constructors
+//
+
+// Constructs guard that checks b before calling load. b must already hold every key of the backing store, encoded with encode.
+// b must only be used through the guard from now on.
+func NewGuard[K comparable, V any](b *BigBloom, encode func(K) []byte, load Loader[K, V]) (*Guard[K, V], error) {
+	if b == nil {
+		return nil, newParameterError("b", nil, "filter cannot be nil")
+	}
+	if encode == nil {
+		return nil, newParameterError("encode", nil, "encode cannot be nil")
+	}
+	if load == nil {
+		return nil, newParameterError("load", nil, "loader cannot be nil")
+	}
+	return &Guard[K, V]{
+		b:        b,
+		bypassed: false,
+		encode:   encode,
+		load:     load,
+		inflight: make(map[K]*guardCall[V]),
+	}, nil
+}
+
+// Constructs guard for string keys, which are encoded as their bytes
+func NewStringGuard[V any](b *BigBloom, load Loader[string, V]) (*Guard[string, V], error) {
+	return NewGuard(b, func(key string) []byte { return []byte(key) }, load)
+}
+
+//
+// Methods
+//
+
+// Gets the value of key. Returns an error matching ErrNotFound if the filter rules key out or the loader does not find it.
+func (g *Guard[K, V]) Get(key K) (V, error) {
+	g.lookups.Add(1)
+	g.mu.RLock()
+	exists := g.bypassed
+	if !exists {
+		exists, _ = g.b.ExistsBytes(g.encode(key))
+	}
+	g.mu.RUnlock()
+	if !exists {
+		g.skips.Add(1)
+		var zero V
+		return zero, ErrNotFound
+	}
+	return g.loadShared(key)
+}
+
+// Adds key to the filter. Call it before writing key to the backing store, so that no lookup is skipped while the write is in flight.
+// If the filter reaches a constraint, the guard bypasses it from then on and every lookup goes to the loader.
+func (g *Guard[K, V]) Add(key K) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.bypassed {
+		return nil
+	}
+	_, err := g.b.PutBytes(g.encode(key))
+	if errors.Is(err, ErrCapacity) || errors.Is(err, ErrAccuracy) {
+		g.bypassed = true
+		return nil
+	}
+	return err
+}
+
+// Adds key to the filter and then calls write, which writes key to the backing store.
+// If write fails key stays in the filter, which only costs a load.
+func (g *Guard[K, V]) Write(key K, write func() error) error {
+	if err := g.Add(key); err != nil {
+		return err
+	}
+	return write()
+}
+
+// Snapshot of the lookups so far
+func (g *Guard[K, V]) Stats() GuardStats {
+	g.mu.RLock()
+	bypassed := g.bypassed
+	g.mu.RUnlock()
+
+	return GuardStats{
+		Lookups:        g.lookups.Load(),
+		Skips:          g.skips.Load(),
+		Loads:          g.loads.Load(),
+		Shared:         g.shared.Load(),
+		Hits:           g.hits.Load(),
+		FalsePositives: g.falsePositives.Load(),
+		Errors:         g.errors.Load(),
+		Bypassed:       bypassed,
+	}
+}
+
+func (s GuardStats) String() string {
+	return fmt.Sprintf("guard: %d lookups, %d skipped, %d loads, %d shared, %d hits, %d false positives, %d errors, bypassed %t", s.Lookups, s.Skips, s.Loads, s.Shared, s.Hits, s.FalsePositives, s.Errors, s.Bypassed)
+}
+
+//
+// helpers
+//
+
+// calls the loader for key, or waits for the load of key already in flight
+func (g *Guard[K, V]) loadShared(key K) (V, error) {
+	g.inflightMu.Lock()
+	if call, ok := g.inflight[key]; ok {
+		g.inflightMu.Unlock()
+		g.shared.Add(1)
+		<-call.done
+		return call.value, call.err
+	}
+	// the error stays if the loader panics, so waiting lookups do not see a zero value as found
+	call := &guardCall[V]{done: make(chan struct{}), err: errors.New("loader panicked")}
+	g.inflight[key] = call
+	g.inflightMu.Unlock()
+
+	// results are not kept, so a later lookup sees writes made during this load
+	defer func() {
+		g.inflightMu.Lock()
+		delete(g.inflight, key)
+		g.inflightMu.Unlock()
+		close(call.done)
+	}()
+
+	g.loads.Add(1)
+	call.value, call.err = g.load(key)
+	switch {
+	case call.err == nil:
+		g.hits.Add(1)
+	case errors.Is(call.err, ErrNotFound):
+		g.falsePositives.Add(1)
+	default:
+		g.errors.Add(1)
+	}
+	return call.value, call.err
+}
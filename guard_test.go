@@ -0,0 +1,221 @@
+package bloom
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// in-memory backing store of a Guard
+type mapStore struct {
+	mu     sync.Mutex
+	values map[string]int
+	loads  int
+}
+
+func newMapStore(values map[string]int) *mapStore {
+	return &mapStore{values: values}
+}
+
+func (s *mapStore) load(key string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loads++
+	v, ok := s.values[key]
+	if !ok {
+		return 0, fmt.Errorf("%s: %w", key, ErrNotFound)
+	}
+	return v, nil
+}
+
+func (s *mapStore) write(key string, v int) func() error {
+	return func() error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.values[key] = v
+		return nil
+	}
+}
+
+// guard over a store holding a and b, with a filter holding the same keys
+func newTestGuard(t *testing.T, cap int) (*Guard[string, int], *mapStore) {
+	store := newMapStore(map[string]int{"a": 1, "b": 2})
+	b, err := NewBigBloomAlloc(cap, .01)
+	assert.Nil(t, err)
+	for key := range store.values {
+		_, err := b.PutStr(key)
+		assert.Nil(t, err)
+	}
+	g, err := NewStringGuard(b, store.load)
+	assert.Nil(t, err)
+	return g, store
+}
+
+func TestNewGuard(t *testing.T) {
+	b, err := NewBigBloomFromK(64, 3)
+	assert.Nil(t, err)
+	encode := func(key int) []byte { return []byte{byte(key)} }
+	load := func(key int) (string, error) { return "", nil }
+
+	testCases := []struct {
+		b           *BigBloom
+		encode      func(int) []byte
+		load        Loader[int, string]
+		expectedErr string
+	}{
+		{nil, encode, load, "filter cannot be nil"},
+		{b, nil, load, "encode cannot be nil"},
+		{b, encode, nil, "loader cannot be nil"},
+	}
+	for _, tc := range testCases {
+		_, err := NewGuard(tc.b, tc.encode, tc.load)
+		assert.EqualError(t, err, tc.expectedErr)
+		assert.True(t, errors.Is(err, ErrInvalidParameter))
+	}
+	_, err = NewGuard(b, encode, load)
+	assert.Nil(t, err)
+}
+
+func TestGuardGet(t *testing.T) {
+	g, store := newTestGuard(t, 100)
+
+	v, err := g.Get("a")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, v)
+
+	// a key the filter rules out never reaches the store
+	missing := feedbackProbe(g.b, false)
+	_, err = g.Get(missing)
+	assert.Equal(t, ErrNotFound, err)
+	assert.Equal(t, 1, store.loads)
+
+	// a key in the filter but not in the store reaches the store, which does not find it
+	assert.Nil(t, g.Add("deleted"))
+	_, err = g.Get("deleted")
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.Equal(t, 2, store.loads)
+
+	stats := g.Stats()
+	assert.Equal(t, GuardStats{
+		Lookups:        3,
+		Skips:          1,
+		Loads:          2,
+		Shared:         0,
+		Hits:           1,
+		FalsePositives: 1,
+		Errors:         0,
+		Bypassed:       false,
+	}, stats)
+	assert.Equal(t, "guard: 3 lookups, 1 skipped, 2 loads, 0 shared, 1 hits, 1 false positives, 0 errors, bypassed false", stats.String())
+
+	// other errors of the loader are passed on
+	loadErr := errors.New("store unavailable")
+	g.load = func(key string) (int, error) { return 0, loadErr }
+	_, err = g.Get("a")
+	assert.Equal(t, loadErr, err)
+	assert.Equal(t, uint64(1), g.Stats().Errors)
+}
+
+func TestGuardWrite(t *testing.T) {
+	g, store := newTestGuard(t, 100)
+	key := feedbackProbe(g.b, false)
+	_, err := g.Get(key)
+	assert.Equal(t, ErrNotFound, err)
+
+	// writes keep the filter in sync with the store
+	assert.Nil(t, g.Write(key, store.write(key, 3)))
+	v, err := g.Get(key)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, v)
+
+	// a failed write leaves the key in the filter, which only costs a load
+	writeErr := errors.New("store unavailable")
+	key = feedbackProbe(g.b, false)
+	assert.Equal(t, writeErr, g.Write(key, func() error { return writeErr }))
+	_, err = g.Get(key)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.Equal(t, uint64(1), g.Stats().FalsePositives)
+}
+
+func TestGuardBypass(t *testing.T) {
+	g, store := newTestGuard(t, 2)
+
+	// the filter is at capacity, so it can no longer hold every key
+	assert.Nil(t, g.Write("c", store.write("c", 3)))
+	assert.True(t, g.Stats().Bypassed)
+	v, err := g.Get("c")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, v)
+
+	// every lookup goes to the store
+	_, err = g.Get(feedbackProbe(g.b, false))
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.Equal(t, uint64(0), g.Stats().Skips)
+	assert.Equal(t, 2, store.loads)
+}
+
+func TestGuardStampede(t *testing.T) {
+	release := make(chan struct{})
+	loads := 0
+	b, err := NewBigBloomFromK(64, 3)
+	assert.Nil(t, err)
+	g, err := NewStringGuard(b, func(key string) (int, error) {
+		loads++
+		<-release
+		return 0, ErrNotFound
+	})
+	assert.Nil(t, err)
+
+	// a missing key the filter cannot rule out
+	assert.Nil(t, g.Add("deleted"))
+
+	const lookups = 10
+	errs := make(chan error, lookups)
+	for i := 0; i < lookups; i++ {
+		go func() {
+			_, err := g.Get("deleted")
+			errs <- err
+		}()
+	}
+	for deadline := time.Now().Add(5 * time.Second); g.Stats().Loads+g.Stats().Shared < lookups; {
+		if time.Now().After(deadline) {
+			t.Fatal("lookups did not start")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	for i := 0; i < lookups; i++ {
+		assert.Equal(t, ErrNotFound, <-errs)
+	}
+
+	assert.Equal(t, 1, loads)
+	stats := g.Stats()
+	assert.Equal(t, uint64(1), stats.Loads)
+	assert.Equal(t, uint64(lookups-1), stats.Shared)
+	assert.Equal(t, uint64(1), stats.FalsePositives)
+
+	// results are not kept
+	_, err = g.Get("deleted")
+	assert.Equal(t, ErrNotFound, err)
+	assert.Equal(t, 2, loads)
+}
+
+func TestGuardLoaderPanic(t *testing.T) {
+	b, err := NewBigBloomFromK(64, 3)
+	assert.Nil(t, err)
+	g, err := NewStringGuard(b, func(key string) (int, error) {
+		panic("store crashed")
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, g.Add("a"))
+
+	assert.Panics(t, func() { g.Get("a") })
+
+	// the failed load is not left in flight
+	assert.Empty(t, g.inflight)
+	assert.Panics(t, func() { g.Get("a") })
+}
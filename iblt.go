@@ -0,0 +1,267 @@
+package bloom
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// IBLT is an Invertible Bloom Lookup Table (Goodrich & Mitzenmacher, 2011) of uint64 keys.
+// Subtracting the tables of two sets leaves only the keys in their symmetric difference,
+// which ListEntries can recover as long as the difference is small compared to the number of cells.
+// This lets two peers reconcile their sets by exchanging a table sized for the difference instead of the sets themselves.
+type IBLT struct {
+	// number of hash functions. The cells are split into k equal subtables with one hash function each,
+	// so a key never maps to the same cell twice
+	k int
+
+	// cells of all subtables
+	cells []ibltCell
+}
+
+type ibltCell struct {
+	// number of keys inserted minus number deleted
+	count int64
+
+	// xor of all keys
+	keySum uint64
+
+	// xor of the checksums of all keys
+	hashSum uint64
+}
+
+// number of hash functions used by NewIBLTForDiff
+const ibltDefaultK = 4
+
+//
+// Constructors
+//
+
+// Constructs IBLT with k hash functions and at least m cells. m is rounded up to a multiple of k.
+func NewIBLT(m, k int) (*IBLT, error) {
+	if k < 1 {
+		return nil, newParameterError("k", k, "k cannot be less than 1")
+	}
+	if m < k {
+		return nil, newParameterError("m", m, "number of cells cannot be less than k")
+	}
+	m = k * int(math.Ceil(float64(m)/float64(k)))
+	return &IBLT{
+		k:     k,
+		cells: make([]ibltCell, m),
+	}, nil
+}
+
+// Constructs IBLT that can recover a symmetric difference of up to d keys with high probability
+func NewIBLTForDiff(d int) (*IBLT, error) {
+	if d < 1 {
+		return nil, newParameterError("d", d, "difference size cannot be less than 1")
+	}
+	return NewIBLT(IBLTCellsForDiff(d), ibltDefaultK)
+}
+
+// Number of cells needed to recover a symmetric difference of d keys with high probability using 4 hash functions.
+func IBLTCellsForDiff(d int) int {
+	// peeling with k=4 succeeds with high probability above 1.295 cells per key for large d.
+	// 1.5 cells per key leaves room for the variance of smaller differences,
+	// and the constant keeps small tables from failing because a few keys share all their cells
+	return int(math.Ceil(1.5*float64(d))) + 8*ibltDefaultK
+}
+
+//
+// Methods
+//
+
+// Inserts key into table
+func (t *IBLT) Insert(key uint64) {
+	t.update(key, 1)
+}
+
+// Deletes key from table. Deleting a key that was never inserted leaves a negative entry that ListEntries reports as deleted.
+func (t *IBLT) Delete(key uint64) {
+	t.update(key, -1)
+}
+
+// Returns table of the keys in t but not other (inserted) and in other but not t (deleted).
+// Both tables must have the same number of cells and hash functions.
+func (t *IBLT) Subtract(other *IBLT) (*IBLT, error) {
+	if t.k != other.k || len(t.cells) != len(other.cells) {
+		return nil, &MismatchError{Reason: "cannot subtract IBLTs of different sizes"}
+	}
+	diff := &IBLT{
+		k:     t.k,
+		cells: make([]ibltCell, len(t.cells)),
+	}
+	for i := range t.cells {
+		diff.cells[i] = ibltCell{
+			count:   t.cells[i].count - other.cells[i].count,
+			keySum:  t.cells[i].keySum ^ other.cells[i].keySum,
+			hashSum: t.cells[i].hashSum ^ other.cells[i].hashSum,
+		}
+	}
+	return diff, nil
+}
+
+// Lists all keys by peeling. Keys with a positive count are returned as inserted and keys with a negative count as deleted.
+// Returns an error if the table holds too many keys to be fully decoded. The table itself is left unchanged.
+func (t *IBLT) ListEntries() (inserted, deleted []uint64, err error) {
+	cells := make([]ibltCell, len(t.cells))
+	copy(cells, t.cells)
+	peeler := &IBLT{k: t.k, cells: cells}
+
+	// queue of cells that may be pure
+	queue := make([]int, 0, len(cells))
+	for i := range cells {
+		if cells[i].pure() {
+			queue = append(queue, i)
+		}
+	}
+	for len(queue) > 0 {
+		i := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		cell := cells[i]
+		// cell may no longer be pure after peeling other keys
+		if !cell.pure() {
+			continue
+		}
+		key := cell.keySum
+		if cell.count == 1 {
+			inserted = append(inserted, key)
+		} else {
+			deleted = append(deleted, key)
+		}
+		// remove key from all its cells
+		for j := 0; j < peeler.k; j++ {
+			cellI := peeler.index(key, j)
+			peeler.cells[cellI].add(key, -cell.count)
+			if peeler.cells[cellI].pure() {
+				queue = append(queue, cellI)
+			}
+		}
+	}
+
+	for i := range cells {
+		if cells[i] != (ibltCell{}) {
+			return inserted, deleted, errors.New("failed to list all entries: IBLT is too small for the number of keys")
+		}
+	}
+	return inserted, deleted, nil
+}
+
+// Number of cells
+func (t *IBLT) Len() int {
+	return len(t.cells)
+}
+
+// Encodes table as k followed by the count, keySum and hashSum of each cell. Integers are big endian.
+func (t *IBLT) MarshalBinary() ([]byte, error) {
+	bs := make([]byte, 0, 8+24*len(t.cells))
+	bs = binary.BigEndian.AppendUint64(bs, uint64(t.k))
+	for _, cell := range t.cells {
+		bs = binary.BigEndian.AppendUint64(bs, uint64(cell.count))
+		bs = binary.BigEndian.AppendUint64(bs, cell.keySum)
+		bs = binary.BigEndian.AppendUint64(bs, cell.hashSum)
+	}
+	return bs, nil
+}
+
+// Decodes table encoded with MarshalBinary
+func (t *IBLT) UnmarshalBinary(bs []byte) error {
+	if len(bs) < 8 || (len(bs)-8)%24 != 0 {
+		return newDecodeError("IBLT", "IBLT encoding has invalid length")
+	}
+	k := binary.BigEndian.Uint64(bs[0:8])
+	m := (len(bs) - 8) / 24
+	if k < 1 || uint64(m) < k || uint64(m)%k != 0 {
+		return newDecodeError("IBLT", "IBLT encoding has invalid number of hash functions")
+	}
+	cells := make([]ibltCell, m)
+	for i := range cells {
+		cellBs := bs[8+24*i:]
+		cells[i] = ibltCell{
+			count:   int64(binary.BigEndian.Uint64(cellBs[0:8])),
+			keySum:  binary.BigEndian.Uint64(cellBs[8:16]),
+			hashSum: binary.BigEndian.Uint64(cellBs[16:24]),
+		}
+	}
+	t.k = int(k)
+	t.cells = cells
+	return nil
+}
+
+// Removes all keys. k and the number of cells are kept
+func (t *IBLT) Reset() {
+	for i := range t.cells {
+		t.cells[i] = ibltCell{}
+	}
+}
+
+// Copies the table
+func (t *IBLT) Clone() *IBLT {
+	cells := make([]ibltCell, len(t.cells))
+	copy(cells, t.cells)
+	return &IBLT{
+		k:     t.k,
+		cells: cells,
+	}
+}
+
+// Checks whether t and other answer every query the same: same k and cells
+func (t *IBLT) Equal(other *IBLT) bool {
+	if t.k != other.k || len(t.cells) != len(other.cells) {
+		return false
+	}
+	for i := range t.cells {
+		if t.cells[i] != other.cells[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (t *IBLT) String() string {
+	return fmt.Sprintf("IBLT: %d cells, k %d", len(t.cells), t.k)
+}
+
+//
+// helpers
+//
+
+// adds count of key to its k cells
+func (t *IBLT) update(key uint64, count int64) {
+	for i := 0; i < t.k; i++ {
+		cellI := t.index(key, i)
+		t.cells[cellI].add(key, count)
+	}
+}
+
+// calculate index of cell for hash function i. Each hash function has its own subtable.
+func (t *IBLT) index(key uint64, i int) int {
+	subLen := len(t.cells) / t.k
+	var bs [8]byte
+	binary.BigEndian.PutUint64(bs[:], key)
+	return i*subLen + int(hashIndex(bs[:], i, uint64(subLen)))
+}
+
+// adds count of key to cell. xor makes adding and removing a key the same operation on the sums
+func (c *ibltCell) add(key uint64, count int64) {
+	c.count += count
+	c.keySum ^= key
+	c.hashSum ^= ibltChecksum(key)
+}
+
+// cell holds exactly one key
+func (c *ibltCell) pure() bool {
+	return (c.count == 1 || c.count == -1) && c.hashSum == ibltChecksum(c.keySum)
+}
+
+// checksum of key, independent of the cell indices
+func ibltChecksum(key uint64) uint64 {
+	var bs [8]byte
+	binary.BigEndian.PutUint64(bs[:], key)
+	h := sha256.Sum256(bs[:])
+	// the cell indices use the first 8 bytes of the nonced hashes, the checksum uses the last 8 of the plain hash
+	return binary.BigEndian.Uint64(h[24:32])
+}
@@ -0,0 +1,210 @@
+package bloom
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewIBLT(t *testing.T) {
+	// test zero k
+	_, err := NewIBLT(10, 0)
+	assert.EqualError(t, err, "k cannot be less than 1")
+	// test fewer cells than k
+	_, err = NewIBLT(2, 3)
+	assert.EqualError(t, err, "number of cells cannot be less than k")
+	// test zero difference
+	_, err = NewIBLTForDiff(0)
+	assert.EqualError(t, err, "difference size cannot be less than 1")
+
+	// cells are rounded up to a multiple of k
+	table, err := NewIBLT(10, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, 12, table.Len())
+
+	table, err = NewIBLTForDiff(100)
+	assert.Nil(t, err)
+	assert.GreaterOrEqual(t, table.Len(), IBLTCellsForDiff(100))
+	assert.Equal(t, 0, table.Len()%4)
+}
+
+func TestIBLTInsertDelete(t *testing.T) {
+	table, err := NewIBLT(30, 3)
+	assert.Nil(t, err)
+
+	table.Insert(1)
+	table.Insert(2)
+	table.Insert(3)
+	table.Delete(2)
+	// deleting a key that was never inserted
+	table.Delete(4)
+
+	inserted, deleted, err := table.ListEntries()
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []uint64{1, 3}, inserted)
+	assert.ElementsMatch(t, []uint64{4}, deleted)
+
+	// listing does not change the table
+	inserted, _, err = table.ListEntries()
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []uint64{1, 3}, inserted)
+
+	// an empty table has no entries
+	empty, err := NewIBLT(30, 3)
+	assert.Nil(t, err)
+	inserted, deleted, err = empty.ListEntries()
+	assert.Nil(t, err)
+	assert.Empty(t, inserted)
+	assert.Empty(t, deleted)
+}
+
+func TestIBLTTooManyEntries(t *testing.T) {
+	table, err := NewIBLT(9, 3)
+	assert.Nil(t, err)
+	for i := uint64(0); i < 100; i++ {
+		table.Insert(i)
+	}
+	_, _, err = table.ListEntries()
+	assert.EqualError(t, err, "failed to list all entries: IBLT is too small for the number of keys")
+}
+
+// builds two random sets that share common keys and differ by onlyA and onlyB keys
+func ibltRandomSets(rng *rand.Rand, common, onlyA, onlyB int) (a, b, expectedA, expectedB []uint64) {
+	seen := make(map[uint64]bool)
+	unique := func() uint64 {
+		for {
+			key := rng.Uint64()
+			if !seen[key] {
+				seen[key] = true
+				return key
+			}
+		}
+	}
+	for i := 0; i < common; i++ {
+		key := unique()
+		a = append(a, key)
+		b = append(b, key)
+	}
+	for i := 0; i < onlyA; i++ {
+		key := unique()
+		a = append(a, key)
+		expectedA = append(expectedA, key)
+	}
+	for i := 0; i < onlyB; i++ {
+		key := unique()
+		b = append(b, key)
+		expectedB = append(expectedB, key)
+	}
+	return a, b, expectedA, expectedB
+}
+
+func TestIBLTReconcile(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, d := range []int{1, 2, 10, 50, 200, 1000} {
+		t.Run(fmt.Sprintf("diff_%d", d), func(t *testing.T) {
+			// every size is tried several times because peeling is probabilistic
+			for trial := 0; trial < 20; trial++ {
+				onlyA := rng.Intn(d + 1)
+				a, b, expectedA, expectedB := ibltRandomSets(rng, 5000, onlyA, d-onlyA)
+
+				tableA, err := NewIBLTForDiff(d)
+				assert.Nil(t, err)
+				tableB, err := NewIBLTForDiff(d)
+				assert.Nil(t, err)
+				for _, key := range a {
+					tableA.Insert(key)
+				}
+				for _, key := range b {
+					tableB.Insert(key)
+				}
+
+				diff, err := tableA.Subtract(tableB)
+				assert.Nil(t, err)
+				inserted, deleted, err := diff.ListEntries()
+				assert.Nil(t, err)
+
+				sort.Slice(inserted, func(i, j int) bool { return inserted[i] < inserted[j] })
+				sort.Slice(deleted, func(i, j int) bool { return deleted[i] < deleted[j] })
+				sort.Slice(expectedA, func(i, j int) bool { return expectedA[i] < expectedA[j] })
+				sort.Slice(expectedB, func(i, j int) bool { return expectedB[i] < expectedB[j] })
+				assert.Equal(t, len(expectedA), len(inserted))
+				assert.Equal(t, len(expectedB), len(deleted))
+				if len(expectedA) > 0 {
+					assert.Equal(t, expectedA, inserted)
+				}
+				if len(expectedB) > 0 {
+					assert.Equal(t, expectedB, deleted)
+				}
+			}
+		})
+	}
+}
+
+func TestIBLTSubtract(t *testing.T) {
+	a, err := NewIBLT(30, 3)
+	assert.Nil(t, err)
+	b, err := NewIBLT(33, 3)
+	assert.Nil(t, err)
+	_, err = a.Subtract(b)
+	assert.EqualError(t, err, "cannot subtract IBLTs of different sizes")
+
+	// subtracting a table from itself leaves nothing
+	a.Insert(7)
+	diff, err := a.Subtract(a)
+	assert.Nil(t, err)
+	for _, cell := range diff.cells {
+		assert.Equal(t, ibltCell{}, cell)
+	}
+}
+
+func TestIBLTMarshalBinary(t *testing.T) {
+	table, err := NewIBLTForDiff(20)
+	assert.Nil(t, err)
+	for i := uint64(0); i < 20; i++ {
+		table.Insert(i * 31)
+	}
+	table.Delete(5)
+
+	bs, err := table.MarshalBinary()
+	assert.Nil(t, err)
+	var decoded IBLT
+	err = decoded.UnmarshalBinary(bs)
+	assert.Nil(t, err)
+	assert.Equal(t, table.k, decoded.k)
+	assert.Equal(t, table.cells, decoded.cells)
+
+	// test invalid encodings
+	err = decoded.UnmarshalBinary(bs[:len(bs)-1])
+	assert.EqualError(t, err, "IBLT encoding has invalid length")
+	bs[7] = 0
+	err = decoded.UnmarshalBinary(bs)
+	assert.EqualError(t, err, "IBLT encoding has invalid number of hash functions")
+}
+
+func TestIBLTResetCloneEqual(t *testing.T) {
+	a, err := NewIBLT(30, 3)
+	assert.Nil(t, err)
+	for key := uint64(1); key <= 5; key++ {
+		a.Insert(key)
+	}
+
+	c := a.Clone()
+	assert.True(t, a.Equal(c))
+	c.Delete(5)
+	assert.False(t, a.Equal(c))
+	c.Insert(5)
+	assert.True(t, a.Equal(c))
+
+	a.Reset()
+	empty, err := NewIBLT(30, 3)
+	assert.Nil(t, err)
+	assert.True(t, a.Equal(empty))
+	assert.False(t, a.Equal(c))
+	differentK, err := NewIBLT(30, 4)
+	assert.Nil(t, err)
+	assert.False(t, empty.Equal(differentK))
+}
@@ -0,0 +1,218 @@
+// Package metrics instruments bloom filters with counters and gauges, exported through expvar and the Prometheus text format.
+// Instrumentation is opt-in: only filters wrapped by a Registry are counted, so package bloom itself pays nothing for it.
+package metrics
+
+import (
+	"errors"
+	"expvar"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/nettijoe96/bloom"
+)
+
+// Registry holds instrumented filters by name
+type Registry struct {
+	// guards filters
+	mu sync.RWMutex
+
+	// instrumented filters by name
+	filters map[string]*Filter
+
+	// called in order when the Handler fails to write metrics
+	errorCallbacks []func(error)
+}
+
+// Filter is a BigBloom that counts its operations. It is safe for concurrent use, so it can be scraped while in use.
+type Filter struct {
+	// name the filter is registered and exported under
+	name string
+
+	// guards b
+	mu sync.RWMutex
+
+	// instrumented filter
+	b *bloom.BigBloom
+
+	// number of successful puts, including duplicates
+	puts atomic.Uint64
+
+	// number of successful puts of entries that were already in the filter
+	duplicatePuts atomic.Uint64
+
+	// number of existence checks
+	queries atomic.Uint64
+
+	// number of existence checks that answered true
+	positives atomic.Uint64
+
+	// number of puts rejected by a capacity or accuracy constraint
+	rejections atomic.Uint64
+}
+
+// Stats is a snapshot of the metrics of a filter
+type Stats struct {
+	// counters
+	Puts          uint64
+	DuplicatePuts uint64
+	Queries       uint64
+	Positives     uint64
+	Rejections    uint64
+
+	// gauges
+	N                 int
+	FillRatio         float64
+	FalsePositiveRate float64
+}
+
+//
+// Constructors
+//
+
+// Constructs empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		filters:        make(map[string]*Filter),
+		errorCallbacks: nil,
+	}
+}
+
+//
+// Methods
+//
+
+// Wraps b in a Filter registered under name. b must only be used through the returned Filter from now on.
+func (r *Registry) Instrument(name string, b *bloom.BigBloom) (*Filter, error) {
+	if name == "" {
+		return nil, errors.New("filter name cannot be empty")
+	}
+	if b == nil {
+		return nil, errors.New("filter cannot be nil")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.filters[name]; ok {
+		return nil, errors.New("filter name already registered: " + name)
+	}
+	f := &Filter{
+		name: name,
+		b:    b,
+	}
+	r.filters[name] = f
+	return f, nil
+}
+
+// Stops exporting the filter registered under name
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.filters, name)
+}
+
+// Snapshot of the metrics of every registered filter, by name
+func (r *Registry) Stats() map[string]Stats {
+	filters := r.sorted()
+	stats := make(map[string]Stats, len(filters))
+	for _, f := range filters {
+		stats[f.name] = f.Stats()
+	}
+	return stats
+}
+
+// expvar variable that reports Stats as JSON, for example
+//
+//	expvar.Publish("bloom", registry.Var())
+func (r *Registry) Var() expvar.Var {
+	return expvar.Func(func() any {
+		return r.Stats()
+	})
+}
+
+// Inserts string element into the filter
+func (f *Filter) PutStr(s string) error {
+	bs := []byte(s)
+	return f.PutBytes(bs)
+}
+
+// Inserts bytes element into the filter. Returns an error if a constraint is violated.
+func (f *Filter) PutBytes(bs []byte) error {
+	f.mu.Lock()
+	n := f.b.N()
+	_, err := f.b.PutBytes(bs)
+	duplicate := err == nil && f.b.N() == n
+	f.mu.Unlock()
+
+	if err != nil {
+		if errors.Is(err, bloom.ErrCapacity) || errors.Is(err, bloom.ErrAccuracy) {
+			f.rejections.Add(1)
+		}
+		return err
+	}
+	f.puts.Add(1)
+	if duplicate {
+		f.duplicatePuts.Add(1)
+	}
+	return nil
+}
+
+// Checks for existance of a string in the filter. Returns boolean and false positive rate.
+func (f *Filter) ExistsStr(s string) (bool, float64) {
+	bs := []byte(s)
+	return f.ExistsBytes(bs)
+}
+
+// Checks for existance of bytes element in the filter. Returns boolean and false positive rate.
+func (f *Filter) ExistsBytes(bs []byte) (bool, float64) {
+	f.mu.RLock()
+	exists, acc := f.b.ExistsBytes(bs)
+	f.mu.RUnlock()
+
+	f.queries.Add(1)
+	if exists {
+		f.positives.Add(1)
+	}
+	return exists, acc
+}
+
+// Name the filter is registered under
+func (f *Filter) Name() string {
+	return f.name
+}
+
+// Snapshot of the metrics of the filter
+func (f *Filter) Stats() Stats {
+	f.mu.RLock()
+	n, fillRatio, acc := f.b.N(), f.b.FillRatio(), f.b.Accuracy()
+	f.mu.RUnlock()
+
+	return Stats{
+		Puts:              f.puts.Load(),
+		DuplicatePuts:     f.duplicatePuts.Load(),
+		Queries:           f.queries.Load(),
+		Positives:         f.positives.Load(),
+		Rejections:        f.rejections.Load(),
+		N:                 n,
+		FillRatio:         fillRatio,
+		FalsePositiveRate: acc,
+	}
+}
+
+//
+// helpers
+//
+
+// registered filters sorted by name
+func (r *Registry) sorted() []*Filter {
+	r.mu.RLock()
+	filters := make([]*Filter, 0, len(r.filters))
+	for _, f := range r.filters {
+		filters = append(filters, f)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(filters, func(i, j int) bool {
+		return filters[i].name < filters[j].name
+	})
+	return filters
+}
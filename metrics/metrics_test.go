@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/nettijoe96/bloom"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstrument(t *testing.T) {
+	r := NewRegistry()
+	b, err := bloom.NewBigBloomFromK(64, 3)
+	assert.Nil(t, err)
+
+	_, err = r.Instrument("", b)
+	assert.EqualError(t, err, "filter name cannot be empty")
+	_, err = r.Instrument("users", nil)
+	assert.EqualError(t, err, "filter cannot be nil")
+
+	f, err := r.Instrument("users", b)
+	assert.Nil(t, err)
+	assert.Equal(t, "users", f.Name())
+	_, err = r.Instrument("users", b)
+	assert.EqualError(t, err, "filter name already registered: users")
+
+	r.Unregister("users")
+	assert.Empty(t, r.Stats())
+	_, err = r.Instrument("users", b)
+	assert.Nil(t, err)
+}
+
+func TestFilterStats(t *testing.T) {
+	r := NewRegistry()
+	b, err := bloom.NewBigBloomAlloc(3, .5)
+	assert.Nil(t, err)
+	f, err := r.Instrument("users", b)
+	assert.Nil(t, err)
+
+	assert.Nil(t, f.PutStr("a"))
+	assert.Nil(t, f.PutStr("b"))
+	assert.Nil(t, f.PutStr("a"))
+	exists, _ := f.ExistsStr("a")
+	assert.True(t, exists)
+	f.ExistsStr("c")
+
+	// insert until a constraint rejects
+	rejected := 0
+	for i := 0; rejected == 0 && i < 100; i++ {
+		err := f.PutBytes([]byte{byte(i), 'x'})
+		if err != nil {
+			assert.True(t, errors.Is(err, bloom.ErrCapacity) || errors.Is(err, bloom.ErrAccuracy))
+			rejected++
+		}
+	}
+	assert.Equal(t, 1, rejected)
+
+	stats := f.Stats()
+	assert.GreaterOrEqual(t, stats.Puts, uint64(3))
+	assert.GreaterOrEqual(t, stats.DuplicatePuts, uint64(1))
+	assert.Equal(t, uint64(2), stats.Queries)
+	assert.GreaterOrEqual(t, stats.Positives, uint64(1))
+	assert.Equal(t, uint64(1), stats.Rejections)
+	assert.Equal(t, b.N(), stats.N)
+	assert.Equal(t, b.FillRatio(), stats.FillRatio)
+	assert.Equal(t, b.Accuracy(), stats.FalsePositiveRate)
+	assert.Equal(t, stats.Puts-stats.DuplicatePuts, uint64(stats.N))
+	assert.Equal(t, map[string]Stats{"users": stats}, r.Stats())
+}
+
+func TestVar(t *testing.T) {
+	r := NewRegistry()
+	b, err := bloom.NewBigBloomFromK(64, 3)
+	assert.Nil(t, err)
+	f, err := r.Instrument("users", b)
+	assert.Nil(t, err)
+	assert.Nil(t, f.PutStr("a"))
+
+	var decoded map[string]Stats
+	assert.Nil(t, json.Unmarshal([]byte(r.Var().String()), &decoded))
+	assert.Equal(t, r.Stats(), decoded)
+}
+
+func TestConcurrentUse(t *testing.T) {
+	r := NewRegistry()
+	b, err := bloom.NewBigBloomFromK(1024, 3)
+	assert.Nil(t, err)
+	f, err := r.Instrument("users", b)
+	assert.Nil(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				f.PutBytes([]byte{byte(i), byte(j)})
+				f.ExistsBytes([]byte{byte(j), byte(i)})
+				r.Stats()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	stats := f.Stats()
+	assert.Equal(t, uint64(400), stats.Puts)
+	assert.Equal(t, uint64(400), stats.Queries)
+}
+
+//
+// Benchmarks
+//
+
+func BenchmarkPutBytes(b *testing.B) {
+	bs := []byte("entry")
+	b.Run("bare", func(b *testing.B) {
+		f, _ := bloom.NewBigBloomFromK(1024, 3)
+		for i := 0; i < b.N; i++ {
+			f.PutBytes(bs)
+		}
+	})
+	b.Run("instrumented", func(b *testing.B) {
+		big, _ := bloom.NewBigBloomFromK(1024, 3)
+		f, _ := NewRegistry().Instrument("bench", big)
+		for i := 0; i < b.N; i++ {
+			f.PutBytes(bs)
+		}
+	})
+}
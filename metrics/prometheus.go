@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// content type of the Prometheus text exposition format
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// a metric exported for every filter
+type prometheusMetric struct {
+	name  string
+	help  string
+	kind  string
+	value func(Stats) float64
+}
+
+// metrics in the order they are written
+var prometheusMetrics = []prometheusMetric{
+	{"bloom_puts_total", "Number of successful puts, including duplicates.", "counter", func(s Stats) float64 { return float64(s.Puts) }},
+	{"bloom_duplicate_puts_total", "Number of successful puts of entries already in the filter.", "counter", func(s Stats) float64 { return float64(s.DuplicatePuts) }},
+	{"bloom_queries_total", "Number of existence checks.", "counter", func(s Stats) float64 { return float64(s.Queries) }},
+	{"bloom_positives_total", "Number of existence checks that answered true.", "counter", func(s Stats) float64 { return float64(s.Positives) }},
+	{"bloom_rejections_total", "Number of puts rejected by a capacity or accuracy constraint.", "counter", func(s Stats) float64 { return float64(s.Rejections) }},
+	{"bloom_entries", "Number of unique entries.", "gauge", func(s Stats) float64 { return float64(s.N) }},
+	{"bloom_fill_ratio", "Share of bits that are set.", "gauge", func(s Stats) float64 { return s.FillRatio }},
+	{"bloom_false_positive_rate", "Current false positive rate. -1 for filters loaded from bytes.", "gauge", func(s Stats) float64 { return s.FalsePositiveRate }},
+}
+
+//
+// Methods
+//
+
+// HTTP handler that serves the metrics of every registered filter in the Prometheus text format, labelled by filter name.
+// The metrics are written in a single write, which only fails once the response is under way, for example when the scraper disconnects,
+// so the status cannot be changed. The error is passed to the callbacks registered with OnWriteError.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", prometheusContentType)
+		if err := r.WritePrometheus(w); err != nil {
+			r.mu.RLock()
+			callbacks := r.errorCallbacks
+			r.mu.RUnlock()
+			for _, fn := range callbacks {
+				fn(err)
+			}
+		}
+	})
+}
+
+// Registers fn to be called with the error when the Handler fails to write metrics.
+// Callbacks are called in the order they were registered.
+func (r *Registry) OnWriteError(fn func(error)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errorCallbacks = append(r.errorCallbacks, fn)
+}
+
+// Writes the metrics of every registered filter in the Prometheus text format
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	filters := r.sorted()
+	stats := make([]Stats, len(filters))
+	for i, f := range filters {
+		stats[i] = f.Stats()
+	}
+
+	var buf strings.Builder
+	for _, metric := range prometheusMetrics {
+		buf.WriteString(fmt.Sprintf("# HELP %s %s\n", metric.name, metric.help))
+		buf.WriteString(fmt.Sprintf("# TYPE %s %s\n", metric.name, metric.kind))
+		for i, f := range filters {
+			buf.WriteString(fmt.Sprintf("%s{filter=\"%s\"} %s\n", metric.name, escapeLabel(f.name), strconv.FormatFloat(metric.value(stats[i]), 'g', -1, 64)))
+		}
+	}
+	_, err := io.WriteString(w, buf.String())
+	return err
+}
+
+//
+// helpers
+//
+
+// escapes backslashes, double quotes and line feeds in a label value
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
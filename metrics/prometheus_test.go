@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nettijoe96/bloom"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	for _, name := range []string{"users", "odd \"name\"\\"} {
+		b, err := bloom.NewBigBloomFromK(8, 1)
+		assert.Nil(t, err)
+		f, err := r.Instrument(name, b)
+		assert.Nil(t, err)
+		assert.Nil(t, f.PutStr("a"))
+		assert.Nil(t, f.PutStr("a"))
+		f.ExistsStr("a")
+	}
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, 200, rec.Code)
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rec.Header().Get("Content-Type"))
+
+	body := rec.Body.String()
+	assert.True(t, strings.HasPrefix(body, "# HELP bloom_puts_total Number of successful puts, including duplicates.\n# TYPE bloom_puts_total counter\n"))
+	for _, line := range []string{
+		`bloom_puts_total{filter="users"} 2`,
+		`bloom_duplicate_puts_total{filter="users"} 1`,
+		`bloom_queries_total{filter="users"} 1`,
+		`bloom_positives_total{filter="users"} 1`,
+		`bloom_rejections_total{filter="users"} 0`,
+		`bloom_entries{filter="users"} 1`,
+		`bloom_fill_ratio{filter="users"} 0.015625`,
+		`bloom_false_positive_rate{filter="users"} 0.015625`,
+		`bloom_puts_total{filter="odd \"name\"\\"} 2`,
+		"# TYPE bloom_fill_ratio gauge",
+	} {
+		assert.Contains(t, body, line+"\n")
+	}
+
+	// filters are sorted by name
+	assert.Less(t, strings.Index(body, `bloom_entries{filter="odd`), strings.Index(body, `bloom_entries{filter="users"}`))
+}
+
+// response writer whose body cannot be written
+type failingResponseWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w failingResponseWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("connection closed")
+}
+
+func (w failingResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func TestHandlerWriteError(t *testing.T) {
+	r := NewRegistry()
+	b, err := bloom.NewBigBloomFromK(8, 1)
+	assert.Nil(t, err)
+	_, err = r.Instrument("users", b)
+	assert.Nil(t, err)
+	var errs []error
+	r.OnWriteError(func(err error) {
+		errs = append(errs, err)
+	})
+
+	// the write fails after the status is decided, so the caller sees the error through the callback
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(failingResponseWriter{rec}, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, 200, rec.Code)
+	assert.Equal(t, 1, len(errs))
+	assert.EqualError(t, errs[0], "connection closed")
+
+	// successful scrapes do not call the callbacks
+	r.Handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, 1, len(errs))
+}
+
+func TestEscapeLabel(t *testing.T) {
+	testCases := []struct {
+		value    string
+		expected string
+	}{
+		{"plain", "plain"},
+		{`a"b`, `a\"b`},
+		{`a\b`, `a\\b`},
+		{"a\nb", `a\nb`},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, escapeLabel(tc.value))
+	}
+}
@@ -0,0 +1,287 @@
+package bloom
+
+// Option configures a bloom filter built with New or NewBloom.
+// The size of a filter is set by exactly two of WithCapacity, WithFalsePositiveRate, WithLength (or WithBytes) and WithK.
+// The capacity and false positive rate only size the filter unless WithConstraints is given too.
+type Option func(*options) error
+
+// Hasher selects how elements are hashed to bits
+type Hasher struct {
+	// secret key of SipHash-2-4. nil selects SHA256 with a nonce
+	key *[BLOOM_KEY_LEN]byte
+}
+
+type options struct {
+	// number of unique entries the filter is sized for
+	cap *int
+
+	// false positive rate at capacity the filter is sized for
+	maxFalsePositiveRate *float64
+
+	// number of bytes
+	len *int
+
+	// bytes of a filter to load
+	bs []byte
+
+	// number of hash functions
+	k *int
+
+	// how elements are hashed
+	hasher Hasher
+
+	// whether capacity and false positive rate are enforced
+	constraints bool
+}
+
+//
+// Options
+//
+
+// Sizes filter for cap unique entries
+func WithCapacity(cap int) Option {
+	return func(o *options) error {
+		if cap < 1 {
+			return newParameterError("cap", cap, "capacity cannot be less than 1")
+		}
+		o.cap = &cap
+		return nil
+	}
+}
+
+// Sizes filter for a false positive rate of maxFalsePositiveRate at capacity
+func WithFalsePositiveRate(maxFalsePositiveRate float64) Option {
+	return func(o *options) error {
+		if maxFalsePositiveRate <= 0 || maxFalsePositiveRate >= 1 {
+			return newParameterError("maxFalsePositiveRate", maxFalsePositiveRate, "false positive rate must be between 0 and 1")
+		}
+		o.maxFalsePositiveRate = &maxFalsePositiveRate
+		return nil
+	}
+}
+
+// Sizes filter to len bytes
+func WithLength(len int) Option {
+	return func(o *options) error {
+		if len < 1 {
+			return newParameterError("len", len, "bloom filter length cannot be 0")
+		}
+		o.len = &len
+		return nil
+	}
+}
+
+// Loads filter from a copy of bs, such as bytes received over the wire.
+// Like the FromBytes constructors, this disables accuracy calculations and constraints because n is unknown.
+func WithBytes(bs []byte) Option {
+	return func(o *options) error {
+		if len(bs) == 0 {
+			return newParameterError("bs", bs, "bloom filter length cannot be 0")
+		}
+		o.bs = append([]byte(nil), bs...)
+		return nil
+	}
+}
+
+// Sets number of hash functions to k
+func WithK(k int) Option {
+	return func(o *options) error {
+		if err := checkK(k); err != nil {
+			return err
+		}
+		o.k = &k
+		return nil
+	}
+}
+
+// Hashes elements with hasher. The default is SHA256Hasher
+func WithHasher(hasher Hasher) Option {
+	return func(o *options) error {
+		o.hasher = hasher
+		return nil
+	}
+}
+
+// Enforces the capacity and false positive rate of the filter, like AddCapacityConstraint and AddAccuracyConstraint.
+// The given capacity and false positive rate are used, and the planned ones fill in what is not given.
+func WithConstraints() Option {
+	return func(o *options) error {
+		o.constraints = true
+		return nil
+	}
+}
+
+// Hashes elements with SHA256 and an appended nonce
+func SHA256Hasher() Hasher {
+	return Hasher{
+		key: nil,
+	}
+}
+
+// Hashes elements with SipHash-2-4 keyed by the secret key, see BigBloom.AddKey
+func SipHasher(key [BLOOM_KEY_LEN]byte) Hasher {
+	return Hasher{
+		key: &key,
+	}
+}
+
+//
+// Constructors
+//
+
+// Constructs bloom filter of any length from options
+func New(opts ...Option) (*BigBloom, error) {
+	o, err := applyOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	if o.len != nil && o.bs != nil {
+		return nil, newParameterError("opts", nil, "cannot give both length and bytes")
+	}
+	m := 0
+	if o.len != nil {
+		m = 8 * *o.len
+	}
+	if o.bs != nil {
+		m = 8 * len(o.bs)
+	}
+	if o.count(m) != 2 {
+		return nil, newParameterError("opts", nil, "exactly two of capacity, false positive rate, length and k must be given")
+	}
+	plan, err := o.plan(m)
+	if err != nil {
+		return nil, err
+	}
+
+	words := make([]uint64, wordsLen(plan.Bytes))
+	if o.bs != nil {
+		words = bytesToWords(o.bs)
+	}
+	b := &BigBloom{
+		n:                    0,
+		k:                    plan.K,
+		words:                words,
+		len:                  plan.Bytes,
+		maxFalsePositiveRate: nil,
+		cap:                  nil,
+		isLoaded:             o.bs != nil,
+		key:                  o.hasher.key,
+	}
+	if o.constraints {
+		if err := b.AddCapacityConstraint(plan.N); err != nil {
+			return nil, err
+		}
+		if err := b.AddAccuracyConstraint(o.constrainedFalsePositiveRate(plan)); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// Constructs 512-bit bloom filter from options. The length is always 64 bytes, so exactly one of
+// WithCapacity, WithFalsePositiveRate and WithK must be given. Only SHA256 hashing is supported.
+func NewBloom(opts ...Option) (*Bloom, error) {
+	o, err := applyOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	if (o.len != nil && *o.len != BLOOM_LEN) || (o.bs != nil && len(o.bs) != BLOOM_LEN) {
+		return nil, newParameterError("opts", nil, "512-bit bloom filter must be 64 bytes")
+	}
+	if o.hasher.key != nil {
+		return nil, newParameterError("opts", nil, "512-bit bloom filter only supports SHA256 hashing")
+	}
+	if o.count(8*BLOOM_LEN) != 2 {
+		return nil, newParameterError("opts", nil, "512-bit bloom filter takes exactly one of capacity, false positive rate and k")
+	}
+	plan, err := o.plan(8 * BLOOM_LEN)
+	if err != nil {
+		return nil, err
+	}
+
+	var bs [BLOOM_LEN]byte
+	copy(bs[:], o.bs)
+	b := &Bloom{
+		n:                    0,
+		k:                    plan.K,
+		bs:                   bs,
+		len:                  BLOOM_LEN,
+		maxFalsePositiveRate: nil,
+		cap:                  nil,
+		isLoaded:             o.bs != nil,
+	}
+	if o.constraints {
+		if err := b.AddCapacityConstraint(plan.N); err != nil {
+			return nil, err
+		}
+		if err := b.AddAccuracyConstraint(o.constrainedFalsePositiveRate(plan)); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+//
+// helpers
+//
+
+// applies opts in order. Later options override earlier ones
+func applyOptions(opts []Option) (*options, error) {
+	o := &options{
+		hasher: SHA256Hasher(),
+	}
+	for _, opt := range opts {
+		if err := opt(o); err != nil {
+			return nil, err
+		}
+	}
+	return o, nil
+}
+
+// count the size parameters given, with m bits
+func (o *options) count(m int) int {
+	given := 0
+	for _, isGiven := range []bool{o.cap != nil, o.maxFalsePositiveRate != nil, m > 0, o.k != nil} {
+		if isGiven {
+			given++
+		}
+	}
+	return given
+}
+
+// plan filter of m bits from the size parameters given
+func (o *options) plan(m int) (*Plan, error) {
+	n, p, k := 0, 0.0, 0
+	if o.cap != nil {
+		n = *o.cap
+	}
+	if o.maxFalsePositiveRate != nil {
+		p = *o.maxFalsePositiveRate
+	}
+	if o.k != nil {
+		k = *o.k
+	}
+	if p > 0 && k > 0 {
+		return nil, newParameterError("opts", []interface{}{p, k}, "false positive rate and k cannot size a filter without capacity or length")
+	}
+	plan, err := NewPlan(n, p, m, k)
+	if err != nil {
+		return nil, err
+	}
+	// rounding k can put the rate at capacity slightly above p, so grow the filter a byte at a time until it is within p
+	for n > 0 && p > 0 && plan.P > p {
+		plan, err = NewPlan(n, 0, plan.M+8, 0)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return plan, nil
+}
+
+// false positive rate to constrain filter to: the one given, or else the planned one
+func (o *options) constrainedFalsePositiveRate(plan *Plan) float64 {
+	if o.maxFalsePositiveRate != nil {
+		return *o.maxFalsePositiveRate
+	}
+	return plan.P
+}
@@ -0,0 +1,181 @@
+package bloom
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewOptionErrors(t *testing.T) {
+	type errorTest struct {
+		opts     []Option
+		expected string
+	}
+
+	tests := []errorTest{
+		{opts: []Option{WithCapacity(0), WithK(3)}, expected: "capacity cannot be less than 1"},
+		{opts: []Option{WithFalsePositiveRate(1), WithK(3)}, expected: "false positive rate must be between 0 and 1"},
+		{opts: []Option{WithLength(0), WithK(3)}, expected: "bloom filter length cannot be 0"},
+		{opts: []Option{WithBytes(nil), WithK(3)}, expected: "bloom filter length cannot be 0"},
+		{opts: []Option{WithLength(8), WithK(0)}, expected: "k cannot be less than 1"},
+		{opts: []Option{WithLength(8), WithBytes(make([]byte, 8)), WithK(3)}, expected: "cannot give both length and bytes"},
+		{opts: nil, expected: "exactly two of capacity, false positive rate, length and k must be given"},
+		{opts: []Option{WithCapacity(100)}, expected: "exactly two of capacity, false positive rate, length and k must be given"},
+		{opts: []Option{WithCapacity(100), WithFalsePositiveRate(.01), WithLength(128)}, expected: "exactly two of capacity, false positive rate, length and k must be given"},
+		{opts: []Option{WithFalsePositiveRate(.01), WithK(7)}, expected: "false positive rate and k cannot size a filter without capacity or length"},
+		{opts: []Option{WithBytes(make([]byte, 8)), WithK(3), WithConstraints()}, expected: "cannot add constraints to loaded bloom filters"},
+	}
+
+	for _, test := range tests {
+		_, err := New(test.opts...)
+		assert.EqualError(t, err, test.expected)
+	}
+}
+
+func TestNewMatchesConstructors(t *testing.T) {
+	type matchTest struct {
+		opts     []Option
+		expected func() (*BigBloom, error)
+	}
+
+	tests := []matchTest{
+		{
+			opts:     []Option{WithLength(128), WithK(3)},
+			expected: func() (*BigBloom, error) { return NewBigBloomFromK(128, 3) },
+		},
+		{
+			opts:     []Option{WithLength(128), WithCapacity(100)},
+			expected: func() (*BigBloom, error) { return NewBigBloomFromCap(128, 100) },
+		},
+		{
+			opts:     []Option{WithLength(128), WithFalsePositiveRate(.01)},
+			expected: func() (*BigBloom, error) { return NewBigBloomFromAcc(128, .01) },
+		},
+		{
+			opts:     []Option{WithCapacity(1234), WithFalsePositiveRate(.5)},
+			expected: func() (*BigBloom, error) { return NewBigBloomAlloc(1234, .5) },
+		},
+		{
+			opts:     []Option{WithBytes([]byte{1, 2, 3}), WithK(3)},
+			expected: func() (*BigBloom, error) { return NewBigBloomFromBytes([]byte{1, 2, 3}, 3) },
+		},
+	}
+
+	for _, test := range tests {
+		b, err := New(test.opts...)
+		assert.Nil(t, err)
+		expected, err := test.expected()
+		assert.Nil(t, err)
+		assert.Equal(t, expected.len, b.len)
+		assert.Equal(t, expected.k, b.k)
+		assert.Equal(t, expected.Hex(), b.Hex())
+		assert.Equal(t, expected.isLoaded, b.isLoaded)
+		// constraints are only set with WithConstraints
+		assert.Nil(t, b.cap)
+		assert.Nil(t, b.maxFalsePositiveRate)
+	}
+}
+
+func TestNewWithConstraints(t *testing.T) {
+	// the given capacity and false positive rate are both kept to, unlike NewBigBloomAlloc which can pass the rate before capacity
+	b, err := New(WithCapacity(1000), WithFalsePositiveRate(.01), WithConstraints())
+	assert.Nil(t, err)
+	assert.Equal(t, 1000, *b.cap)
+	assert.Equal(t, .01, *b.maxFalsePositiveRate)
+	i := 0
+	for ; err == nil; i++ {
+		_, err = b.PutStr(strconv.Itoa(i))
+	}
+	assert.IsType(t, &CapacityError{}, err)
+	assert.LessOrEqual(t, b.Accuracy(), .01)
+
+	// the planned capacity fills in when only the length and false positive rate are given
+	b, err = New(WithLength(128), WithFalsePositiveRate(.01), WithConstraints())
+	assert.Nil(t, err)
+	plan, err := NewPlan(0, .01, 8*128, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, plan.N, *b.cap)
+	assert.Equal(t, .01, *b.maxFalsePositiveRate)
+
+	// and both fill in from length and k
+	b, err = New(WithLength(128), WithK(3), WithConstraints())
+	assert.Nil(t, err)
+	plan, err = NewPlan(0, 0, 8*128, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, plan.N, *b.cap)
+	assert.Equal(t, plan.P, *b.maxFalsePositiveRate)
+}
+
+func TestNewWithHasher(t *testing.T) {
+	key := [BLOOM_KEY_LEN]byte{1}
+	keyed, err := New(WithLength(128), WithK(3), WithHasher(SipHasher(key)))
+	assert.Nil(t, err)
+	assert.Equal(t, key, *keyed.key)
+	unkeyed, err := New(WithLength(128), WithK(3), WithHasher(SHA256Hasher()))
+	assert.Nil(t, err)
+	assert.Nil(t, unkeyed.key)
+
+	keyed.PutStr("a")
+	unkeyed.PutStr("a")
+	assert.NotEqual(t, keyed.Hex(), unkeyed.Hex())
+	exists, _ := keyed.ExistsStr("a")
+	assert.True(t, exists)
+}
+
+func TestNewBloom(t *testing.T) {
+	type errorTest struct {
+		opts     []Option
+		expected string
+	}
+
+	errorTests := []errorTest{
+		{opts: []Option{WithLength(32), WithK(3)}, expected: "512-bit bloom filter must be 64 bytes"},
+		{opts: []Option{WithBytes(make([]byte, 32)), WithK(3)}, expected: "512-bit bloom filter must be 64 bytes"},
+		{opts: []Option{WithK(3), WithHasher(SipHasher([BLOOM_KEY_LEN]byte{}))}, expected: "512-bit bloom filter only supports SHA256 hashing"},
+		{opts: []Option{WithCapacity(10), WithK(3)}, expected: "512-bit bloom filter takes exactly one of capacity, false positive rate and k"},
+		{opts: nil, expected: "512-bit bloom filter takes exactly one of capacity, false positive rate and k"},
+	}
+
+	for _, test := range errorTests {
+		_, err := NewBloom(test.opts...)
+		assert.EqualError(t, err, test.expected)
+	}
+
+	type matchTest struct {
+		opts     []Option
+		expected func() (*Bloom, error)
+	}
+
+	tests := []matchTest{
+		{
+			opts:     []Option{WithK(3)},
+			expected: func() (*Bloom, error) { return NewBloomFromK(3) },
+		},
+		{
+			opts:     []Option{WithCapacity(20)},
+			expected: func() (*Bloom, error) { return NewBloomFromCap(20) },
+		},
+		{
+			opts:     []Option{WithFalsePositiveRate(.01)},
+			expected: func() (*Bloom, error) { return NewBloomFromAcc(.01) },
+		},
+		{
+			opts:     []Option{WithBytes(make([]byte, BLOOM_LEN)), WithK(4)},
+			expected: func() (*Bloom, error) { return NewBloomFromBytes([BLOOM_LEN]byte{}, 4) },
+		},
+	}
+
+	for _, test := range tests {
+		b, err := NewBloom(test.opts...)
+		assert.Nil(t, err)
+		expected, err := test.expected()
+		assert.Nil(t, err)
+		assert.Equal(t, *expected, *b)
+	}
+
+	// constraints
+	b, err := NewBloom(WithCapacity(20), WithConstraints())
+	assert.Nil(t, err)
+	assert.Equal(t, "512-bit bloom filter: 0 unique entries, max cap 20, max false positive rate "+strconv.FormatFloat(*b.maxFalsePositiveRate, 'f', 6, 64), b.String())
+}
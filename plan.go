@@ -0,0 +1,187 @@
+package bloom
+
+import (
+	"fmt"
+	"math"
+)
+
+// Plan is the full set of parameters of a bloom filter, calculated with the same math BigBloom uses at runtime.
+// The number of bits is always a multiple of 8 because filters are allocated in bytes.
+type Plan struct {
+	// capacity: number of unique entries planned for
+	N int
+
+	// false positive rate with N entries
+	P float64
+
+	// number of bits
+	M int
+
+	// number of hash functions
+	K int
+
+	// memory of the filter bytes
+	Bytes int
+
+	// bits of memory per entry at capacity
+	BitsPerElement float64
+}
+
+// PlanPoint is the false positive rate of a planned filter at a fill level
+type PlanPoint struct {
+	// number of unique entries
+	N int
+
+	// share of capacity used
+	Fill float64
+
+	// false positive rate with N entries
+	FalsePositiveRate float64
+}
+
+//
+// Constructors
+//
+
+// Plans bloom filter from exactly two of capacity n, false positive rate p, number of bits m and number of hash functions k.
+// The other two must be 0.
+// Given n and p, the filter is sized the same as NewBigBloomAlloc, and P is the rate reached with the rounded m and k, which can be slightly above p.
+// Given p and m, N is the most entries that keep the rate at or below p.
+// p and k only fix the bits per element, so they cannot be given together.
+func NewPlan(n int, p float64, m, k int) (*Plan, error) {
+	if n < 0 {
+		return nil, newParameterError("n", n, "capacity cannot be less than 1")
+	}
+	if p < 0 || p >= 1 {
+		return nil, newParameterError("p", p, "false positive rate must be between 0 and 1")
+	}
+	if m < 0 {
+		return nil, newParameterError("m", m, "number of bits cannot be less than 1")
+	}
+	if k < 0 {
+		return nil, newParameterError("k", k, "k cannot be less than 1")
+	}
+	if k > BLOOM_MAX_K {
+		return nil, newParameterError("k", k, fmt.Sprintf("k cannot be more than %d", BLOOM_MAX_K))
+	}
+	given := 0
+	for _, isGiven := range []bool{n > 0, p > 0, m > 0, k > 0} {
+		if isGiven {
+			given++
+		}
+	}
+	if given != 2 {
+		return nil, newParameterError("n, p, m, k", []interface{}{n, p, m, k}, "exactly two of n, p, m and k must be given")
+	}
+
+	var len int
+	switch {
+	case n > 0 && p > 0:
+		len = calcLenFromCapAcc(n, p)
+		k = calcKFromCap(len, n)
+	case n > 0 && m > 0:
+		len = bitsToLen(m)
+		k = calcKFromCap(len, n)
+	case n > 0 && k > 0:
+		// k = ln(2) * m/n rearranged: m = k*n/ln(2)
+		len = bitsToLen(int(math.Ceil(float64(k*n) / math.Log(2))))
+	case p > 0 && m > 0:
+		len = bitsToLen(m)
+		k = calcKFromAcc(len, p)
+		n = calcCapFromAcc(len, k, p)
+		if n < 1 {
+			return nil, newParameterError("p", p, "false positive rate cannot be reached with 1 entry in m bits")
+		}
+	case m > 0 && k > 0:
+		len = bitsToLen(m)
+		// k = ln(2) * m/n rearranged: n = ln(2) * m/k
+		n = int(math.Max(1, math.Floor(math.Log(2)*float64(8*len)/float64(k))))
+	default:
+		return nil, newParameterError("p, k", []interface{}{p, k}, "p and k only fix the bits per element, n or m must be given too")
+	}
+
+	return &Plan{
+		N:              n,
+		P:              falsePositiveRate(len, n, k),
+		M:              8 * len,
+		K:              k,
+		Bytes:          len,
+		BitsPerElement: float64(8*len) / float64(n),
+	}, nil
+}
+
+//
+// Methods
+//
+
+// False positive rate of planned filter with n unique entries
+func (p *Plan) FalsePositiveRate(n int) float64 {
+	if n == 0 {
+		return 0
+	}
+	return falsePositiveRate(p.Bytes, n, p.K)
+}
+
+// False positive rates of planned filter at steps+1 evenly spaced fill levels from empty to capacity
+func (p *Plan) Curve(steps int) []PlanPoint {
+	if steps < 1 {
+		steps = 1
+	}
+	points := make([]PlanPoint, steps+1)
+	for i := range points {
+		n := int(math.Round(float64(p.N) * float64(i) / float64(steps)))
+		points[i] = PlanPoint{
+			N:                 n,
+			Fill:              float64(n) / float64(p.N),
+			FalsePositiveRate: p.FalsePositiveRate(n),
+		}
+	}
+	return points
+}
+
+// Constructs bloom filter of planned size, constrained to the planned capacity and false positive rate
+func (p *Plan) NewBigBloom() (*BigBloom, error) {
+	b, err := NewBigBloomFromK(p.Bytes, p.K)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.AddCapacityConstraint(p.N); err != nil {
+		return nil, err
+	}
+	if err := b.AddAccuracyConstraint(p.P); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (p *Plan) String() string {
+	return fmt.Sprintf("bloom filter plan: n %d, p %f, m %d bits (%d bytes), k %d, %.2f bits per element", p.N, p.P, p.M, p.Bytes, p.K, p.BitsPerElement)
+}
+
+//
+// helpers
+//
+
+// calculate len of filter in bytes that holds m bits
+func bitsToLen(m int) int {
+	return int(math.Ceil(float64(m) / 8))
+}
+
+// calculate the most entries a filter of len bytes and k hashes holds with false positive rate at most acc
+func calcCapFromAcc(len, k int, acc float64) int {
+	// the rate grows with n, so search for the last n within acc
+	hi := 1
+	for falsePositiveRate(len, hi, k) <= acc {
+		hi *= 2
+	}
+	lo := 0
+	for lo+1 < hi {
+		mid := (lo + hi) / 2
+		if falsePositiveRate(len, mid, k) <= acc {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return lo
+}
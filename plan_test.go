@@ -0,0 +1,123 @@
+package bloom
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPlan(t *testing.T) {
+	type errorTest struct {
+		n        int
+		p        float64
+		m        int
+		k        int
+		expected string
+	}
+
+	errorTests := []errorTest{
+		{n: -1, p: .01, expected: "capacity cannot be less than 1"},
+		{n: 100, p: 1, expected: "false positive rate must be between 0 and 1"},
+		{n: 100, m: -1, expected: "number of bits cannot be less than 1"},
+		{n: 100, k: -1, expected: "k cannot be less than 1"},
+		{n: 100, expected: "exactly two of n, p, m and k must be given"},
+		{n: 100, p: .01, m: 1000, expected: "exactly two of n, p, m and k must be given"},
+		{p: .01, k: 7, expected: "p and k only fix the bits per element, n or m must be given too"},
+		{p: .0000001, m: 8, expected: "false positive rate cannot be reached with 1 entry in m bits"},
+	}
+
+	for _, test := range errorTests {
+		_, err := NewPlan(test.n, test.p, test.m, test.k)
+		assert.EqualError(t, err, test.expected)
+	}
+
+	type planTest struct {
+		n        int
+		p        float64
+		m        int
+		k        int
+		expected Plan
+	}
+
+	tests := []planTest{
+		{
+			// same as NewBigBloomAlloc(1000, .01)
+			n:        1000,
+			p:        .01,
+			expected: Plan{N: 1000, M: 9592, K: 7, Bytes: 1199},
+		},
+		{
+			n:        1000,
+			m:        9592,
+			expected: Plan{N: 1000, M: 9592, K: 7, Bytes: 1199},
+		},
+		{
+			// m is rounded up to whole bytes
+			n:        1000,
+			k:        7,
+			expected: Plan{N: 1000, M: 10104, K: 7, Bytes: 1263},
+		},
+		{
+			m:        9592,
+			k:        7,
+			expected: Plan{N: 949, M: 9592, K: 7, Bytes: 1199},
+		},
+		{
+			// rounding k makes 1000 entries slightly exceed .01
+			p:        .01,
+			m:        9592,
+			expected: Plan{N: 999, M: 9592, K: 7, Bytes: 1199},
+		},
+	}
+
+	for _, test := range tests {
+		plan, err := NewPlan(test.n, test.p, test.m, test.k)
+		assert.Nil(t, err)
+		assert.Equal(t, test.expected.N, plan.N)
+		assert.Equal(t, test.expected.M, plan.M)
+		assert.Equal(t, test.expected.K, plan.K)
+		assert.Equal(t, test.expected.Bytes, plan.Bytes)
+		assert.Equal(t, float64(plan.M)/float64(plan.N), plan.BitsPerElement)
+		assert.Equal(t, falsePositiveRate(plan.Bytes, plan.N, plan.K), plan.P)
+		if test.p > 0 && test.m > 0 {
+			assert.LessOrEqual(t, plan.P, test.p)
+		}
+	}
+}
+
+func TestPlanMatchesBigBloomAlloc(t *testing.T) {
+	for _, n := range []int{1, 10, 1000, 123456} {
+		for _, p := range []float64{.5, .01, .0001} {
+			plan, err := NewPlan(n, p, 0, 0)
+			assert.Nil(t, err)
+			b, err := NewBigBloomAlloc(n, p)
+			assert.Nil(t, err)
+			assert.Equal(t, b.len, plan.Bytes)
+			assert.Equal(t, b.k, plan.K)
+		}
+	}
+}
+
+func TestPlanCurve(t *testing.T) {
+	plan, err := NewPlan(1000, .01, 0, 0)
+	assert.Nil(t, err)
+	curve := plan.Curve(4)
+	assert.Equal(t, 5, len(curve))
+	assert.Equal(t, PlanPoint{N: 0, Fill: 0, FalsePositiveRate: 0}, curve[0])
+	assert.Equal(t, 250, curve[1].N)
+	assert.Equal(t, .25, curve[1].Fill)
+	assert.Equal(t, plan.P, curve[4].FalsePositiveRate)
+	for i := 1; i < len(curve); i++ {
+		assert.Greater(t, curve[i].FalsePositiveRate, curve[i-1].FalsePositiveRate)
+	}
+}
+
+func TestPlanNewBigBloom(t *testing.T) {
+	plan, err := NewPlan(100, .01, 0, 0)
+	assert.Nil(t, err)
+	b, err := plan.NewBigBloom()
+	assert.Nil(t, err)
+	assert.Equal(t, plan.Bytes, b.len)
+	assert.Equal(t, plan.K, b.k)
+	assert.Equal(t, "bloom filter plan: n 100, p 0.009990, m 960 bits (120 bytes), k 7, 9.60 bits per element", plan.String())
+}
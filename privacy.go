@@ -0,0 +1,130 @@
+package bloom
+
+import (
+	"fmt"
+	"math"
+)
+
+// PrivacyReport describes how well a filter built with NewPrivacyPaddedBloom hides its real items
+// among the universe of elements it is matched against.
+type PrivacyReport struct {
+	// number of real items
+	Items int
+
+	// number of explicit decoys inserted
+	Decoys int
+
+	// number of elements the filter is matched against, such as all transactions a full node checks
+	UniverseSize int
+
+	// false positive rate of the filter with the items and decoys inserted
+	FalsePositiveRate float64
+
+	// expected number of matching elements that are not real items: the explicit decoys plus the false positives
+	ExpectedDecoys float64
+
+	// expected number of matching elements, real or not
+	ExpectedMatches float64
+
+	// probability that a matching element is not a real item. An observer learns little while this is close to 1
+	PlausibleDeniability float64
+}
+
+//
+// Constructors
+//
+
+// Constructs bloom filter of items that matches about targetDecoys other elements of a universe of universeSize elements.
+// The explicit decoys, which may be nil, are inserted too and count towards targetDecoys.
+// m and k are picked so that false positives make up the rest of the decoys.
+// With few items the false positive rate of the filter built can be far from the target, so the report is calculated from the filter's set bits.
+// The filter is constrained to the items and decoys inserted, since inserting more elements would change the report.
+func NewPrivacyPaddedBloom(items [][]byte, universeSize, targetDecoys int, decoys [][]byte) (*BigBloom, *PrivacyReport, error) {
+	if len(items) < 1 {
+		return nil, nil, newParameterError("items", items, "number of items cannot be less than 1")
+	}
+	isItem := make(map[string]bool, len(items))
+	for _, item := range items {
+		isItem[string(item)] = true
+	}
+	isDecoy := make(map[string]bool, len(decoys))
+	for _, decoy := range decoys {
+		if isItem[string(decoy)] {
+			return nil, nil, newParameterError("decoys", decoy, "decoys cannot include items")
+		}
+		isDecoy[string(decoy)] = true
+	}
+	numItems, numDecoys := len(isItem), len(isDecoy)
+	if universeSize < numItems+numDecoys {
+		return nil, nil, newParameterError("universeSize", universeSize, "universe size cannot be less than the number of items and decoys")
+	}
+	// elements of the universe that can only match by being a false positive
+	others := universeSize - numItems - numDecoys
+	if targetDecoys <= numDecoys {
+		return nil, nil, newParameterError("targetDecoys", targetDecoys, "target decoys must be more than the number of explicit decoys")
+	}
+	if targetDecoys-numDecoys >= others {
+		return nil, nil, newParameterError("targetDecoys", targetDecoys, "target decoys must be less than the universe size minus the items")
+	}
+
+	fpr := float64(targetDecoys-numDecoys) / float64(others)
+	b, err := NewBigBloomAlloc(numItems+numDecoys, fpr)
+	if err != nil {
+		return nil, nil, err
+	}
+	// the false positive rate is a target to reach, not a limit.
+	// The capacity is constrained after inserting, since items that are false positives of other items do not count
+	b.cap = nil
+	b.maxFalsePositiveRate = nil
+	for _, item := range items {
+		if _, err := b.PutBytes(item); err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, decoy := range decoys {
+		if _, err := b.PutBytes(decoy); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := b.AddCapacityConstraint(b.n); err != nil {
+		return nil, nil, err
+	}
+
+	report := newPrivacyReport(numItems, numDecoys, universeSize, filledFalsePositiveRate(b))
+	return b, report, nil
+}
+
+//
+// Methods
+//
+
+func (r *PrivacyReport) String() string {
+	return fmt.Sprintf("%d items and %d decoys in a universe of %d: false positive rate %f, %.1f expected decoys, plausible deniability %f",
+		r.Items, r.Decoys, r.UniverseSize, r.FalsePositiveRate, r.ExpectedDecoys, r.PlausibleDeniability)
+}
+
+//
+// helpers
+//
+
+// calculate privacy report of a filter with false positive rate fpr
+func newPrivacyReport(items, decoys, universeSize int, fpr float64) *PrivacyReport {
+	expectedDecoys := float64(decoys) + fpr*float64(universeSize-items-decoys)
+	expectedMatches := float64(items) + expectedDecoys
+	return &PrivacyReport{
+		Items:                items,
+		Decoys:               decoys,
+		UniverseSize:         universeSize,
+		FalsePositiveRate:    fpr,
+		ExpectedDecoys:       expectedDecoys,
+		ExpectedMatches:      expectedMatches,
+		PlausibleDeniability: expectedDecoys / expectedMatches,
+	}
+}
+
+// calculate false positive rate of b from the share of its bits that are set.
+// For small filters this is much closer to the real rate than the estimate from n, which averages over all possible filters
+func filledFalsePositiveRate(b *BigBloom) float64 {
+	return math.Pow(b.FillRatio(), float64(b.k))
+}
@@ -0,0 +1,106 @@
+package bloom
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPrivacyPaddedBloom(t *testing.T) {
+	items := fuseTestKeys("tx", 10)
+
+	type errorTest struct {
+		items        [][]byte
+		universeSize int
+		targetDecoys int
+		decoys       [][]byte
+		expected     string
+	}
+
+	tests := []errorTest{
+		{items: nil, universeSize: 100, targetDecoys: 10, expected: "number of items cannot be less than 1"},
+		{items: items, universeSize: 100, targetDecoys: 10, decoys: items[:1], expected: "decoys cannot include items"},
+		{items: items, universeSize: 5, targetDecoys: 10, expected: "universe size cannot be less than the number of items and decoys"},
+		{items: items, universeSize: 100, targetDecoys: 2, decoys: fuseTestKeys("decoy", 2), expected: "target decoys must be more than the number of explicit decoys"},
+		{items: items, universeSize: 100, targetDecoys: 90, expected: "target decoys must be less than the universe size minus the items"},
+	}
+
+	for _, test := range tests {
+		_, _, err := NewPrivacyPaddedBloom(test.items, test.universeSize, test.targetDecoys, test.decoys)
+		assert.EqualError(t, err, test.expected)
+	}
+}
+
+func TestPrivacyPaddedBloomDecoys(t *testing.T) {
+	universeSize := 200000
+	items := fuseTestKeys("tx", 200)
+
+	type decoyTest struct {
+		targetDecoys int
+		decoys       [][]byte
+	}
+
+	tests := []decoyTest{
+		{targetDecoys: 100, decoys: nil},
+		{targetDecoys: 1000, decoys: nil},
+		{targetDecoys: 100, decoys: fuseTestKeys("decoy", 40)},
+	}
+
+	for _, test := range tests {
+		b, report, err := NewPrivacyPaddedBloom(items, universeSize, test.targetDecoys, test.decoys)
+		assert.Nil(t, err)
+		assert.Equal(t, 200, report.Items)
+		assert.Equal(t, len(test.decoys), report.Decoys)
+		assert.Equal(t, universeSize, report.UniverseSize)
+		// rounding m and k keeps the expected decoys close to the target
+		assert.InEpsilon(t, float64(test.targetDecoys), report.ExpectedDecoys, .2)
+		assert.Equal(t, float64(200)+report.ExpectedDecoys, report.ExpectedMatches)
+		assert.Equal(t, report.ExpectedDecoys/report.ExpectedMatches, report.PlausibleDeniability)
+
+		// every item and decoy matches
+		for _, item := range items {
+			exists, _ := b.ExistsBytes(item)
+			assert.True(t, exists)
+		}
+		for _, decoy := range test.decoys {
+			exists, _ := b.ExistsBytes(decoy)
+			assert.True(t, exists)
+		}
+
+		// matching the rest of the universe gives about the expected number of decoys
+		falsePositives := 0
+		others := universeSize - len(items) - len(test.decoys)
+		for i := 0; i < others; i++ {
+			if exists, _ := b.ExistsStr("other" + strconv.Itoa(i)); exists {
+				falsePositives++
+			}
+		}
+		expectedFalsePositives := report.ExpectedDecoys - float64(len(test.decoys))
+		assert.InEpsilon(t, expectedFalsePositives, float64(falsePositives), .35)
+
+		// the filter is constrained to what was inserted
+		_, err = b.PutStr("new")
+		assert.IsType(t, &CapacityError{}, err)
+	}
+}
+
+func TestPrivacyPaddedBloomFewItems(t *testing.T) {
+	// with few items the false positive rate of the filter varies a lot around the target, and the report reflects the filter built
+	universeSize := 200000
+	items := fuseTestKeys("tx", 5)
+	b, report, err := NewPrivacyPaddedBloom(items, universeSize, 500, nil)
+	assert.Nil(t, err)
+	falsePositives := 0
+	for i := 0; i < universeSize-len(items); i++ {
+		if exists, _ := b.ExistsStr("other" + strconv.Itoa(i)); exists {
+			falsePositives++
+		}
+	}
+	assert.InEpsilon(t, report.ExpectedDecoys, float64(falsePositives), .35)
+}
+
+func TestPrivacyReportString(t *testing.T) {
+	report := newPrivacyReport(10, 5, 1015, .01)
+	assert.Equal(t, "10 items and 5 decoys in a universe of 1015: false positive rate 0.010000, 15.0 expected decoys, plausible deniability 0.600000", report.String())
+}
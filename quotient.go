@@ -0,0 +1,544 @@
+package bloom
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// QuotientFilter is a quotient filter (Bender et al., 2012) that supports deletion, resizing and merging without the original keys.
+// Each entry is stored as an r-bit remainder in a table of 2^q slots, next to the slots of entries with nearby quotients,
+// so a lookup reads a short run of consecutive slots.
+type QuotientFilter struct {
+	// current number of unique entries
+	n int
+
+	// number of quotient bits: the table has 2^q slots
+	q uint
+
+	// number of remainder bits stored in each slot
+	r uint
+
+	// remainder << 3 | shifted | continuation | occupied
+	slots []uint64
+
+	// optional, maximum number of unique entries allowed
+	cap *int
+
+	// optional, the maximum allowed false positive rate until no more entries accepted
+	maxFalsePositiveRate *float64
+}
+
+// metadata bits of a slot
+const (
+	// the slot is the canonical slot of at least one entry
+	qfOccupied uint64 = 1 << iota
+
+	// the slot holds an entry that is not the first of its run
+	qfContinuation
+
+	// the slot holds an entry that is not in its canonical slot
+	qfShifted
+
+	qfMetadataBits = 3
+)
+
+// maximum quotient bits, 2^32 slots
+const qfMaxQ = 32
+
+// load factor NewQuotientFilterAlloc sizes the table for
+const qfAllocLoad = 0.75
+
+// quotient and remainder of an entry
+type qfEntry struct {
+	q uint64
+	r uint64
+}
+
+// QuotientFilter has the same interface as BigBloom
+var _ MembershipFilter[*QuotientFilter] = (*QuotientFilter)(nil)
+
+//
+// Constructors
+//
+
+// Constructs quotient filter with 2^q slots of r-bit remainders. q+r bits of each entry's hash are kept,
+// so the false positive rate of n entries is about n/2^(q+r).
+func NewQuotientFilter(q, r int) (*QuotientFilter, error) {
+	if q < 1 || q > qfMaxQ {
+		return nil, newParameterError("q", q, fmt.Sprintf("quotient bits must be between 1 and %d", qfMaxQ))
+	}
+	if r < 1 || r > 64-qfMetadataBits {
+		return nil, newParameterError("r", r, fmt.Sprintf("remainder bits must be between 1 and %d", 64-qfMetadataBits))
+	}
+	if q+r > 64 {
+		return nil, newParameterError("r", r, "quotient and remainder bits cannot be more than 64")
+	}
+	return &QuotientFilter{
+		n:                    0,
+		q:                    uint(q),
+		r:                    uint(r),
+		slots:                make([]uint64, 1<<q),
+		cap:                  nil,
+		maxFalsePositiveRate: nil,
+	}, nil
+}
+
+// Constructs quotient filter with cap and maxFalsePositiveRate
+func NewQuotientFilterAlloc(cap int, maxFalsePositiveRate float64) (*QuotientFilter, error) {
+	if cap < 1 {
+		return nil, newParameterError("cap", cap, "capacity cannot be less than 1")
+	}
+	if maxFalsePositiveRate <= 0 || maxFalsePositiveRate >= 1 {
+		return nil, newParameterError("maxFalsePositiveRate", maxFalsePositiveRate, "false positive rate must be between 0 and 1")
+	}
+
+	// math:
+	// slots: cap / 2^q <= load
+	// q = log2(cap/load)
+	// false positive rate: 1 - (1 - 2^-(q+r))^cap <= acc
+	// q+r = -log2(1 - (1-acc)^(1/cap))
+	q := int(math.Ceil(math.Log2(float64(cap) / qfAllocLoad)))
+	if q < 1 {
+		q = 1
+	}
+	fingerprintBits := int(math.Ceil(-math.Log2(-math.Expm1(math.Log1p(-maxFalsePositiveRate) / float64(cap)))))
+	r := fingerprintBits - q
+	if r < 1 {
+		r = 1
+	}
+	f, err := NewQuotientFilter(q, r)
+	if err != nil {
+		return nil, err
+	}
+	f.cap = &cap
+	f.maxFalsePositiveRate = &maxFalsePositiveRate
+	return f, nil
+}
+
+//
+// Methods
+//
+
+// Inserts string element into quotient filter. Returns an error if a constraint is violated or the filter is full.
+func (f *QuotientFilter) PutStr(s string) (*QuotientFilter, error) {
+	bs := []byte(s)
+	return f.PutBytes(bs)
+}
+
+// Inserts bytes element into quotient filter. Returns an error if a constraint is violated or the filter is full.
+func (f *QuotientFilter) PutBytes(bs []byte) (*QuotientFilter, error) {
+	fq, fr := f.fingerprint(bs)
+	// if exists already just return filter and don't increase n
+	if f.contains(fq, fr) {
+		return f, nil
+	}
+
+	if f.cap != nil && f.n == *f.cap {
+		return f, &CapacityError{Cap: *f.cap, N: f.n}
+	}
+
+	if f.maxFalsePositiveRate != nil {
+		if projected := qfFalsePositiveRate(f.q+f.r, f.n+1); projected > *f.maxFalsePositiveRate {
+			return f, &AccuracyError{MaxFalsePositiveRate: *f.maxFalsePositiveRate, N: f.n, FalsePositiveRate: projected}
+		}
+	}
+
+	// at least one slot must stay empty so that every cluster has an end
+	if f.n+1 >= len(f.slots) {
+		return f, ErrFull
+	}
+
+	f.insert(fq, fr)
+	return f, nil
+}
+
+// Checks for existance of a string in a quotient filter. Returns boolean and false positive rate.
+func (f *QuotientFilter) ExistsStr(s string) (bool, float64) {
+	bs := []byte(s)
+	return f.ExistsBytes(bs)
+}
+
+// Checks for existance of bytes element in a quotient filter. Returns boolean and false positive rate.
+func (f *QuotientFilter) ExistsBytes(bs []byte) (bool, float64) {
+	fq, fr := f.fingerprint(bs)
+	if !f.contains(fq, fr) {
+		return false, 1
+	}
+	return true, f.Accuracy()
+}
+
+// Deletes string element from quotient filter. Returns false if it does not exist.
+func (f *QuotientFilter) DeleteStr(s string) bool {
+	bs := []byte(s)
+	return f.DeleteBytes(bs)
+}
+
+// Deletes bytes element from quotient filter. Returns false if it does not exist.
+// Deleting an element that was never inserted but shares its fingerprint with one that was removes the other element.
+func (f *QuotientFilter) DeleteBytes(bs []byte) bool {
+	fq, fr := f.fingerprint(bs)
+	return f.remove(fq, fr)
+}
+
+// Get false positive rate
+func (f *QuotientFilter) Accuracy() float64 {
+	if f.n == 0 {
+		return 1
+	}
+	return qfFalsePositiveRate(f.q+f.r, f.n)
+}
+
+// Doubles the number of slots by moving one bit of every entry from the remainder to the quotient.
+// The false positive rate stays the same because the number of hash bits kept does not change.
+func (f *QuotientFilter) Resize() error {
+	if f.r < 2 {
+		return &SizeError{Q: int(f.q), R: int(f.r), Reason: "cannot resize quotient filter: no remainder bits left"}
+	}
+	if f.q == qfMaxQ {
+		return &SizeError{Q: int(f.q), R: int(f.r), Reason: fmt.Sprintf("cannot resize quotient filter: quotient bits cannot be more than %d", qfMaxQ)}
+	}
+	f.rebuild(f.q+1, f.fingerprints())
+	return nil
+}
+
+// Adds all entries of other to f, resizing f if they do not fit.
+// Both filters must keep the same number of hash bits (q+r). Constraints of f are not checked.
+func (f *QuotientFilter) Merge(other *QuotientFilter) error {
+	if f.q+f.r != other.q+other.r {
+		return &MismatchError{Reason: "cannot merge quotient filters with different numbers of hash bits"}
+	}
+	fingerprints := append(f.fingerprints(), other.fingerprints()...)
+	q := f.q
+	if other.q > q {
+		q = other.q
+	}
+	for len(fingerprints) >= 1<<q {
+		q++
+	}
+	if q >= f.q+f.r || q > qfMaxQ {
+		return &SizeError{Q: int(f.q), R: int(f.r), Reason: "cannot merge quotient filters: merged filter would be too large"}
+	}
+	f.rebuild(q, fingerprints)
+	return nil
+}
+
+// Constrains quotient filter from not adding more than cap insertions
+func (f *QuotientFilter) AddCapacityConstraint(cap int) error {
+	if cap < 1 {
+		return newParameterError("cap", cap, "capacity cannot be less than 1")
+	}
+	if f.maxFalsePositiveRate != nil {
+		if projected := qfFalsePositiveRate(f.q+f.r, cap); projected > *f.maxFalsePositiveRate {
+			return &ConstraintError{Cap: cap, MaxFalsePositiveRate: *f.maxFalsePositiveRate, FalsePositiveRate: projected}
+		}
+	}
+	f.cap = &cap
+	return nil
+}
+
+// Constrains quotient filter from not adding insertions that would cause accuracy to be worse than maxFalsePositiveRate
+func (f *QuotientFilter) AddAccuracyConstraint(maxFalsePositiveRate float64) error {
+	if maxFalsePositiveRate <= 0 || maxFalsePositiveRate >= 1 {
+		return newParameterError("maxFalsePositiveRate", maxFalsePositiveRate, "false positive rate must be between 0 and 1")
+	}
+	if f.cap != nil {
+		if projected := qfFalsePositiveRate(f.q+f.r, *f.cap); projected > maxFalsePositiveRate {
+			return &ConstraintError{Cap: *f.cap, MaxFalsePositiveRate: maxFalsePositiveRate, FalsePositiveRate: projected}
+		}
+	}
+	f.maxFalsePositiveRate = &maxFalsePositiveRate
+	return nil
+}
+
+// Removes all entries, keeping the size and the constraints
+func (f *QuotientFilter) Reset() {
+	for i := range f.slots {
+		f.slots[i] = 0
+	}
+	f.n = 0
+}
+
+// Deep copies quotient filter, including its constraints
+func (f *QuotientFilter) Clone() *QuotientFilter {
+	cap, maxFalsePositiveRate := cloneConstraints(f.cap, f.maxFalsePositiveRate)
+	return &QuotientFilter{
+		n:                    f.n,
+		q:                    f.q,
+		r:                    f.r,
+		slots:                append([]uint64(nil), f.slots...),
+		cap:                  cap,
+		maxFalsePositiveRate: maxFalsePositiveRate,
+	}
+}
+
+// Checks whether f and other answer every query the same: same size and slots
+func (f *QuotientFilter) Equal(other *QuotientFilter) bool {
+	if f.q != other.q || f.r != other.r {
+		return false
+	}
+	for i := range f.slots {
+		if f.slots[i] != other.slots[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *QuotientFilter) String() string {
+	var buf strings.Builder
+
+	buf.WriteString(fmt.Sprintf("%d-slot quotient filter with %d-bit remainders: %d unique entries", len(f.slots), f.r, f.n))
+	if f.cap != nil {
+		buf.WriteString(fmt.Sprintf(", max cap %d", *f.cap))
+	}
+	if f.maxFalsePositiveRate != nil {
+		buf.WriteString(fmt.Sprintf(", max false positive rate %f", *f.maxFalsePositiveRate))
+	}
+	if f.cap == nil && f.maxFalsePositiveRate == nil {
+		buf.WriteString(", no constraints")
+	}
+
+	return buf.String()
+}
+
+// converts slots of quotient filter to hex string. Each slot is 8 big endian bytes.
+func (f *QuotientFilter) Hex() string {
+	bs := make([]byte, 0, 8*len(f.slots))
+	for _, slot := range f.slots {
+		bs = binary.BigEndian.AppendUint64(bs, slot)
+	}
+	return hex.EncodeToString(bs)
+}
+
+//
+// helpers
+//
+
+// calculate false positive rate of n entries keeping bits hash bits each
+func qfFalsePositiveRate(bits uint, n int) float64 {
+	// a query is a false positive if any of the n fingerprints matches its fingerprint: 1 - (1 - 2^-bits)^n
+	return -math.Expm1(float64(n) * math.Log1p(-math.Pow(2, -float64(bits))))
+}
+
+// calculate quotient and remainder of bs from the top q+r bits of its hash
+func (f *QuotientFilter) fingerprint(bs []byte) (uint64, uint64) {
+	fp := keyHash(bs) >> (64 - f.q - f.r)
+	return fp >> f.r, fp & (1<<f.r - 1)
+}
+
+// all entries as q+r bit fingerprints
+func (f *QuotientFilter) fingerprints() []uint64 {
+	fingerprints := make([]uint64, 0, f.n)
+	if f.n == 0 {
+		return fingerprints
+	}
+	// start after an empty slot so that the first region is whole
+	empty := uint64(0)
+	for !f.isEmpty(empty) {
+		empty++
+	}
+	size := uint64(len(f.slots))
+	for i := uint64(1); i <= size; {
+		s := f.wrap(empty + i)
+		if f.isEmpty(s) {
+			i++
+			continue
+		}
+		entries := f.decodeRegion(s)
+		for _, e := range entries {
+			fingerprints = append(fingerprints, e.q<<f.r|e.r)
+		}
+		i += uint64(len(entries))
+	}
+	return fingerprints
+}
+
+// replaces the table with 2^q slots holding fingerprints
+func (f *QuotientFilter) rebuild(q uint, fingerprints []uint64) {
+	bits := f.q + f.r
+	f.q = q
+	f.r = bits - q
+	f.slots = make([]uint64, 1<<q)
+	f.n = 0
+	for _, fp := range fingerprints {
+		fq, fr := fp>>f.r, fp&(1<<f.r-1)
+		if !f.contains(fq, fr) {
+			f.insert(fq, fr)
+		}
+	}
+}
+
+// inserts entry that does not exist yet
+func (f *QuotientFilter) insert(fq, fr uint64) {
+	start := f.regionStart(fq)
+	entries := f.decodeRegion(start)
+	oldLen := len(entries)
+	entries = append(entries, qfEntry{q: fq, r: fr})
+	f.encodeRegion(start, entries, oldLen)
+	f.n++
+}
+
+// removes entry. Returns false if it does not exist
+func (f *QuotientFilter) remove(fq, fr uint64) bool {
+	if !f.occupied(fq) {
+		return false
+	}
+	start := f.regionStart(fq)
+	entries := f.decodeRegion(start)
+	for i, e := range entries {
+		if e.q == fq && e.r == fr {
+			f.encodeRegion(start, append(entries[:i], entries[i+1:]...), len(entries))
+			f.n--
+			return true
+		}
+	}
+	return false
+}
+
+// checks whether entry exists
+func (f *QuotientFilter) contains(fq, fr uint64) bool {
+	if !f.occupied(fq) {
+		return false
+	}
+	// runs are sorted by remainder
+	s := f.runStart(fq)
+	for {
+		rem := f.remainder(s)
+		if rem == fr {
+			return true
+		}
+		if rem > fr {
+			return false
+		}
+		s = f.next(s)
+		if !f.continuation(s) {
+			return false
+		}
+	}
+}
+
+// finds the slot of the first entry with quotient fq, which must be occupied
+func (f *QuotientFilter) runStart(fq uint64) uint64 {
+	// walk back to the start of the cluster, the first entry that is in its canonical slot
+	b := fq
+	for f.shifted(b) {
+		b = f.prev(b)
+	}
+	// walk forward one run for every occupied quotient until the run of fq
+	s := b
+	for b != fq {
+		for {
+			s = f.next(s)
+			if !f.continuation(s) {
+				break
+			}
+		}
+		for {
+			b = f.next(b)
+			if f.occupied(b) {
+				break
+			}
+		}
+	}
+	return s
+}
+
+// finds the first slot of the region of consecutive non-empty slots that slot i is in or would join
+func (f *QuotientFilter) regionStart(i uint64) uint64 {
+	// terminates because at least one slot is always empty
+	for !f.isEmpty(f.prev(i)) {
+		i = f.prev(i)
+	}
+	return i
+}
+
+// decodes all entries of the region starting at start, sorted by quotient then remainder
+func (f *QuotientFilter) decodeRegion(start uint64) []qfEntry {
+	var entries []qfEntry
+	// quotients of occupied slots whose runs have not started yet
+	var quotients []uint64
+	var cur uint64
+	for s := start; !f.isEmpty(s); s = f.next(s) {
+		if f.occupied(s) {
+			quotients = append(quotients, s)
+		}
+		if !f.continuation(s) {
+			cur = quotients[0]
+			quotients = quotients[1:]
+		}
+		entries = append(entries, qfEntry{q: cur, r: f.remainder(s)})
+	}
+	return entries
+}
+
+// clears the oldLen slots of the region starting at start and writes entries back, each as close to its canonical slot as possible
+func (f *QuotientFilter) encodeRegion(start uint64, entries []qfEntry, oldLen int) {
+	for i := 0; i < oldLen; i++ {
+		f.slots[f.wrap(start+uint64(i))] = 0
+	}
+	// quotients relative to start so that regions can wrap around the end of the table
+	sort.Slice(entries, func(i, j int) bool {
+		qi, qj := f.wrap(entries[i].q-start), f.wrap(entries[j].q-start)
+		if qi != qj {
+			return qi < qj
+		}
+		return entries[i].r < entries[j].r
+	})
+	pos := uint64(0)
+	for i, e := range entries {
+		qRel := f.wrap(e.q - start)
+		newRun := i == 0 || e.q != entries[i-1].q
+		if newRun {
+			if qRel > pos {
+				pos = qRel
+			}
+			f.slots[e.q] |= qfOccupied
+		}
+		var meta uint64
+		if !newRun {
+			meta |= qfContinuation
+		}
+		if pos != qRel {
+			meta |= qfShifted
+		}
+		s := f.wrap(start + pos)
+		f.slots[s] = f.slots[s]&qfOccupied | e.r<<qfMetadataBits | meta
+		pos++
+	}
+}
+
+func (f *QuotientFilter) wrap(i uint64) uint64 {
+	return i & uint64(len(f.slots)-1)
+}
+
+func (f *QuotientFilter) next(i uint64) uint64 {
+	return f.wrap(i + 1)
+}
+
+func (f *QuotientFilter) prev(i uint64) uint64 {
+	return f.wrap(i - 1)
+}
+
+func (f *QuotientFilter) occupied(i uint64) bool {
+	return f.slots[i]&qfOccupied != 0
+}
+
+func (f *QuotientFilter) continuation(i uint64) bool {
+	return f.slots[i]&qfContinuation != 0
+}
+
+func (f *QuotientFilter) shifted(i uint64) bool {
+	return f.slots[i]&qfShifted != 0
+}
+
+func (f *QuotientFilter) isEmpty(i uint64) bool {
+	return f.slots[i]&(qfOccupied|qfContinuation|qfShifted) == 0
+}
+
+func (f *QuotientFilter) remainder(i uint64) uint64 {
+	return f.slots[i] >> qfMetadataBits
+}
@@ -0,0 +1,337 @@
+package bloom
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewQuotientFilter(t *testing.T) {
+	// test quotient bits out of range
+	_, err := NewQuotientFilter(0, 8)
+	assert.EqualError(t, err, "quotient bits must be between 1 and 32")
+	_, err = NewQuotientFilter(33, 8)
+	assert.EqualError(t, err, "quotient bits must be between 1 and 32")
+	// test remainder bits out of range
+	_, err = NewQuotientFilter(8, 0)
+	assert.EqualError(t, err, "remainder bits must be between 1 and 61")
+	_, err = NewQuotientFilter(8, 62)
+	assert.EqualError(t, err, "remainder bits must be between 1 and 61")
+	// test too many hash bits
+	_, err = NewQuotientFilter(8, 57)
+	assert.EqualError(t, err, "quotient and remainder bits cannot be more than 64")
+}
+
+func TestNewQuotientFilterAlloc(t *testing.T) {
+	// test zero capacity
+	_, err := NewQuotientFilterAlloc(0, .01)
+	assert.EqualError(t, err, "capacity cannot be less than 1")
+	// test zero false positive rate
+	_, err = NewQuotientFilterAlloc(100, 0)
+	assert.EqualError(t, err, "false positive rate must be between 0 and 1")
+
+	type allocTest struct {
+		cap       int
+		acc       float64
+		expectedQ uint
+		expectedR uint
+	}
+
+	tests := []allocTest{
+		{
+			cap:       1000,
+			acc:       .01,
+			expectedQ: 11, // 1000/0.75 = 1334 slots -> 2048
+			expectedR: 6,  // log2(1000/0.01) = 16.6 -> 17 hash bits
+		},
+		{
+			cap:       100000,
+			acc:       .001,
+			expectedQ: 18,
+			expectedR: 9,
+		},
+	}
+
+	for _, test := range tests {
+		f, err := NewQuotientFilterAlloc(test.cap, test.acc)
+		assert.Nil(t, err)
+		assert.Equal(t, test.expectedQ, f.q)
+		assert.Equal(t, test.expectedR, f.r)
+
+		// filling to capacity stays within the false positive rate.
+		// some entries may already exist because of false positives, so insert until the capacity constraint is hit
+		i := 0
+		for ; err == nil; i++ {
+			_, err = f.PutStr(strconv.Itoa(i))
+		}
+		assert.IsType(t, &CapacityError{}, err)
+		assert.GreaterOrEqual(t, i, test.cap)
+		assert.LessOrEqual(t, f.Accuracy(), test.acc)
+	}
+}
+
+// checks that f holds exactly the expected fingerprints
+func assertQuotientFilterHolds(t *testing.T, f *QuotientFilter, expected map[uint64]bool) {
+	assert.Equal(t, len(expected), f.n)
+	fingerprints := f.fingerprints()
+	assert.Equal(t, len(expected), len(fingerprints))
+	for _, fp := range fingerprints {
+		assert.True(t, expected[fp])
+	}
+	for fp := range expected {
+		assert.True(t, f.contains(fp>>f.r, fp&(1<<f.r-1)))
+	}
+}
+
+func TestQuotientFilterRandomOperations(t *testing.T) {
+	// a tiny table with short remainders so that clusters are long, wrap around and fingerprints collide
+	f, err := NewQuotientFilter(6, 4)
+	assert.Nil(t, err)
+	rng := rand.New(rand.NewSource(1))
+	expected := make(map[uint64]bool)
+
+	for i := 0; i < 20000; i++ {
+		fp := uint64(rng.Intn(1 << 10))
+		fq, fr := fp>>4, fp&15
+		if rng.Intn(2) == 0 && f.n < 60 {
+			if !f.contains(fq, fr) {
+				f.insert(fq, fr)
+			}
+			expected[fp] = true
+		} else {
+			assert.Equal(t, expected[fp], f.remove(fq, fr))
+			delete(expected, fp)
+		}
+		assert.Equal(t, expected[fp], f.contains(fq, fr))
+		if i%500 == 0 {
+			assertQuotientFilterHolds(t, f, expected)
+		}
+	}
+	assertQuotientFilterHolds(t, f, expected)
+}
+
+func TestQuotientFilterPutDelete(t *testing.T) {
+	f, err := NewQuotientFilter(10, 10)
+	assert.Nil(t, err)
+
+	for i := 0; i < 500; i++ {
+		_, err := f.PutStr(strconv.Itoa(i))
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, 500, f.n)
+
+	// make sure n stays the same after same insertion
+	f.PutStr("0")
+	assert.Equal(t, 500, f.n)
+
+	// no false negatives
+	for i := 0; i < 500; i++ {
+		exists, _ := f.ExistsStr(strconv.Itoa(i))
+		assert.True(t, exists)
+	}
+
+	// delete every other entry
+	for i := 0; i < 500; i += 2 {
+		assert.True(t, f.DeleteStr(strconv.Itoa(i)))
+	}
+	assert.Equal(t, 250, f.n)
+	for i := 1; i < 500; i += 2 {
+		exists, _ := f.ExistsStr(strconv.Itoa(i))
+		assert.True(t, exists)
+	}
+	falsePositives := 0
+	for i := 0; i < 500; i += 2 {
+		if exists, _ := f.ExistsStr(strconv.Itoa(i)); exists {
+			falsePositives++
+		}
+	}
+	assert.Less(t, falsePositives, 5)
+
+	// deleting a missing entry
+	assert.False(t, f.DeleteStr("missing"))
+	assert.Equal(t, 250, f.n)
+}
+
+func TestQuotientFilterFull(t *testing.T) {
+	f, err := NewQuotientFilter(3, 20)
+	assert.Nil(t, err)
+	for i := 0; i < 7; i++ {
+		_, err := f.PutStr(strconv.Itoa(i))
+		assert.Nil(t, err)
+	}
+	_, err = f.PutStr("fail")
+	assert.EqualError(t, err, "failed to add entry: quotient filter is full")
+	for i := 0; i < 7; i++ {
+		exists, _ := f.ExistsStr(strconv.Itoa(i))
+		assert.True(t, exists)
+	}
+}
+
+func TestQuotientFilterResize(t *testing.T) {
+	f, err := NewQuotientFilter(8, 12)
+	assert.Nil(t, err)
+	for i := 0; i < 200; i++ {
+		f.PutStr(strconv.Itoa(i))
+	}
+	before := f.Accuracy()
+
+	err = f.Resize()
+	assert.Nil(t, err)
+	assert.Equal(t, uint(9), f.q)
+	assert.Equal(t, uint(11), f.r)
+	assert.Equal(t, 512, len(f.slots))
+	assert.Equal(t, 200, f.n)
+	assert.Equal(t, before, f.Accuracy())
+	for i := 0; i < 200; i++ {
+		exists, _ := f.ExistsStr(strconv.Itoa(i))
+		assert.True(t, exists)
+	}
+
+	// room for more entries after resizing
+	for i := 200; i < 400; i++ {
+		_, err := f.PutStr(strconv.Itoa(i))
+		assert.Nil(t, err)
+	}
+
+	// test no remainder bits left
+	small, err := NewQuotientFilter(4, 1)
+	assert.Nil(t, err)
+	err = small.Resize()
+	assert.EqualError(t, err, "cannot resize quotient filter: no remainder bits left")
+}
+
+func TestQuotientFilterMerge(t *testing.T) {
+	a, err := NewQuotientFilter(8, 16)
+	assert.Nil(t, err)
+	b, err := NewQuotientFilter(9, 15)
+	assert.Nil(t, err)
+	for i := 0; i < 200; i++ {
+		a.PutStr("a" + strconv.Itoa(i))
+		b.PutStr("b" + strconv.Itoa(i))
+	}
+	// shared entries are only kept once
+	a.PutStr("shared")
+	b.PutStr("shared")
+
+	err = a.Merge(b)
+	assert.Nil(t, err)
+	assert.Equal(t, 401, a.n)
+	assert.Equal(t, uint(9), a.q)
+	for i := 0; i < 200; i++ {
+		exists, _ := a.ExistsStr("a" + strconv.Itoa(i))
+		assert.True(t, exists)
+		exists, _ = a.ExistsStr("b" + strconv.Itoa(i))
+		assert.True(t, exists)
+	}
+
+	// test different hash bits
+	c, err := NewQuotientFilter(8, 8)
+	assert.Nil(t, err)
+	err = a.Merge(c)
+	assert.EqualError(t, err, "cannot merge quotient filters with different numbers of hash bits")
+}
+
+func TestQuotientFilterResetCloneEqual(t *testing.T) {
+	f, err := NewQuotientFilterAlloc(100, .01)
+	assert.Nil(t, err)
+	for i := 0; i < 50; i++ {
+		_, err = f.PutStr(strconv.Itoa(i))
+		assert.Nil(t, err)
+	}
+
+	// clone is equal but does not share slots or constraints
+	c := f.Clone()
+	assert.True(t, f.Equal(c))
+	assert.Equal(t, f, c)
+	assert.NotSame(t, f.cap, c.cap)
+	assert.NotSame(t, f.maxFalsePositiveRate, c.maxFalsePositiveRate)
+	assert.True(t, c.DeleteStr("0"))
+	assert.False(t, f.Equal(c))
+	exists, _ := f.ExistsStr("0")
+	assert.True(t, exists)
+
+	// reset removes entries but keeps size and constraints
+	c.Reset()
+	assert.Equal(t, 0, c.n)
+	assert.Equal(t, make([]uint64, len(f.slots)), c.slots)
+	assert.Equal(t, 100, *c.cap)
+	assert.Equal(t, .01, *c.maxFalsePositiveRate)
+	for i := 0; i < 50; i++ {
+		_, err = c.PutStr(strconv.Itoa(i))
+		assert.Nil(t, err)
+	}
+	assert.True(t, f.Equal(c))
+
+	// different size
+	assert.Nil(t, c.Resize())
+	assert.False(t, f.Equal(c))
+}
+
+func TestQuotientFilterConstraints(t *testing.T) {
+	f, err := NewQuotientFilter(10, 10)
+	assert.Nil(t, err)
+	err = f.AddCapacityConstraint(0)
+	assert.EqualError(t, err, "capacity cannot be less than 1")
+	err = f.AddAccuracyConstraint(1)
+	assert.EqualError(t, err, "false positive rate must be between 0 and 1")
+
+	// test capacity and accuracy incompatibility
+	err = f.AddCapacityConstraint(1000)
+	assert.Nil(t, err)
+	err = f.AddAccuracyConstraint(.0001)
+	assert.EqualError(t, err, "false positive rate will be higher at full capacity than the maxFalsePositiveRate provided")
+
+	f, err = NewQuotientFilter(10, 10)
+	assert.Nil(t, err)
+	err = f.AddAccuracyConstraint(.0001)
+	assert.Nil(t, err)
+	err = f.AddCapacityConstraint(1000)
+	assert.EqualError(t, err, "false positive rate will be higher at full capacity than the maxFalsePositiveRate provided")
+
+	// the accuracy constraint rejects entries
+	for i := 0; i < 104; i++ {
+		_, err := f.PutStr(strconv.Itoa(i))
+		assert.Nil(t, err)
+	}
+	_, err = f.PutStr("fail")
+	assert.IsType(t, &AccuracyError{}, err)
+}
+
+func TestQuotientFilterString(t *testing.T) {
+	f, err := NewQuotientFilterAlloc(10, .01)
+	assert.Nil(t, err)
+	f.PutStr("a")
+	assert.Equal(t, "16-slot quotient filter with 6-bit remainders: 1 unique entries, max cap 10, max false positive rate 0.010000", f.String())
+	assert.Equal(t, 16*16, len(f.Hex()))
+}
+
+// QuotientFilter and BigBloom can be used interchangeably through MembershipFilter
+func TestQuotientFilterMembershipFilter(t *testing.T) {
+	qf, err := NewQuotientFilterAlloc(100, .01)
+	assert.Nil(t, err)
+	testMembershipFilter[*QuotientFilter](t, qf)
+	b, err := NewBigBloomAlloc(100, .01)
+	assert.Nil(t, err)
+	testMembershipFilter[*BigBloom](t, b)
+}
+
+func testMembershipFilter[F MembershipFilter[F]](t *testing.T, f F) {
+	_, err := f.PutStr("a")
+	assert.Nil(t, err)
+	_, err = f.PutBytes([]byte("b"))
+	assert.Nil(t, err)
+	exists, _ := f.ExistsStr("a")
+	assert.True(t, exists)
+	exists, _ = f.ExistsBytes([]byte("b"))
+	assert.True(t, exists)
+	assert.Greater(t, f.Accuracy(), float64(0))
+
+	clone := f.Clone()
+	assert.True(t, f.Equal(clone))
+	f.Reset()
+	assert.False(t, f.Equal(clone))
+	exists, _ = f.ExistsStr("a")
+	assert.False(t, exists)
+}
@@ -0,0 +1,200 @@
+package rappor
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+
+	"github.com/nettijoe96/bloom"
+)
+
+// Aggregator is the server side of RAPPOR. It sums the bits of reports per cohort and decodes how often candidate values occur.
+type Aggregator struct {
+	// encoding parameters, the same as the clients'
+	params Params
+
+	// number of reports with each bit set, per cohort
+	counts [][NUM_BITS]int
+
+	// number of reports, per cohort
+	reports []int
+}
+
+// Estimate is the decoded frequency of a candidate value
+type Estimate struct {
+	// candidate value
+	Value string
+
+	// estimated share of reports with this value
+	Proportion float64
+
+	// estimated number of reports with this value
+	Count float64
+}
+
+// max number of coordinate descent sweeps when decoding
+const maxDecodeSweeps = 10000
+
+//
+// Constructors
+//
+
+// Constructs aggregator for reports encoded with params
+func NewAggregator(params Params) (*Aggregator, error) {
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
+	if params.F == 1 {
+		// reports would be pure noise
+		return nil, parameterError("F", params.F, "f must be less than 1 to decode reports")
+	}
+	return &Aggregator{
+		params:  params,
+		counts:  make([][NUM_BITS]int, params.NumCohorts),
+		reports: make([]int, params.NumCohorts),
+	}, nil
+}
+
+//
+// Methods
+//
+
+// Adds report to the sums
+func (a *Aggregator) Add(r *Report) error {
+	if r.Cohort < 0 || r.Cohort >= a.params.NumCohorts {
+		return parameterError("cohort", r.Cohort, "cohort must be between 0 and the number of cohorts")
+	}
+	bs := r.Filter.Bytes()
+	for i := 0; i < NUM_BITS; i++ {
+		if getBit(bs, i) {
+			a.counts[r.Cohort][i]++
+		}
+	}
+	a.reports[r.Cohort]++
+	return nil
+}
+
+// Number of reports added
+func (a *Aggregator) N() int {
+	n := 0
+	for _, reports := range a.reports {
+		n += reports
+	}
+	return n
+}
+
+// Estimates how often each candidate value occurs. Estimates are returned in the order of candidates.
+// Values reported by clients that are missing from candidates are not estimated, so proportions can add up to less than 1.
+func (a *Aggregator) Decode(candidates []string) ([]Estimate, error) {
+	n := a.N()
+	if n == 0 {
+		return nil, errors.New("cannot decode without reports")
+	}
+	if len(candidates) == 0 {
+		return nil, errors.New("cannot decode without candidates")
+	}
+
+	// bits set by each candidate in each cohort
+	candidateBits := make([][][bloom.BLOOM_LEN]byte, a.params.NumCohorts)
+	for j := range candidateBits {
+		candidateBits[j] = make([][bloom.BLOOM_LEN]byte, len(candidates))
+		for v, candidate := range candidates {
+			bs, err := a.params.bloomBits(j, []byte(candidate))
+			if err != nil {
+				return nil, err
+			}
+			candidateBits[j][v] = bs
+		}
+	}
+
+	// least squares of the estimated true bit rates y against the candidates' bits, weighted by cohort size:
+	// minimize sum over cohorts j of N_j * |y_j - X_j * proportions|^2 with proportions >= 0.
+	// gram is the weighted X^T X and target the weighted X^T y
+	gram := make([][]float64, len(candidates))
+	target := make([]float64, len(candidates))
+	for u := range candidates {
+		gram[u] = make([]float64, len(candidates))
+	}
+	for j := range candidateBits {
+		nj := float64(a.reports[j])
+		if nj == 0 {
+			continue
+		}
+		y := a.trueBitRates(j)
+		for u := range candidates {
+			for v := u; v < len(candidates); v++ {
+				shared := nj * float64(sharedBits(candidateBits[j][u], candidateBits[j][v]))
+				gram[u][v] += shared
+				if u != v {
+					gram[v][u] += shared
+				}
+			}
+			for i := 0; i < NUM_BITS; i++ {
+				if getBit(candidateBits[j][u], i) {
+					target[u] += nj * y[i]
+				}
+			}
+		}
+	}
+
+	proportions := nonNegativeLeastSquares(gram, target)
+	estimates := make([]Estimate, len(candidates))
+	for v, candidate := range candidates {
+		estimates[v] = Estimate{
+			Value:      candidate,
+			Proportion: proportions[v],
+			Count:      proportions[v] * float64(n),
+		}
+	}
+	return estimates, nil
+}
+
+//
+// helpers
+//
+
+// estimate the share of reports in cohort j whose bloom filter had each bit set before randomization.
+// A report has a bit set with probability p + f/2*(q-p) + (1-f)*(q-p)*t where t is the true rate
+func (a *Aggregator) trueBitRates(j int) [NUM_BITS]float64 {
+	f, p, q := a.params.F, a.params.P, a.params.Q
+	nj := float64(a.reports[j])
+	var y [NUM_BITS]float64
+	for i := range y {
+		observed := float64(a.counts[j][i]) / nj
+		y[i] = (observed - p - f/2*(q-p)) / ((1 - f) * (q - p))
+	}
+	return y
+}
+
+// count bits set in both a and b
+func sharedBits(a, b [bloom.BLOOM_LEN]byte) int {
+	shared := 0
+	for i := range a {
+		shared += bits.OnesCount8(a[i] & b[i])
+	}
+	return shared
+}
+
+// solve min x^T gram x - 2 target^T x with x >= 0 by projected coordinate descent
+func nonNegativeLeastSquares(gram [][]float64, target []float64) []float64 {
+	x := make([]float64, len(target))
+	for sweep := 0; sweep < maxDecodeSweeps; sweep++ {
+		maxChange := 0.0
+		for v := range x {
+			if gram[v][v] == 0 {
+				continue
+			}
+			grad := -target[v]
+			for u := range x {
+				grad += gram[v][u] * x[u]
+			}
+			next := math.Max(0, x[v]-grad/gram[v][v])
+			maxChange = math.Max(maxChange, math.Abs(next-x[v]))
+			x[v] = next
+		}
+		if maxChange < 1e-12 {
+			break
+		}
+	}
+	return x
+}
@@ -0,0 +1,108 @@
+package rappor
+
+import (
+	"errors"
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/nettijoe96/bloom"
+	"github.com/stretchr/testify/assert"
+)
+
+// simulates one report from each of n clients, client i having value values[i] in a random cohort
+func aggregateReports(t *testing.T, params Params, values []string) *Aggregator {
+	a, err := NewAggregator(params)
+	assert.Nil(t, err)
+	for i, value := range values {
+		e, err := NewEncoder(params, i%params.NumCohorts, int64(i))
+		assert.Nil(t, err)
+		r, err := e.EncodeStr(value)
+		assert.Nil(t, err)
+		assert.Nil(t, a.Add(r))
+	}
+	return a
+}
+
+func TestNewAggregator(t *testing.T) {
+	_, err := NewAggregator(Params{K: 0, NumCohorts: 1, F: .5, P: .5, Q: .75})
+	assert.EqualError(t, err, "k cannot be less than 1")
+	_, err = NewAggregator(Params{K: 2, NumCohorts: 1, F: 1, P: .5, Q: .75})
+	assert.EqualError(t, err, "f must be less than 1 to decode reports")
+
+	a, err := NewAggregator(testParams)
+	assert.Nil(t, err)
+	_, err = a.Decode([]string{"a"})
+	assert.EqualError(t, err, "cannot decode without reports")
+
+	e, err := NewEncoder(Params{K: 2, NumCohorts: 16, F: .5, P: .5, Q: .75}, 10, 1)
+	assert.Nil(t, err)
+	r, err := e.EncodeStr("a")
+	assert.Nil(t, err)
+	err = a.Add(r)
+	assert.EqualError(t, err, "cohort must be between 0 and the number of cohorts")
+	assert.True(t, errors.Is(err, bloom.ErrInvalidParameter))
+	r.Cohort = 0
+	assert.Nil(t, a.Add(r))
+	assert.Equal(t, 1, a.N())
+	_, err = a.Decode(nil)
+	assert.EqualError(t, err, "cannot decode without candidates")
+}
+
+func TestDecodeKnownDistribution(t *testing.T) {
+	// 10 values with proportions 0.3, 0.2, 0.15, 0.1, 0.1, 0.05, 0.05, 0.05, 0 and 0
+	proportions := []float64{.3, .2, .15, .1, .1, .05, .05, .05, 0, 0}
+	candidates := make([]string, len(proportions))
+	for i := range candidates {
+		candidates[i] = "value" + strconv.Itoa(i)
+	}
+
+	n := 100000
+	values := make([]string, 0, n)
+	for i, proportion := range proportions {
+		for j := 0; j < int(proportion*float64(n)); j++ {
+			values = append(values, candidates[i])
+		}
+	}
+	rand.New(rand.NewSource(1)).Shuffle(len(values), func(i, j int) {
+		values[i], values[j] = values[j], values[i]
+	})
+
+	a := aggregateReports(t, testParams, values)
+	assert.Equal(t, n, a.N())
+	estimates, err := a.Decode(candidates)
+	assert.Nil(t, err)
+	for i, estimate := range estimates {
+		assert.Equal(t, candidates[i], estimate.Value)
+		assert.InDelta(t, proportions[i], estimate.Proportion, .02)
+		assert.InDelta(t, proportions[i]*float64(n), estimate.Count, .02*float64(n))
+	}
+}
+
+func TestDecodeWithoutNoise(t *testing.T) {
+	// without randomization the counts are recovered almost exactly
+	params := Params{K: 2, NumCohorts: 4, F: 0, P: 0, Q: 1}
+	values := make([]string, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		values = append(values, "value"+strconv.Itoa(i%4/3))
+	}
+	a := aggregateReports(t, params, values)
+	estimates, err := a.Decode([]string{"value0", "value1", "missing"})
+	assert.Nil(t, err)
+	assert.InDelta(t, 750, estimates[0].Count, 1)
+	assert.InDelta(t, 250, estimates[1].Count, 1)
+	assert.InDelta(t, 0, estimates[2].Count, 1)
+}
+
+//
+// Benchmarks
+//
+
+func BenchmarkEncode(b *testing.B) {
+	e, err := NewEncoder(testParams, 0, 1)
+	assert.Nil(b, err)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		e.EncodeStr("value")
+	}
+}
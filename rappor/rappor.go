@@ -0,0 +1,202 @@
+// Package rappor collects differentially private reports of string values with RAPPOR (Erlingsson, Pihur & Korolova, 2014).
+// Clients encode their value into a 512-bit bloom.Bloom, randomize it once with the permanent randomized response,
+// and randomize every report again with the instantaneous randomized response.
+// A single report reveals little about its value, but a server aggregating many reports can estimate how often candidate values occur.
+package rappor
+
+import (
+	"math/rand"
+
+	"github.com/nettijoe96/bloom"
+)
+
+// number of bits in every report
+const NUM_BITS = 8 * bloom.BLOOM_LEN
+
+// Params are the encoding parameters shared by clients and the server.
+type Params struct {
+	// number of hash functions of the bloom filter
+	K int
+
+	// number of cohorts. Each cohort hashes values differently, which helps the server tell candidates apart
+	NumCohorts int
+
+	// probability that the permanent randomized response replaces a bit with a random bit
+	F float64
+
+	// probability that the instantaneous randomized response reports 1 for a 0 bit
+	P float64
+
+	// probability that the instantaneous randomized response reports 1 for a 1 bit
+	Q float64
+}
+
+// Report is a single randomized report sent by a client
+type Report struct {
+	// cohort of the client
+	Cohort int
+
+	// randomized bloom filter of the value
+	Filter *bloom.Bloom
+}
+
+// Encoder is the client side of RAPPOR. It remembers the permanent randomized response of every value it encodes,
+// so that repeated reports of a value do not average out the permanent randomness.
+type Encoder struct {
+	// encoding parameters
+	params Params
+
+	// cohort of this client
+	cohort int
+
+	// permanent randomized response of each value encoded so far
+	permanent map[string][bloom.BLOOM_LEN]byte
+
+	// source of the randomized responses
+	rng *rand.Rand
+}
+
+//
+// Constructors
+//
+
+// Constructs encoder for a client in cohort.
+// seed seeds the randomized responses; clients should use a secret, random seed.
+func NewEncoder(params Params, cohort int, seed int64) (*Encoder, error) {
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
+	if cohort < 0 || cohort >= params.NumCohorts {
+		return nil, parameterError("cohort", cohort, "cohort must be between 0 and the number of cohorts")
+	}
+	return &Encoder{
+		params:    params,
+		cohort:    cohort,
+		permanent: make(map[string][bloom.BLOOM_LEN]byte),
+		rng:       rand.New(rand.NewSource(seed)),
+	}, nil
+}
+
+//
+// Methods
+//
+
+// Encodes string value into a randomized report
+func (e *Encoder) EncodeStr(s string) (*Report, error) {
+	bs := []byte(s)
+	return e.EncodeBytes(bs)
+}
+
+// Encodes bytes value into a randomized report
+func (e *Encoder) EncodeBytes(bs []byte) (*Report, error) {
+	prr, ok := e.permanent[string(bs)]
+	if !ok {
+		bits, err := e.params.bloomBits(e.cohort, bs)
+		if err != nil {
+			return nil, err
+		}
+		prr = e.permanentResponse(bits)
+		e.permanent[string(bs)] = prr
+	}
+	filter, err := bloom.NewBloomFromBytes(e.instantaneousResponse(prr), e.params.K)
+	if err != nil {
+		return nil, err
+	}
+	return &Report{
+		Cohort: e.cohort,
+		Filter: filter,
+	}, nil
+}
+
+// Cohort of the client
+func (e *Encoder) Cohort() int {
+	return e.cohort
+}
+
+//
+// helpers
+//
+
+// checks that params describe a valid encoding
+func (params Params) validate() error {
+	if params.K < 1 {
+		return parameterError("K", params.K, "k cannot be less than 1")
+	}
+	if params.NumCohorts < 1 || params.NumCohorts > 256 {
+		return parameterError("NumCohorts", params.NumCohorts, "number of cohorts must be between 1 and 256")
+	}
+	if params.F < 0 || params.F > 1 {
+		return parameterError("F", params.F, "f must be between 0 and 1")
+	}
+	if params.P < 0 || params.P > 1 || params.Q < 0 || params.Q > 1 {
+		return parameterError("P, Q", []float64{params.P, params.Q}, "p and q must be between 0 and 1")
+	}
+	if params.Q <= params.P {
+		return parameterError("Q", params.Q, "q must be greater than p")
+	}
+	return nil
+}
+
+// calculate bloom filter bytes of value bs for cohort. The cohort salts the value so every cohort sets different bits
+func (params Params) bloomBits(cohort int, bs []byte) ([bloom.BLOOM_LEN]byte, error) {
+	b, err := bloom.NewBloomFromK(params.K)
+	if err != nil {
+		return [bloom.BLOOM_LEN]byte{}, err
+	}
+	salted := make([]byte, 1+len(bs))
+	salted[0] = byte(cohort)
+	copy(salted[1:], bs)
+	b.PutBytes(salted)
+	return b.Bytes(), nil
+}
+
+// replaces each bit with 1 with probability f/2, with 0 with probability f/2 and keeps it otherwise
+func (e *Encoder) permanentResponse(bits [bloom.BLOOM_LEN]byte) [bloom.BLOOM_LEN]byte {
+	var prr [bloom.BLOOM_LEN]byte
+	for i := 0; i < NUM_BITS; i++ {
+		bit := getBit(bits, i)
+		if r := e.rng.Float64(); r < e.params.F/2 {
+			bit = true
+		} else if r < e.params.F {
+			bit = false
+		}
+		if bit {
+			setBit(&prr, i)
+		}
+	}
+	return prr
+}
+
+// reports each 1 bit as 1 with probability q and each 0 bit as 1 with probability p
+func (e *Encoder) instantaneousResponse(prr [bloom.BLOOM_LEN]byte) [bloom.BLOOM_LEN]byte {
+	var irr [bloom.BLOOM_LEN]byte
+	for i := 0; i < NUM_BITS; i++ {
+		prob := e.params.P
+		if getBit(prr, i) {
+			prob = e.params.Q
+		}
+		if e.rng.Float64() < prob {
+			setBit(&irr, i)
+		}
+	}
+	return irr
+}
+
+// check bit i, numbered the same way as bloom.Bloom numbers its bits
+func getBit(bits [bloom.BLOOM_LEN]byte, i int) bool {
+	return bits[i/8]&(1<<(i%8)) != 0
+}
+
+// set bit i to 1
+func setBit(bits *[bloom.BLOOM_LEN]byte, i int) {
+	bits[i/8] |= 1 << (i % 8)
+}
+
+// constructs bloom.ParameterError, which matches bloom.ErrInvalidParameter
+func parameterError(param string, value interface{}, reason string) error {
+	return &bloom.ParameterError{
+		Param:  param,
+		Value:  value,
+		Reason: reason,
+	}
+}
@@ -0,0 +1,96 @@
+package rappor
+
+import (
+	"testing"
+
+	"github.com/nettijoe96/bloom"
+	"github.com/stretchr/testify/assert"
+)
+
+var testParams = Params{
+	K:          2,
+	NumCohorts: 8,
+	F:          0.5,
+	P:          0.5,
+	Q:          0.75,
+}
+
+func TestNewEncoder(t *testing.T) {
+	type paramsTest struct {
+		params   Params
+		expected string
+	}
+
+	tests := []paramsTest{
+		{params: Params{K: 0, NumCohorts: 1, F: .5, P: .5, Q: .75}, expected: "k cannot be less than 1"},
+		{params: Params{K: 2, NumCohorts: 0, F: .5, P: .5, Q: .75}, expected: "number of cohorts must be between 1 and 256"},
+		{params: Params{K: 2, NumCohorts: 257, F: .5, P: .5, Q: .75}, expected: "number of cohorts must be between 1 and 256"},
+		{params: Params{K: 2, NumCohorts: 1, F: 1.5, P: .5, Q: .75}, expected: "f must be between 0 and 1"},
+		{params: Params{K: 2, NumCohorts: 1, F: .5, P: -.5, Q: .75}, expected: "p and q must be between 0 and 1"},
+		{params: Params{K: 2, NumCohorts: 1, F: .5, P: .75, Q: .5}, expected: "q must be greater than p"},
+	}
+
+	for _, test := range tests {
+		_, err := NewEncoder(test.params, 0, 1)
+		assert.EqualError(t, err, test.expected)
+	}
+
+	// test cohort out of range
+	_, err := NewEncoder(testParams, 8, 1)
+	assert.EqualError(t, err, "cohort must be between 0 and the number of cohorts")
+	e, err := NewEncoder(testParams, 7, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 7, e.Cohort())
+}
+
+func TestEncodeWithoutNoise(t *testing.T) {
+	// no randomization reports the bloom filter of the salted value
+	e, err := NewEncoder(Params{K: 3, NumCohorts: 4, F: 0, P: 0, Q: 1}, 2, 1)
+	assert.Nil(t, err)
+	r, err := e.EncodeStr("hello")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, r.Cohort)
+
+	expected, err := bloom.NewBloomFromK(3)
+	assert.Nil(t, err)
+	expected.PutBytes(append([]byte{2}, "hello"...))
+	assert.Equal(t, expected.Hex(), r.Filter.Hex())
+	exists, _ := r.Filter.ExistsBytes(append([]byte{2}, "hello"...))
+	assert.True(t, exists)
+}
+
+func TestEncodePermanentResponse(t *testing.T) {
+	// with p=0 and q=1 reports are the permanent randomized response, which is the same every time
+	e, err := NewEncoder(Params{K: 2, NumCohorts: 1, F: .5, P: 0, Q: 1}, 0, 1)
+	assert.Nil(t, err)
+	first, err := e.EncodeStr("hello")
+	assert.Nil(t, err)
+	for i := 0; i < 10; i++ {
+		r, err := e.EncodeStr("hello")
+		assert.Nil(t, err)
+		assert.Equal(t, first.Filter.Hex(), r.Filter.Hex())
+	}
+	// about half the bits are randomized to 1 or 0, so a quarter of them are set
+	ones := 0
+	bs := first.Filter.Bytes()
+	for i := 0; i < NUM_BITS; i++ {
+		if getBit(bs, i) {
+			ones++
+		}
+	}
+	assert.InDelta(t, NUM_BITS/4, ones, 40)
+}
+
+func TestEncodeSeeded(t *testing.T) {
+	a, err := NewEncoder(testParams, 0, 42)
+	assert.Nil(t, err)
+	b, err := NewEncoder(testParams, 0, 42)
+	assert.Nil(t, err)
+	c, err := NewEncoder(testParams, 0, 43)
+	assert.Nil(t, err)
+	ra, _ := a.EncodeStr("hello")
+	rb, _ := b.EncodeStr("hello")
+	rc, _ := c.EncodeStr("hello")
+	assert.Equal(t, ra.Filter.Hex(), rb.Filter.Hex())
+	assert.NotEqual(t, ra.Filter.Hex(), rc.Filter.Hex())
+}
@@ -0,0 +1,236 @@
+package bloom
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Clock returns the current time. It is injectable so that time based rotation can be tested deterministically.
+type Clock func() time.Time
+
+// RotatingBloom is a time-decaying bloom filter made of several BigBloom generations.
+// Entries are inserted into the newest generation and checked against every live generation.
+// The oldest generation is dropped when the rotation interval elapses or when the newest generation is full,
+// so an entry is remembered for at least (generations-1) * interval.
+type RotatingBloom struct {
+	// live generations, newest first
+	gens []*BigBloom
+
+	// maximum number of unique entries per generation
+	cap int
+
+	// maximum false positive rate per generation
+	maxFalsePositiveRate float64
+
+	// optional, time between rotations. 0 means only rotate when the newest generation is full
+	interval time.Duration
+
+	// source of the current time
+	clock Clock
+
+	// time of the last rotation
+	rotatedAt time.Time
+}
+
+//
+// Constructors
+//
+
+// Constructs rotating bloom filter with the given number of generations, each sized for cap entries at maxFalsePositiveRate.
+// A generation is rotated out every interval. An interval of 0 disables time based rotation.
+func NewRotatingBloom(generations, cap int, maxFalsePositiveRate float64, interval time.Duration) (*RotatingBloom, error) {
+	return NewRotatingBloomWithClock(generations, cap, maxFalsePositiveRate, interval, time.Now)
+}
+
+// Constructs rotating bloom filter that reads the time from clock
+func NewRotatingBloomWithClock(generations, cap int, maxFalsePositiveRate float64, interval time.Duration, clock Clock) (*RotatingBloom, error) {
+	if generations < 1 {
+		return nil, newParameterError("generations", generations, "generations cannot be less than 1")
+	}
+	if interval < 0 {
+		return nil, newParameterError("interval", interval, "interval cannot be negative")
+	}
+	if clock == nil {
+		return nil, newParameterError("clock", nil, "clock cannot be nil")
+	}
+	gens := make([]*BigBloom, generations)
+	for i := range gens {
+		b, err := NewBigBloomAlloc(cap, maxFalsePositiveRate)
+		if err != nil {
+			return nil, err
+		}
+		gens[i] = b
+	}
+	return &RotatingBloom{
+		gens:                 gens,
+		cap:                  cap,
+		maxFalsePositiveRate: maxFalsePositiveRate,
+		interval:             interval,
+		clock:                clock,
+		rotatedAt:            clock(),
+	}, nil
+}
+
+//
+// Methods
+//
+
+// Inserts string element into the newest generation
+func (r *RotatingBloom) PutStr(s string) (*RotatingBloom, error) {
+	bs := []byte(s)
+	return r.PutBytes(bs)
+}
+
+// Inserts bytes element into the newest generation. If the newest generation is full, the filter is rotated first.
+// Entries that are already in an older generation are inserted again so that they stay live for longer.
+func (r *RotatingBloom) PutBytes(bs []byte) (*RotatingBloom, error) {
+	r.expire()
+	_, err := r.gens[0].PutBytes(bs)
+	if err == nil {
+		return r, nil
+	}
+	if !errors.Is(err, ErrCapacity) && !errors.Is(err, ErrAccuracy) {
+		return r, err
+	}
+	r.Rotate()
+	_, err = r.gens[0].PutBytes(bs)
+	return r, err
+}
+
+// Checks for existance of a string in any live generation. Returns boolean and false positive rate.
+func (r *RotatingBloom) ExistsStr(s string) (bool, float64) {
+	bs := []byte(s)
+	return r.ExistsBytes(bs)
+}
+
+// Checks for existance of bytes element in any live generation. Returns boolean and false positive rate.
+func (r *RotatingBloom) ExistsBytes(bs []byte) (bool, float64) {
+	r.expire()
+	for _, gen := range r.gens {
+		if exists, _ := gen.ExistsBytes(bs); exists {
+			return true, r.Accuracy()
+		}
+	}
+	return false, 1
+}
+
+// Get false positive rate across all live generations
+func (r *RotatingBloom) Accuracy() float64 {
+	// a query is a false positive if any of the generations gives a false positive
+	trueNegative := float64(1)
+	empty := true
+	for _, gen := range r.gens {
+		if gen.n == 0 {
+			continue
+		}
+		empty = false
+		trueNegative *= 1 - gen.Accuracy()
+	}
+	if empty {
+		return 1
+	}
+	return 1 - trueNegative
+}
+
+// Drops the oldest generation and starts a new empty one.
+// The rotation interval restarts, so the new generation is live for a full interval before the next time based rotation.
+func (r *RotatingBloom) Rotate() {
+	r.rotate()
+	r.rotatedAt = r.clock()
+}
+
+// Number of unique entries across all live generations. Entries that were inserted into more than one generation are counted more than once.
+func (r *RotatingBloom) N() int {
+	n := 0
+	for _, gen := range r.gens {
+		n += gen.n
+	}
+	return n
+}
+
+// Removes all entries from every generation and restarts the rotation interval
+func (r *RotatingBloom) Reset() {
+	for _, gen := range r.gens {
+		gen.Reset()
+	}
+	r.rotatedAt = r.clock()
+}
+
+// Copies the filter and its generations. The copy reads the same clock and rotates at the same times
+func (r *RotatingBloom) Clone() *RotatingBloom {
+	gens := make([]*BigBloom, len(r.gens))
+	for i, gen := range r.gens {
+		gens[i] = gen.Clone()
+	}
+	return &RotatingBloom{
+		gens:                 gens,
+		cap:                  r.cap,
+		maxFalsePositiveRate: r.maxFalsePositiveRate,
+		interval:             r.interval,
+		clock:                r.clock,
+		rotatedAt:            r.rotatedAt,
+	}
+}
+
+// Checks whether r and other answer every query the same: same generations, and the same rotation times so that they expire together
+func (r *RotatingBloom) Equal(other *RotatingBloom) bool {
+	if len(r.gens) != len(other.gens) || r.interval != other.interval {
+		return false
+	}
+	if r.interval > 0 && !r.rotatedAt.Equal(other.rotatedAt) {
+		return false
+	}
+	for i, gen := range r.gens {
+		if !gen.Equal(other.gens[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *RotatingBloom) String() string {
+	var buf strings.Builder
+
+	buf.WriteString(fmt.Sprintf("rotating bloom filter: %d generations of %d bits, %d unique entries, max cap %d per generation", len(r.gens), 8*r.gens[0].len, r.N(), r.cap))
+	if r.interval > 0 {
+		buf.WriteString(fmt.Sprintf(", rotates every %s", r.interval))
+	}
+
+	return buf.String()
+}
+
+//
+// helpers
+//
+
+// rotates out every generation whose interval has elapsed
+func (r *RotatingBloom) expire() {
+	if r.interval <= 0 {
+		return
+	}
+	elapsed := r.clock().Sub(r.rotatedAt)
+	if elapsed < r.interval {
+		return
+	}
+	steps := int(elapsed / r.interval)
+	r.rotatedAt = r.rotatedAt.Add(time.Duration(steps) * r.interval)
+	if steps > len(r.gens) {
+		// everything expired, no need to rotate more than once per generation
+		steps = len(r.gens)
+	}
+	for i := 0; i < steps; i++ {
+		r.rotate()
+	}
+}
+
+// drops the oldest generation and starts a new empty one, without touching the rotation time
+func (r *RotatingBloom) rotate() {
+	// the oldest generation is cleared and reused as the newest
+	last := len(r.gens) - 1
+	oldest := r.gens[last]
+	oldest.Reset()
+	copy(r.gens[1:], r.gens[:last])
+	r.gens[0] = oldest
+}
@@ -0,0 +1,207 @@
+package bloom
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock is a manually advanced clock
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func TestNewRotatingBloom(t *testing.T) {
+	// test zero generations
+	_, err := NewRotatingBloom(0, 10, .01, time.Minute)
+	assert.EqualError(t, err, "generations cannot be less than 1")
+
+	// test negative interval
+	_, err = NewRotatingBloom(2, 10, .01, -time.Minute)
+	assert.EqualError(t, err, "interval cannot be negative")
+
+	// test nil clock
+	_, err = NewRotatingBloomWithClock(2, 10, .01, time.Minute, nil)
+	assert.EqualError(t, err, "clock cannot be nil")
+
+	// test invalid generation parameters
+	_, err = NewRotatingBloom(2, 0, .01, time.Minute)
+	assert.EqualError(t, err, "capacity cannot be less than 1")
+	_, err = NewRotatingBloom(2, 10, 1, time.Minute)
+	assert.EqualError(t, err, "false positive rate must be between 0 and 1")
+}
+
+func TestRotatingBloomTimeRotation(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	r, err := NewRotatingBloomWithClock(3, 100, .001, time.Minute, clock.Now)
+	assert.Nil(t, err)
+
+	_, err = r.PutStr("first")
+	assert.Nil(t, err)
+
+	// still live after two rotations
+	clock.Advance(2 * time.Minute)
+	exists, _ := r.ExistsStr("first")
+	assert.True(t, exists)
+
+	_, err = r.PutStr("second")
+	assert.Nil(t, err)
+
+	// "first" expires with the third rotation
+	clock.Advance(time.Minute)
+	exists, _ = r.ExistsStr("first")
+	assert.False(t, exists)
+	exists, _ = r.ExistsStr("second")
+	assert.True(t, exists)
+
+	// everything expires after a long pause
+	clock.Advance(time.Hour)
+	exists, _ = r.ExistsStr("second")
+	assert.False(t, exists)
+	assert.Equal(t, 0, r.N())
+	assert.Equal(t, float64(1), r.Accuracy())
+}
+
+func TestRotatingBloomPartialInterval(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	r, err := NewRotatingBloomWithClock(2, 100, .001, time.Minute, clock.Now)
+	assert.Nil(t, err)
+
+	// rotation times stay aligned to the interval rather than the last query
+	clock.Advance(90 * time.Second)
+	r.PutStr("entry")
+	clock.Advance(30 * time.Second)
+	exists, _ := r.ExistsStr("entry")
+	assert.True(t, exists)
+	clock.Advance(time.Minute)
+	exists, _ = r.ExistsStr("entry")
+	assert.False(t, exists)
+}
+
+func TestRotatingBloomManualRotation(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	r, err := NewRotatingBloomWithClock(1, 100, .001, time.Minute, clock.Now)
+	assert.Nil(t, err)
+
+	// a manual rotation restarts the interval
+	clock.Advance(50 * time.Second)
+	r.Rotate()
+	_, err = r.PutStr("entry")
+	assert.Nil(t, err)
+
+	// a minute after construction the generation is only 10 seconds old, so it is kept
+	clock.Advance(50 * time.Second)
+	exists, _ := r.ExistsStr("entry")
+	assert.True(t, exists)
+
+	// a minute after the manual rotation it is rotated out
+	clock.Advance(10 * time.Second)
+	exists, _ = r.ExistsStr("entry")
+	assert.False(t, exists)
+}
+
+func TestRotatingBloomResetCloneEqual(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	r, err := NewRotatingBloomWithClock(2, 100, .01, time.Minute, clock.Now)
+	assert.Nil(t, err)
+	_, err = r.PutStr("a")
+	assert.Nil(t, err)
+
+	// clones rotate at the same times
+	c := r.Clone()
+	assert.True(t, r.Equal(c))
+	_, err = c.PutStr("b")
+	assert.Nil(t, err)
+	assert.False(t, r.Equal(c))
+	exists, _ := r.ExistsStr("b")
+	assert.False(t, exists)
+	_, err = r.PutStr("b")
+	assert.Nil(t, err)
+	assert.True(t, r.Equal(c))
+	clock.Advance(2 * time.Minute)
+	exists, _ = c.ExistsStr("a")
+	assert.False(t, exists)
+	exists, _ = r.ExistsStr("a")
+	assert.False(t, exists)
+
+	// the same entries with different rotation times do not expire together
+	clock.Advance(30 * time.Second)
+	c.Rotate()
+	assert.False(t, r.Equal(c))
+
+	// reset restarts the interval
+	r.PutStr("c")
+	r.Reset()
+	exists, _ = r.ExistsStr("c")
+	assert.False(t, exists)
+	assert.Equal(t, 0, r.N())
+	assert.True(t, r.Equal(c))
+}
+
+func TestRotatingBloomCountRotation(t *testing.T) {
+	// no time based rotation
+	r, err := NewRotatingBloom(2, 5, .1, 0)
+	assert.Nil(t, err)
+
+	for i := 0; i < 5; i++ {
+		_, err := r.PutStr(strconv.Itoa(i))
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, 5, r.N())
+
+	// the sixth entry rotates instead of failing
+	_, err = r.PutStr("5")
+	assert.Nil(t, err)
+	assert.Equal(t, 6, r.N())
+	for i := 0; i < 6; i++ {
+		exists, _ := r.ExistsStr(strconv.Itoa(i))
+		assert.True(t, exists)
+	}
+
+	// filling another generation expires the first one
+	for i := 6; i < 11; i++ {
+		_, err := r.PutStr(strconv.Itoa(i))
+		assert.Nil(t, err)
+	}
+	exists, _ := r.ExistsStr("0")
+	assert.False(t, exists)
+	exists, _ = r.ExistsStr("10")
+	assert.True(t, exists)
+}
+
+func TestRotatingBloomAccuracy(t *testing.T) {
+	r, err := NewRotatingBloom(2, 100, .01, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, float64(1), r.Accuracy())
+
+	for i := 0; i < 50; i++ {
+		r.PutStr(strconv.Itoa(i))
+	}
+	single := r.gens[0].Accuracy()
+	assert.InEpsilon(t, single, r.Accuracy(), 1e-9)
+
+	// combined rate of two generations
+	r.Rotate()
+	for i := 50; i < 100; i++ {
+		r.PutStr(strconv.Itoa(i))
+	}
+	expected := 1 - (1-single)*(1-single)
+	assert.InEpsilon(t, expected, r.Accuracy(), 1e-6)
+}
+
+func TestRotatingBloomString(t *testing.T) {
+	r, err := NewRotatingBloom(2, 10, .01, time.Minute)
+	assert.Nil(t, err)
+	r.PutStr("a")
+	assert.Equal(t, "rotating bloom filter: 2 generations of 96 bits, 1 unique entries, max cap 10 per generation, rotates every 1m0s", r.String())
+}
@@ -0,0 +1,297 @@
+package bloom
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// SaturationPolicy decides what a SaturatingBloom does when its newest filter reaches its capacity or accuracy constraint
+type SaturationPolicy int
+
+const (
+	// returns the constraint error, like BigBloom does
+	SaturationReject SaturationPolicy = iota
+
+	// starts a fresh filter of the same size and keeps the saturated one for reads until the fresh one saturates too
+	SaturationRotate
+
+	// adds a filter of double the capacity and keeps every saturated filter for reads
+	SaturationGrow
+)
+
+// ratio the false positive rate of every new filter is tightened by when growing
+const growTighteningRatio = 0.5
+
+// SaturationEvent describes a filter reaching its capacity or accuracy constraint
+type SaturationEvent struct {
+	// constraint error of the saturated filter. Matches ErrCapacity or ErrAccuracy
+	Err error
+
+	// policy that handled the saturation
+	Policy SaturationPolicy
+
+	// number of unique entries in the saturated filter
+	N int
+
+	// number of live filters after the policy was applied
+	Filters int
+}
+
+// SaturatingBloom is a bloom filter that keeps accepting writes when it saturates, according to its SaturationPolicy.
+// Entries are inserted into the newest filter and checked against every live filter.
+// The false positive rate of each filter is tightened so that the rate across all live filters stays within maxFalsePositiveRate.
+type SaturatingBloom struct {
+	// live filters, newest first
+	filters []*BigBloom
+
+	// what to do when the newest filter saturates
+	policy SaturationPolicy
+
+	// maximum number of unique entries of the first filter
+	cap int
+
+	// maximum false positive rate across all live filters
+	maxFalsePositiveRate float64
+
+	// called in order after every saturation
+	callbacks []func(SaturationEvent)
+
+	// number of saturations so far
+	saturations int
+
+	// whether the filter is saturated under SaturationReject, so later rejections are not new saturations
+	rejecting bool
+}
+
+//
+// Constructors
+//
+
+// Constructs saturating bloom filter for cap entries at maxFalsePositiveRate that handles saturation with policy
+func NewSaturatingBloom(cap int, maxFalsePositiveRate float64, policy SaturationPolicy) (*SaturatingBloom, error) {
+	if policy < SaturationReject || policy > SaturationGrow {
+		return nil, newParameterError("policy", policy, "unknown saturation policy")
+	}
+	if maxFalsePositiveRate <= 0 || maxFalsePositiveRate >= 1 {
+		return nil, newParameterError("maxFalsePositiveRate", maxFalsePositiveRate, "false positive rate must be between 0 and 1")
+	}
+	s := &SaturatingBloom{
+		filters:              nil,
+		policy:               policy,
+		cap:                  cap,
+		maxFalsePositiveRate: maxFalsePositiveRate,
+		callbacks:            nil,
+		saturations:          0,
+		rejecting:            false,
+	}
+	b, err := NewBigBloomAlloc(cap, s.filterFalsePositiveRate(0))
+	if err != nil {
+		return nil, err
+	}
+	s.filters = []*BigBloom{b}
+	return s, nil
+}
+
+//
+// Methods
+//
+
+// Registers fn to be called after every saturation, once the policy has been applied.
+// With SaturationReject it is called once, when the filter saturates, and not for every rejected put after that.
+// Callbacks are called in the order they were registered.
+func (s *SaturatingBloom) OnSaturation(fn func(SaturationEvent)) {
+	s.callbacks = append(s.callbacks, fn)
+}
+
+// Inserts string element into the newest filter
+func (s *SaturatingBloom) PutStr(str string) (*SaturatingBloom, error) {
+	bs := []byte(str)
+	return s.PutBytes(bs)
+}
+
+// Inserts bytes element into the newest filter. If the newest filter is saturated, the policy is applied first.
+// Returns the constraint error if the policy is SaturationReject.
+func (s *SaturatingBloom) PutBytes(bs []byte) (*SaturatingBloom, error) {
+	if s.policy == SaturationGrow {
+		// filters are never dropped, so an entry in an older filter does not need to be inserted again
+		for _, b := range s.filters[1:] {
+			if exists, _ := b.ExistsBytes(bs); exists {
+				return s, nil
+			}
+		}
+	}
+	_, err := s.filters[0].PutBytes(bs)
+	if err == nil {
+		return s, nil
+	}
+	if !errors.Is(err, ErrCapacity) && !errors.Is(err, ErrAccuracy) {
+		return s, err
+	}
+	if err := s.saturate(err); err != nil {
+		return s, err
+	}
+	_, err = s.filters[0].PutBytes(bs)
+	return s, err
+}
+
+// Checks for existance of a string in any live filter. Returns boolean and false positive rate.
+func (s *SaturatingBloom) ExistsStr(str string) (bool, float64) {
+	bs := []byte(str)
+	return s.ExistsBytes(bs)
+}
+
+// Checks for existance of bytes element in any live filter. Returns boolean and false positive rate.
+func (s *SaturatingBloom) ExistsBytes(bs []byte) (bool, float64) {
+	for _, b := range s.filters {
+		if exists, _ := b.ExistsBytes(bs); exists {
+			return true, s.Accuracy()
+		}
+	}
+	return false, 1
+}
+
+// Get false positive rate across all live filters
+func (s *SaturatingBloom) Accuracy() float64 {
+	// a query is a false positive if any of the filters gives a false positive
+	trueNegative := float64(1)
+	empty := true
+	for _, b := range s.filters {
+		if b.n == 0 {
+			continue
+		}
+		empty = false
+		trueNegative *= 1 - b.Accuracy()
+	}
+	if empty {
+		return 1
+	}
+	return 1 - trueNegative
+}
+
+// Number of unique entries across all live filters. With SaturationRotate, entries inserted into both filters are counted twice.
+func (s *SaturatingBloom) N() int {
+	n := 0
+	for _, b := range s.filters {
+		n += b.n
+	}
+	return n
+}
+
+// Number of live filters
+func (s *SaturatingBloom) Filters() int {
+	return len(s.filters)
+}
+
+// Number of times the newest filter has saturated
+func (s *SaturatingBloom) Saturations() int {
+	return s.saturations
+}
+
+// Removes all entries and drops every filter the policy added, so the filter is as it was constructed. Callbacks are kept
+func (s *SaturatingBloom) Reset() {
+	// the oldest filter is the one that was constructed
+	first := s.filters[len(s.filters)-1]
+	first.Reset()
+	s.filters = []*BigBloom{first}
+	s.saturations = 0
+	s.rejecting = false
+}
+
+// Copies the filter and its live filters. Callbacks are not copied, so the copy can be used as a snapshot without calling them
+func (s *SaturatingBloom) Clone() *SaturatingBloom {
+	filters := make([]*BigBloom, len(s.filters))
+	for i, b := range s.filters {
+		filters[i] = b.Clone()
+	}
+	return &SaturatingBloom{
+		filters:              filters,
+		policy:               s.policy,
+		cap:                  s.cap,
+		maxFalsePositiveRate: s.maxFalsePositiveRate,
+		callbacks:            nil,
+		saturations:          s.saturations,
+		rejecting:            s.rejecting,
+	}
+}
+
+// Checks whether s and other answer every query the same: same live filters
+func (s *SaturatingBloom) Equal(other *SaturatingBloom) bool {
+	if len(s.filters) != len(other.filters) {
+		return false
+	}
+	for i, b := range s.filters {
+		if !b.Equal(other.filters[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *SaturatingBloom) String() string {
+	return fmt.Sprintf("saturating bloom filter: %d filters, %d unique entries, %d saturations, max false positive rate %f", len(s.filters), s.N(), s.saturations, s.maxFalsePositiveRate)
+}
+
+//
+// helpers
+//
+
+// applies the policy to the saturated newest filter and calls the callbacks
+func (s *SaturatingBloom) saturate(err error) error {
+	if s.rejecting {
+		return err
+	}
+	event := SaturationEvent{
+		Err:     err,
+		Policy:  s.policy,
+		N:       s.filters[0].n,
+		Filters: len(s.filters),
+	}
+	s.saturations++
+
+	switch s.policy {
+	case SaturationRotate:
+		if len(s.filters) == 2 {
+			// the older filter is cleared and reused as the newest
+			oldest := s.filters[1]
+			oldest.Reset()
+			s.filters[0], s.filters[1] = oldest, s.filters[0]
+		} else {
+			b, err := NewBigBloomAlloc(s.cap, s.filterFalsePositiveRate(1))
+			if err != nil {
+				return err
+			}
+			s.filters = append([]*BigBloom{b}, s.filters...)
+		}
+	case SaturationGrow:
+		i := len(s.filters)
+		b, err := NewBigBloomAlloc(s.cap<<i, s.filterFalsePositiveRate(i))
+		if err != nil {
+			return err
+		}
+		s.filters = append([]*BigBloom{b}, s.filters...)
+	}
+
+	event.Filters = len(s.filters)
+	for _, fn := range s.callbacks {
+		fn(event)
+	}
+	if s.policy == SaturationReject {
+		s.rejecting = true
+		return err
+	}
+	return nil
+}
+
+// false positive rate of the i-th filter, counting from the oldest, so that the rate across all live filters stays within maxFalsePositiveRate
+func (s *SaturatingBloom) filterFalsePositiveRate(i int) float64 {
+	switch s.policy {
+	case SaturationRotate:
+		// two live filters: (1-p')^2 = 1-p
+		return 1 - math.Sqrt(1-s.maxFalsePositiveRate)
+	case SaturationGrow:
+		// p/2 + p/4 + p/8 + ... stays within p, and the rate across filters is at most the sum of their rates
+		return s.maxFalsePositiveRate * (1 - growTighteningRatio) * math.Pow(growTighteningRatio, float64(i))
+	}
+	return s.maxFalsePositiveRate
+}
@@ -0,0 +1,171 @@
+package bloom
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSaturatingBloom(t *testing.T) {
+	// test unknown policy
+	_, err := NewSaturatingBloom(10, .01, SaturationPolicy(3))
+	assert.EqualError(t, err, "unknown saturation policy")
+	assert.True(t, errors.Is(err, ErrInvalidParameter))
+
+	// test invalid parameters
+	_, err = NewSaturatingBloom(0, .01, SaturationGrow)
+	assert.EqualError(t, err, "capacity cannot be less than 1")
+	_, err = NewSaturatingBloom(10, 1, SaturationRotate)
+	assert.EqualError(t, err, "false positive rate must be between 0 and 1")
+}
+
+func TestSaturatingBloomReject(t *testing.T) {
+	s, err := NewSaturatingBloom(10, .01, SaturationReject)
+	assert.Nil(t, err)
+	var events []SaturationEvent
+	s.OnSaturation(func(e SaturationEvent) {
+		events = append(events, e)
+	})
+
+	// insert until the filter saturates
+	i := 0
+	for ; err == nil; i++ {
+		_, err = s.PutStr(strconv.Itoa(i))
+	}
+	assert.True(t, errors.Is(err, ErrCapacity) || errors.Is(err, ErrAccuracy))
+	assert.LessOrEqual(t, i, 11)
+
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, err, events[0].Err)
+	assert.Equal(t, SaturationReject, events[0].Policy)
+	assert.Equal(t, s.N(), events[0].N)
+	assert.Equal(t, 1, events[0].Filters)
+	assert.Equal(t, 1, s.Filters())
+	assert.Equal(t, 1, s.Saturations())
+	assert.LessOrEqual(t, s.Accuracy(), .01)
+
+	// later rejections are not new saturations
+	for j := 0; j < 5; j++ {
+		_, err = s.PutStr(strconv.Itoa(i + j))
+		assert.True(t, errors.Is(err, ErrCapacity) || errors.Is(err, ErrAccuracy))
+	}
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, 1, s.Saturations())
+}
+
+func TestSaturatingBloomRotate(t *testing.T) {
+	cap := 100
+	s, err := NewSaturatingBloom(cap, .01, SaturationRotate)
+	assert.Nil(t, err)
+	// index of the entry that saturated the filter
+	var rotatedAt []int
+	i := 0
+	s.OnSaturation(func(e SaturationEvent) {
+		assert.True(t, errors.Is(e.Err, ErrCapacity) || errors.Is(e.Err, ErrAccuracy))
+		assert.Equal(t, SaturationRotate, e.Policy)
+		assert.LessOrEqual(t, e.N, cap)
+		assert.Equal(t, 2, e.Filters)
+		rotatedAt = append(rotatedAt, i)
+	})
+
+	for ; i < 5*cap; i++ {
+		_, err = s.PutStr(strconv.Itoa(i))
+		assert.Nil(t, err)
+		assert.LessOrEqual(t, s.Accuracy(), .01)
+	}
+	assert.GreaterOrEqual(t, s.Saturations(), 4)
+	assert.Equal(t, len(rotatedAt), s.Saturations())
+	assert.Equal(t, 2, s.Filters())
+
+	// entries since the second to last rotation are still live
+	for i := rotatedAt[len(rotatedAt)-2]; i < 5*cap; i++ {
+		exists, acc := s.ExistsStr(strconv.Itoa(i))
+		assert.True(t, exists)
+		assert.LessOrEqual(t, acc, .01)
+	}
+}
+
+func TestSaturatingBloomGrow(t *testing.T) {
+	cap := 100
+	s, err := NewSaturatingBloom(cap, .01, SaturationGrow)
+	assert.Nil(t, err)
+	filters := []int{}
+	s.OnSaturation(func(e SaturationEvent) {
+		assert.LessOrEqual(t, e.N, cap<<(e.Filters-2))
+		filters = append(filters, e.Filters)
+	})
+
+	// 100 + 200 + 400 need at least three filters
+	for i := 0; i < 7*cap; i++ {
+		_, err = s.PutStr(strconv.Itoa(i))
+		assert.Nil(t, err)
+		assert.LessOrEqual(t, s.Accuracy(), .01)
+	}
+	assert.GreaterOrEqual(t, len(filters), 2)
+	for i, n := range filters {
+		assert.Equal(t, i+2, n)
+	}
+	// entries that are false positives of an older filter are not inserted again
+	assert.LessOrEqual(t, s.N(), 7*cap)
+
+	// every entry stays live, and inserting it again does not count it twice
+	n := s.N()
+	for i := 0; i < 7*cap; i++ {
+		exists, _ := s.ExistsStr(strconv.Itoa(i))
+		assert.True(t, exists)
+		_, err = s.PutStr(strconv.Itoa(i))
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, n, s.N())
+	assert.Equal(t, fmt.Sprintf("saturating bloom filter: %d filters, %d unique entries, %d saturations, max false positive rate 0.010000", s.Filters(), n, s.Saturations()), s.String())
+}
+
+func TestSaturatingBloomResetCloneEqual(t *testing.T) {
+	s, err := NewSaturatingBloom(10, .01, SaturationGrow)
+	assert.Nil(t, err)
+	events := 0
+	s.OnSaturation(func(e SaturationEvent) {
+		events++
+	})
+	for i := 0; i < 30; i++ {
+		_, err = s.PutStr(strconv.Itoa(i))
+		assert.Nil(t, err)
+	}
+	assert.Greater(t, s.Filters(), 1)
+
+	// clones do not call the callbacks
+	c := s.Clone()
+	assert.True(t, s.Equal(c))
+	saturations := events
+	for i := 30; i < 100; i++ {
+		_, err = c.PutStr(strconv.Itoa(i))
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, saturations, events)
+	assert.Greater(t, c.Saturations(), s.Saturations())
+	assert.False(t, s.Equal(c))
+
+	// reset drops the filters the policy added
+	s.Reset()
+	assert.Equal(t, 1, s.Filters())
+	assert.Equal(t, 0, s.N())
+	assert.Equal(t, 0, s.Saturations())
+	exists, _ := s.ExistsStr("0")
+	assert.False(t, exists)
+	fresh, err := NewSaturatingBloom(10, .01, SaturationGrow)
+	assert.Nil(t, err)
+	assert.True(t, s.Equal(fresh))
+
+	// a rejecting filter accepts entries again after a reset
+	r, err := NewSaturatingBloom(10, .01, SaturationReject)
+	assert.Nil(t, err)
+	for i := 0; err == nil; i++ {
+		_, err = r.PutStr(strconv.Itoa(i))
+	}
+	r.Reset()
+	_, err = r.PutStr("a")
+	assert.Nil(t, err)
+}
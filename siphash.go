@@ -0,0 +1,78 @@
+package bloom
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"math/bits"
+)
+
+// SipHash-2-4 (Aumasson & Bernstein, 2012), a fast keyed hash. Without the key the outputs cannot be predicted,
+// so elements that collide in one filter cannot be crafted ahead of time.
+
+// Generates a random secret key for keyed hashing
+func GenerateKey() ([BLOOM_KEY_LEN]byte, error) {
+	var key [BLOOM_KEY_LEN]byte
+	_, err := rand.Read(key[:])
+	return key, err
+}
+
+// Calculates the 64-bit SipHash-2-4 of data with a 128-bit key
+func SipHash24(key [BLOOM_KEY_LEN]byte, data []byte) uint64 {
+	k0 := binary.LittleEndian.Uint64(key[0:8])
+	k1 := binary.LittleEndian.Uint64(key[8:16])
+	return siphash24(k0, k1, data)
+}
+
+// SipHash-2-4 with the key split into two little endian words
+func siphash24(k0, k1 uint64, data []byte) uint64 {
+	v0 := k0 ^ 0x736f6d6570736575
+	v1 := k1 ^ 0x646f72616e646f6d
+	v2 := k0 ^ 0x6c7967656e657261
+	v3 := k1 ^ 0x7465646279746573
+
+	round := func() {
+		v0 += v1
+		v1 = bits.RotateLeft64(v1, 13)
+		v1 ^= v0
+		v0 = bits.RotateLeft64(v0, 32)
+		v2 += v3
+		v3 = bits.RotateLeft64(v3, 16)
+		v3 ^= v2
+		v0 += v3
+		v3 = bits.RotateLeft64(v3, 21)
+		v3 ^= v0
+		v2 += v1
+		v1 = bits.RotateLeft64(v1, 17)
+		v1 ^= v2
+		v2 = bits.RotateLeft64(v2, 32)
+	}
+
+	// compress full 8 byte words with 2 rounds each
+	length := len(data)
+	for len(data) >= 8 {
+		m := binary.LittleEndian.Uint64(data)
+		v3 ^= m
+		round()
+		round()
+		v0 ^= m
+		data = data[8:]
+	}
+
+	// last word holds the remaining bytes and the length in its top byte
+	var last [8]byte
+	copy(last[:], data)
+	last[7] = byte(length)
+	m := binary.LittleEndian.Uint64(last[:])
+	v3 ^= m
+	round()
+	round()
+	v0 ^= m
+
+	// finalize with 4 rounds
+	v2 ^= 0xff
+	round()
+	round()
+	round()
+	round()
+	return v0 ^ v1 ^ v2 ^ v3
+}
@@ -0,0 +1,45 @@
+package bloom
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// vectors from the reference implementation, with key 00 01 ... 0f and message 00 01 ... of each length
+func TestSipHash24(t *testing.T) {
+	var key [BLOOM_KEY_LEN]byte
+	for i := range key {
+		key[i] = byte(i)
+	}
+	message := make([]byte, 64)
+	for i := range message {
+		message[i] = byte(i)
+	}
+
+	type sipTest struct {
+		length   int
+		expected uint64
+	}
+
+	tests := []sipTest{
+		{length: 0, expected: 0x726fdb47dd0e0e31},
+		{length: 1, expected: 0x74f839c593dc67fd},
+		{length: 7, expected: 0xab0200f58b01d137},
+		{length: 8, expected: 0x93f5f5799a932462},
+		{length: 15, expected: 0xa129ca6149be45e5},
+		{length: 63, expected: 0x958a324ceb064572},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, SipHash24(key, message[:test.length]))
+	}
+}
+
+func TestGenerateKey(t *testing.T) {
+	a, err := GenerateKey()
+	assert.Nil(t, err)
+	b, err := GenerateKey()
+	assert.Nil(t, err)
+	assert.NotEqual(t, a, b)
+}
@@ -0,0 +1,220 @@
+package bloom
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+// StableBloom is a Stable Bloom Filter (Deng & Rafiei, 2006) for duplicate detection on unbounded streams.
+// Every insert decrements P random cells before setting the entry's k cells to the maximum cell value,
+// so old entries are gradually evicted and the false positive rate converges to a stable point instead of 1.
+// The trade-off is that, unlike BigBloom, a stable bloom filter can have false negatives.
+type StableBloom struct {
+	// number of insertions
+	n int
+
+	// number of hash functions
+	k int
+
+	// number of cells
+	m int
+
+	// bits per cell
+	d int
+
+	// value a cell is set to on insert: 2^d - 1
+	max uint8
+
+	// number of cells decremented on each insert
+	p int
+
+	// one cell per byte, each cell holds a value between 0 and max
+	cells []uint8
+
+	// splitmix64 state of the random decrements. A plain integer, so clones decrement the same cells
+	rngState uint64
+}
+
+//
+// Constructors
+//
+
+// Constructs stable bloom filter of m d-bit cells with k hash functions that decrements p cells on each insert.
+// seed seeds the random decrements so that a stream always produces the same filter.
+func NewStableBloom(m, k, d, p int, seed int64) (*StableBloom, error) {
+	if m < 1 {
+		return nil, newParameterError("m", m, "number of cells cannot be less than 1")
+	}
+	if k < 1 {
+		return nil, newParameterError("k", k, "k cannot be less than 1")
+	}
+	if k > m {
+		return nil, newParameterError("k", k, "k cannot be greater than the number of cells")
+	}
+	if d < 1 || d > 8 {
+		return nil, newParameterError("d", d, "bits per cell must be between 1 and 8")
+	}
+	if p < 1 {
+		return nil, newParameterError("p", p, "number of decrements cannot be less than 1")
+	}
+	return &StableBloom{
+		n:        0,
+		k:        k,
+		m:        m,
+		d:        d,
+		max:      uint8(1<<d - 1),
+		p:        p,
+		cells:    make([]uint8, m),
+		rngState: uint64(seed),
+	}, nil
+}
+
+// Constructs stable bloom filter of m d-bit cells with k hash functions
+// that picks the number of decrements so that the stable false positive rate is maxFalsePositiveRate
+func NewStableBloomFromAcc(m, k, d int, maxFalsePositiveRate float64, seed int64) (*StableBloom, error) {
+	if maxFalsePositiveRate <= 0 || maxFalsePositiveRate >= 1 {
+		return nil, newParameterError("maxFalsePositiveRate", maxFalsePositiveRate, "false positive rate must be between 0 and 1")
+	}
+	if d < 1 || d > 8 {
+		return nil, newParameterError("d", d, "bits per cell must be between 1 and 8")
+	}
+	if k >= m {
+		return nil, newParameterError("k", k, "k must be less than the number of cells")
+	}
+	return NewStableBloom(m, k, d, calcStableP(m, k, d, maxFalsePositiveRate), seed)
+}
+
+//
+// Methods
+//
+
+// Inserts string element into stable bloom filter
+func (s *StableBloom) PutStr(str string) *StableBloom {
+	bs := []byte(str)
+	return s.PutBytes(bs)
+}
+
+// Inserts bytes element into stable bloom filter. Decrements p random cells and then sets the element's k cells to max.
+func (s *StableBloom) PutBytes(bs []byte) *StableBloom {
+	for i := 0; i < s.p; i++ {
+		cellI, _ := bits.Mul64(splitmix64(&s.rngState), uint64(s.m))
+		if s.cells[cellI] > 0 {
+			s.cells[cellI]--
+		}
+	}
+	for i := 0; i < s.k; i++ {
+		cellI := hashIndex(bs, i, uint64(s.m))
+		s.cells[cellI] = s.max
+	}
+	s.n++
+	return s
+}
+
+// Checks for existance of a string in a stable bloom filter. Returns boolean and stable false positive rate.
+func (s *StableBloom) ExistsStr(str string) (bool, float64) {
+	bs := []byte(str)
+	return s.ExistsBytes(bs)
+}
+
+// Checks for existance of bytes element in a stable bloom filter. Returns boolean and stable false positive rate.
+func (s *StableBloom) ExistsBytes(bs []byte) (bool, float64) {
+	for i := 0; i < s.k; i++ {
+		cellI := hashIndex(bs, i, uint64(s.m))
+		if s.cells[cellI] == 0 {
+			return false, 1
+		}
+	}
+	return true, s.Accuracy()
+}
+
+// Get false positive rate at the stable point. This is the rate the filter converges to as the stream grows,
+// and an upper bound of the rate before then. Returns 1 when there are no entries.
+func (s *StableBloom) Accuracy() float64 {
+	if s.n == 0 {
+		return 1
+	}
+	return stableFalsePositiveRate(s.m, s.k, s.d, s.p)
+}
+
+// Fraction of cells that are currently 0
+func (s *StableBloom) ZeroRatio() float64 {
+	zeros := 0
+	for _, c := range s.cells {
+		if c == 0 {
+			zeros++
+		}
+	}
+	return float64(zeros) / float64(s.m)
+}
+
+// Removes all entries. The settings and the state of the random decrements are kept
+func (s *StableBloom) Reset() {
+	for i := range s.cells {
+		s.cells[i] = 0
+	}
+	s.n = 0
+}
+
+// Copies the filter, including the state of the random decrements, so the copy evolves the same on the same stream
+func (s *StableBloom) Clone() *StableBloom {
+	cells := make([]uint8, len(s.cells))
+	copy(cells, s.cells)
+	return &StableBloom{
+		n:        s.n,
+		k:        s.k,
+		m:        s.m,
+		d:        s.d,
+		max:      s.max,
+		p:        s.p,
+		cells:    cells,
+		rngState: s.rngState,
+	}
+}
+
+// Checks whether s and other answer every query the same: same k and cells
+func (s *StableBloom) Equal(other *StableBloom) bool {
+	if s.k != other.k || s.m != other.m {
+		return false
+	}
+	for i := range s.cells {
+		if s.cells[i] != other.cells[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *StableBloom) String() string {
+	return fmt.Sprintf("stable bloom filter: %d %d-bit cells, k %d, %d decrements per insert, %d insertions", s.m, s.d, s.k, s.p, s.n)
+}
+
+//
+// helpers
+//
+
+// calculate the stable point false positive rate
+func stableFalsePositiveRate(m, k, d, p int) float64 {
+	// equations from Deng & Rafiei, "Approximately Detecting Duplicates for Streaming Data using Stable Bloom Filters"
+	// fraction of 0 cells at the stable point: zeros = (1 / (1 + 1/(p(1/k - 1/m))))^max
+	// false positive rate: (1 - zeros)^k
+	max := float64(int(1)<<d - 1)
+	inner := 1 / (1 + 1/(float64(p)*(1/float64(k)-1/float64(m))))
+	zeros := math.Pow(inner, max)
+	return math.Pow(1-zeros, float64(k))
+}
+
+// calculate the number of decrements per insert that gives the stable false positive rate acc
+func calcStableP(m, k, d int, acc float64) int {
+	// rearranging stableFalsePositiveRate for p:
+	// p = 1 / ((1/(1 - acc^(1/k))^(1/max) - 1) * (1/k - 1/m))
+	max := float64(int(1)<<d - 1)
+	zeros := 1 - math.Pow(acc, 1/float64(k))
+	denom := (math.Pow(1/zeros, 1/max) - 1) * (1/float64(k) - 1/float64(m))
+	p := int(math.Round(1 / denom))
+	if p < 1 {
+		// at least one cell must be decremented for old entries to be evicted
+		p = 1
+	}
+	return p
+}
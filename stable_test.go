@@ -0,0 +1,162 @@
+package bloom
+
+import (
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewStableBloom(t *testing.T) {
+	// test zero cells
+	_, err := NewStableBloom(0, 3, 3, 10, 1)
+	assert.EqualError(t, err, "number of cells cannot be less than 1")
+	// test zero k
+	_, err = NewStableBloom(1000, 0, 3, 10, 1)
+	assert.EqualError(t, err, "k cannot be less than 1")
+	// test k larger than cells
+	_, err = NewStableBloom(2, 3, 3, 10, 1)
+	assert.EqualError(t, err, "k cannot be greater than the number of cells")
+	// test cell size
+	_, err = NewStableBloom(1000, 3, 0, 10, 1)
+	assert.EqualError(t, err, "bits per cell must be between 1 and 8")
+	_, err = NewStableBloom(1000, 3, 9, 10, 1)
+	assert.EqualError(t, err, "bits per cell must be between 1 and 8")
+	// test zero decrements
+	_, err = NewStableBloom(1000, 3, 3, 0, 1)
+	assert.EqualError(t, err, "number of decrements cannot be less than 1")
+}
+
+func TestNewStableBloomFromAcc(t *testing.T) {
+	// test zero accuracy
+	_, err := NewStableBloomFromAcc(1000, 3, 3, 0, 1)
+	assert.EqualError(t, err, "false positive rate must be between 0 and 1")
+	// test one accuracy
+	_, err = NewStableBloomFromAcc(1000, 3, 3, 1, 1)
+	assert.EqualError(t, err, "false positive rate must be between 0 and 1")
+
+	// the chosen p should give back the requested stable rate
+	s, err := NewStableBloomFromAcc(100000, 4, 3, .01, 1)
+	assert.Nil(t, err)
+	s.PutStr("a")
+	assert.InDelta(t, .01, s.Accuracy(), .0005)
+}
+
+func TestStableBloomExistsStr(t *testing.T) {
+	s, err := NewStableBloom(1000, 3, 3, 50, 1)
+	assert.Nil(t, err)
+
+	// no entries
+	exists, acc := s.ExistsStr("test")
+	assert.False(t, exists)
+	assert.Equal(t, float64(1), acc)
+
+	// the most recent entry always exists because its cells were set after the decrements
+	for i := 0; i < 10000; i++ {
+		s.PutStr(strconv.Itoa(i))
+		exists, _ := s.ExistsStr(strconv.Itoa(i))
+		assert.True(t, exists)
+	}
+
+	// old entries are evicted
+	evicted := 0
+	for i := 0; i < 100; i++ {
+		if exists, _ := s.ExistsStr(strconv.Itoa(i)); !exists {
+			evicted++
+		}
+	}
+	assert.Greater(t, evicted, 90)
+}
+
+func TestStableBloomSeed(t *testing.T) {
+	a, err := NewStableBloom(1000, 3, 2, 5, 42)
+	assert.Nil(t, err)
+	b, err := NewStableBloom(1000, 3, 2, 5, 42)
+	assert.Nil(t, err)
+	for i := 0; i < 5000; i++ {
+		a.PutStr(strconv.Itoa(i))
+		b.PutStr(strconv.Itoa(i))
+	}
+	assert.Equal(t, a.cells, b.cells)
+}
+
+func TestStableBloomConvergence(t *testing.T) {
+	m, k, d, p := 10000, 3, 3, 20
+	s, err := NewStableBloom(m, k, d, p, 7)
+	assert.Nil(t, err)
+
+	// stream far more unique entries than the filter could ever hold
+	for i := 0; i < 200000; i++ {
+		s.PutStr(strconv.Itoa(i))
+	}
+
+	// fraction of zero cells converges to the analytic stable point
+	max := float64(int(1)<<d - 1)
+	expectedZeros := math.Pow(1/(1+1/(float64(p)*(1/float64(k)-1/float64(m)))), max)
+	assert.InDelta(t, expectedZeros, s.ZeroRatio(), .02)
+
+	// observed false positive rate on entries never inserted stays close to Accuracy()
+	falsePositives := 0
+	probes := 20000
+	for i := 0; i < probes; i++ {
+		if exists, _ := s.ExistsStr("probe" + strconv.Itoa(i)); exists {
+			falsePositives++
+		}
+	}
+	observed := float64(falsePositives) / float64(probes)
+	assert.InDelta(t, s.Accuracy(), observed, .02)
+	assert.Less(t, s.Accuracy(), .5)
+}
+
+func TestCalcStableP(t *testing.T) {
+	for _, acc := range []float64{.001, .01, .1} {
+		p := calcStableP(100000, 5, 4, acc)
+		assert.InEpsilon(t, acc, stableFalsePositiveRate(100000, 5, 4, p), .05)
+	}
+	// p can never be 0
+	assert.Equal(t, 1, calcStableP(10, 1, 1, .99))
+}
+
+func TestHashIndex(t *testing.T) {
+	// hashIndex derives the same index as BigBloom
+	b, err := NewBigBloomFromK(100, 3)
+	assert.Nil(t, err)
+	b.PutStr("test")
+	for i := 0; i < 3; i++ {
+		bitI := hashIndex([]byte("test"), i, 800)
+		assert.NotZero(t, b.Bytes()[bitI/8]&(1<<(bitI%8)))
+	}
+
+	// the caller's backing array is never written to
+	bs := make([]byte, 4, 5)
+	copy(bs, "test")
+	full := bs[:5]
+	full[4] = 0xff
+	hashIndex(bs, 3, 800)
+	assert.Equal(t, byte(0xff), full[4])
+}
+
+func TestStableBloomResetCloneEqual(t *testing.T) {
+	s, err := NewStableBloom(1000, 3, 2, 5, 42)
+	assert.Nil(t, err)
+	for i := 0; i < 100; i++ {
+		s.PutStr(strconv.Itoa(i))
+	}
+
+	// clones decrement the same cells, so they stay equal on the same stream
+	c := s.Clone()
+	assert.True(t, s.Equal(c))
+	s.PutStr("a")
+	assert.False(t, s.Equal(c))
+	c.PutStr("a")
+	assert.True(t, s.Equal(c))
+
+	s.Reset()
+	assert.Equal(t, 1.0, s.ZeroRatio())
+	exists, _ := s.ExistsStr("a")
+	assert.False(t, exists)
+	exists, _ = c.ExistsStr("a")
+	assert.True(t, exists)
+	assert.False(t, s.Equal(c))
+}